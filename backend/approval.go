@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ========== 支払い承認（LIFF・Bot共通） ==========
+
+// errNotOrganizer は会計者以外が承認・差し戻しをしようとした場合のエラー
+var errNotOrganizer = errors.New("not the organizer of this event")
+
+// authorizeApproval は参加者レコードの会計者が操作ユーザーか確認する
+func authorizeApproval(participantID int, organizerUserID string) error {
+	organizerID, err := GetParticipantOrganizerID(participantID)
+	if err != nil {
+		return fmt.Errorf("failed to get organizer: %w", err)
+	}
+	if organizerID != organizerUserID {
+		return errNotOrganizer
+	}
+	return nil
+}
+
+// approvePayment は支払い報告を承認して参加者に通知する
+// 承認待ちでなかった（処理済みなど）場合はfalseを返す
+func approvePayment(participantID int, organizerUserID string) (bool, error) {
+	if err := authorizeApproval(participantID, organizerUserID); err != nil {
+		return false, err
+	}
+
+	approved, err := ApproveParticipant(participantID)
+	if err != nil || !approved {
+		return approved, err
+	}
+
+	// 承認通知を送信（非同期）
+	go func() {
+		info, err := GetApprovalNotifyInfo(participantID)
+		if err != nil {
+			log.Printf("承認通知情報取得エラー: %v", err)
+			return
+		}
+
+		organizer, _ := GetUser(organizerUserID)
+		if organizer != nil {
+			notifyText := fmt.Sprintf("【支払い承認】\n%sさんが支払いを承認しました。\n\nイベント: %s\n金額: %d円\n\nありがとうございました！",
+				organizer.Name, info.EventName, info.SplitAmount)
+			PushMessage(info.ParticipantUserID, notifyText)
+			log.Printf("承認通知送信: %s", info.ParticipantName)
+		}
+	}()
+
+	return true, nil
+}
+
+// rejectPayment は支払い報告を差し戻して参加者に通知する
+// 承認待ちでなかった（処理済みなど）場合はfalseを返す
+func rejectPayment(participantID int, organizerUserID string) (bool, error) {
+	if err := authorizeApproval(participantID, organizerUserID); err != nil {
+		return false, err
+	}
+
+	rejected, err := RejectPaymentReport(participantID)
+	if err != nil || !rejected {
+		return rejected, err
+	}
+
+	// 差し戻し通知を送信（非同期）
+	go func() {
+		info, err := GetApprovalNotifyInfo(participantID)
+		if err != nil {
+			log.Printf("差し戻し通知情報取得エラー: %v", err)
+			return
+		}
+
+		organizer, _ := GetUser(organizerUserID)
+		if organizer != nil {
+			notifyText := fmt.Sprintf("【支払い確認できず】\n%sさんが支払い報告を差し戻しました。\n\nイベント: %s\n金額: %d円\n\n支払い状況を確認し、支払い後にもう一度報告してください。",
+				organizer.Name, info.EventName, info.SplitAmount)
+			PushMessage(info.ParticipantUserID, notifyText)
+			log.Printf("差し戻し通知送信: %s", info.ParticipantName)
+		}
+	}()
+
+	return true, nil
+}
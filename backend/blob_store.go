@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ========== Strategy Pattern: ファイル保存先 ==========
+
+// errBlobNotFound は指定したキーのファイルがない場合のエラー
+var errBlobNotFound = errors.New("blob not found")
+
+// BlobStore は画像などのファイルの保存先を表すインターフェース
+// キーは"event-receipts/12/xxxx"のようなスラッシュ区切りのパス
+type BlobStore interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// blobStore はアプリ全体で使う保存先（initBlobStoreで設定する）
+var blobStore BlobStore
+
+// blobStoreFactories は保存先の種類と作成処理の対応表
+// S3などの保存先を追加する場合はここに登録する
+var blobStoreFactories = map[string]func() (BlobStore, error){
+	"local": func() (BlobStore, error) {
+		dir := os.Getenv("BLOB_STORE_DIR")
+		if dir == "" {
+			dir = defaultBlobStoreDir
+		}
+		return newLocalBlobStore(dir)
+	},
+}
+
+// defaultBlobStoreDir はローカル保存先のデフォルトのディレクトリ
+const defaultBlobStoreDir = "data/blobs"
+
+// initBlobStore は環境変数BLOB_STORE（省略時はlocal）の保存先を設定する
+func initBlobStore() error {
+	kind := os.Getenv("BLOB_STORE")
+	if kind == "" {
+		kind = "local"
+	}
+
+	factory, ok := blobStoreFactories[kind]
+	if !ok {
+		return fmt.Errorf("unknown BLOB_STORE: %s", kind)
+	}
+
+	store, err := factory()
+	if err != nil {
+		return fmt.Errorf("failed to initialize %s blob store: %w", kind, err)
+	}
+	blobStore = store
+	log.Printf("ファイル保存先: %s", kind)
+	return nil
+}
+
+// newBlobKey は接頭辞の下に推測されにくいキーを作る
+func newBlobKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate blob key: %w", err)
+	}
+	return strings.TrimRight(prefix, "/") + "/" + hex.EncodeToString(b), nil
+}
+
+// ========== BlobStore 実装 ==========
+
+// localBlobStore はローカルのディレクトリに保存する
+type localBlobStore struct {
+	dir string
+}
+
+func newLocalBlobStore(dir string) (*localBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localBlobStore{dir: dir}, nil
+}
+
+// path はキーに対応するファイルパスを返す（ディレクトリの外を指すキーはエラー）
+func (s *localBlobStore) path(key string) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.dir, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+	return p, nil
+}
+
+func (s *localBlobStore) Put(key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// 書き込み途中のファイルを読まれないよう、一時ファイルに書いてから置き換える
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s *localBlobStore) Get(key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+func (s *localBlobStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========== 監査ログリポジトリ ==========
+
+// sqlExecer はsql.DBとsql.Txの共通インターフェース
+// 監査ログは操作と同じトランザクションで記録できるようにする
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// sqlRowQueryer はsql.DBとsql.Txの共通インターフェース（1行を返すクエリ用）
+type sqlRowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// recordCircleAudit は監査ログを1件追記する
+func recordCircleAudit(exec sqlExecer, entry *CircleAuditLog) error {
+	details := entry.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO circle_audit_logs (circle_id, actor_id, target_id, action, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, entry.CircleID, entry.ActorID, entry.TargetID, entry.Action, entry.Reason, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// recordParticipantAudit は支払い報告の承認・差し戻しを監査ログに記録する
+// 実行者はイベントの会計者。サークルに属さないイベントは記録しない
+func recordParticipantAudit(exec sqlExecer, participantID int, action string) error {
+	_, err := exec.Exec(`
+		INSERT INTO circle_audit_logs (circle_id, actor_id, target_id, action, details)
+		SELECT e.circle_id, e.organizer_id, ep.user_id, $2,
+		       jsonb_build_object('eventId', e.id, 'participantId', ep.id, 'amount', e.split_amount)
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		WHERE ep.id = $1 AND e.circle_id IS NOT NULL
+	`, participantID, action)
+	if err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// CircleAuditFilter は監査ログの絞り込み条件（ゼロ値の項目は絞り込まない）
+type CircleAuditFilter struct {
+	CircleID int
+	Action   string
+	ActorID  string
+	TargetID string
+	From     *time.Time
+	To       *time.Time
+	BeforeID int // このIDより古いログのみ（ページング用）
+	Limit    int
+}
+
+// GetCircleAuditLogs は監査ログを新しい順に取得する
+// 名前はサークル内の表示名を優先する
+func GetCircleAuditLogs(f CircleAuditFilter) ([]CircleAuditLog, error) {
+	rows, err := db.Query(`
+		SELECT a.id, a.circle_id, a.actor_id, COALESCE(NULLIF(ac.display_name, ''), au.name, ''),
+		       a.target_id, COALESCE(NULLIF(tc.display_name, ''), tu.name, ''),
+		       a.action, a.reason, a.details, a.created_at
+		FROM circle_audit_logs a
+		LEFT JOIN users au ON a.actor_id = au.user_id
+		LEFT JOIN user_circles ac ON a.actor_id = ac.user_id AND ac.circle_id = a.circle_id
+		LEFT JOIN users tu ON a.target_id = tu.user_id
+		LEFT JOIN user_circles tc ON a.target_id = tc.user_id AND tc.circle_id = a.circle_id
+		WHERE a.circle_id = $1
+		  AND ($2::TEXT = '' OR a.action = $2)
+		  AND ($3::TEXT = '' OR a.actor_id = $3)
+		  AND ($4::TEXT = '' OR a.target_id = $4)
+		  AND ($5::date IS NULL OR a.created_at >= $5::date)
+		  AND ($6::date IS NULL OR a.created_at < $6::date + 1)
+		  AND ($7::INTEGER = 0 OR a.id < $7)
+		ORDER BY a.id DESC
+		LIMIT $8
+	`, f.CircleID, f.Action, f.ActorID, f.TargetID, f.From, f.To, f.BeforeID, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []CircleAuditLog{}
+	for rows.Next() {
+		var entry CircleAuditLog
+		var targetID sql.NullString
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.CircleID, &entry.ActorID, &entry.ActorName,
+			&targetID, &entry.TargetName, &entry.Action, &entry.Reason, &details, &entry.CreatedAt); err != nil {
+			log.Printf("監査ログスキャンエラー: %v", err)
+			continue
+		}
+		if targetID.Valid {
+			entry.TargetID = &targetID.String
+		}
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			log.Printf("監査ログ詳細の解析エラー: %v", err)
+		}
+		logs = append(logs, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
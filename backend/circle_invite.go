@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ========== サークル招待（LIFF・Bot共通） ==========
+
+// inviteCodePrefix はBotで招待コードを送信する際のプレフィックス
+const inviteCodePrefix = "招待コード:"
+
+var (
+	// errInvalidInvite は招待コードが存在しない・期限切れ・上限到達・無効化済みの場合のエラー
+	errInvalidInvite = errors.New("invalid or expired invite code")
+
+	// errAlreadyCircleMember は既にサークルに参加している場合のエラー
+	errAlreadyCircleMember = errors.New("already a member of this circle")
+)
+
+// normalizeInviteCode は入力された招待コードを比較用に正規化する
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// inviteURL は招待コードのLIFFディープリンクを返す
+func inviteURL(code string) string {
+	return strings.TrimRight(os.Getenv("LIFF_URL"), "/") + "/circles?invite=" + url.QueryEscape(code)
+}
+
+// redeemCircleInvite は招待コードを使ってサークルに参加する
+// 主サークルが未設定の場合は参加したサークルを主サークルにする
+// 招待コードは管理者が発行したものなので、非公開サークルでも承認なしで参加できる
+func redeemCircleInvite(userID, code string) (*Circle, error) {
+	code = normalizeInviteCode(code)
+	if code == "" {
+		return nil, errInvalidInvite
+	}
+
+	invite, err := GetCircleInviteByCode(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	if invite == nil || !invite.Usable {
+		return nil, errInvalidInvite
+	}
+
+	// 既存メンバーが使用回数を消費しないよう先に確認
+	membership, err := GetUserCircleStatus(userID, invite.CircleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if membership != nil {
+		switch membership.Status {
+		case MembershipActive:
+			return nil, errAlreadyCircleMember
+		case MembershipSuspended:
+			return nil, errMembershipSuspended
+		}
+	}
+
+	if err := JoinCircleWithInvite(code, userID, invite.CircleID); err != nil {
+		return nil, err
+	}
+
+	circle, err := GetCircleByID(invite.CircleID)
+	if err != nil || circle == nil {
+		return nil, fmt.Errorf("failed to get circle: %v", err)
+	}
+
+	if primary, err := GetPrimaryCircle(userID); err == nil && primary == nil {
+		if err := SetPrimaryCircle(userID, circle.ID); err != nil {
+			log.Printf("主サークル設定エラー: %v", err)
+		}
+	}
+
+	log.Printf("[サークル] 招待コードで参加: user=%s, circle=%d, code=%s", userID, circle.ID, code)
+	return circle, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+)
+
+// ========== サークル招待コードリポジトリ ==========
+
+const (
+	// inviteCodeLength は招待コードの文字数
+	inviteCodeLength = 8
+
+	// inviteCodeAlphabet は招待コードに使う文字（見間違えやすい0/O/1/I/Lは除外）
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// circleInviteColumns は招待コードのSELECT句（scanCircleInviteと対応）
+const circleInviteColumns = `
+	id, circle_id, code, created_by, expires_at, max_uses, use_count,
+	revoked_at IS NOT NULL,
+	revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		AND (max_uses IS NULL OR use_count < max_uses),
+	created_at`
+
+// rowScanner はsql.Rowとsql.Rowsの共通インターフェース
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCircleInvite は招待コードの行を読み取る
+func scanCircleInvite(row rowScanner) (*CircleInvite, error) {
+	var invite CircleInvite
+	var expiresAt sql.NullTime
+	var maxUses sql.NullInt64
+
+	err := row.Scan(&invite.ID, &invite.CircleID, &invite.Code, &invite.CreatedBy, &expiresAt, &maxUses,
+		&invite.UseCount, &invite.Revoked, &invite.Usable, &invite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		invite.MaxUses = &n
+	}
+	return &invite, nil
+}
+
+// generateInviteCode はランダムな招待コードを生成する
+func generateInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// CreateCircleInvite は招待コードを発行する
+// expiresInが0なら無期限、maxUsesがnilなら回数無制限
+func CreateCircleInvite(circleID int, createdBy string, expiresIn time.Duration, maxUses *int) (*CircleInvite, error) {
+	var expiresInSeconds sql.NullInt64
+	if expiresIn > 0 {
+		expiresInSeconds = sql.NullInt64{Int64: int64(expiresIn.Seconds()), Valid: true}
+	}
+
+	// コードの衝突時は作り直す
+	for attempt := 0; attempt < 3; attempt++ {
+		code, err := generateInviteCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate invite code: %w", err)
+		}
+
+		row := db.QueryRow(`
+			INSERT INTO circle_invites (circle_id, code, created_by, expires_at, max_uses)
+			VALUES ($1, $2, $3, NOW() + ($4 * INTERVAL '1 second'), $5)
+			ON CONFLICT (code) DO NOTHING
+			RETURNING `+circleInviteColumns,
+			circleID, code, createdBy, expiresInSeconds, maxUses)
+
+		invite, err := scanCircleInvite(row)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create invite: %w", err)
+		}
+
+		log.Printf("[サークル] 招待コード発行: circle=%d, code=%s, by=%s", circleID, code, createdBy)
+		return invite, nil
+	}
+
+	return nil, fmt.Errorf("failed to create invite: code collision")
+}
+
+// GetCircleInviteByCode はコードから招待を取得する
+func GetCircleInviteByCode(code string) (*CircleInvite, error) {
+	invite, err := scanCircleInvite(db.QueryRow(`
+		SELECT `+circleInviteColumns+`
+		FROM circle_invites
+		WHERE code = $1
+	`, code))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// GetCircleInvites はサークルの招待コード一覧を取得する
+func GetCircleInvites(circleID int) ([]CircleInvite, error) {
+	rows, err := db.Query(`
+		SELECT `+circleInviteColumns+`
+		FROM circle_invites
+		WHERE circle_id = $1
+		ORDER BY created_at DESC
+	`, circleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []CircleInvite
+	for rows.Next() {
+		invite, err := scanCircleInvite(rows)
+		if err != nil {
+			log.Printf("スキャンエラー: %v", err)
+			continue
+		}
+		invites = append(invites, *invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// RevokeCircleInvite は招待コードを無効化する
+func RevokeCircleInvite(circleID, inviteID int) error {
+	result, err := db.Exec(`
+		UPDATE circle_invites SET revoked_at = NOW()
+		WHERE id = $1 AND circle_id = $2 AND revoked_at IS NULL
+	`, inviteID, circleID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("invite not found")
+	}
+
+	log.Printf("[サークル] 招待コード無効化: circle=%d, invite=%d", circleID, inviteID)
+	return nil
+}
+
+// JoinCircleWithInvite は招待コードの使用回数を1つ増やし、ユーザーをサークルに参加させる
+// 参加に失敗した場合は使用回数も戻るよう、同じトランザクションで行う
+// 期限切れ・回数超過・無効化済みの場合はerrInvalidInviteを返す
+func JoinCircleWithInvite(code, userID string, circleID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE circle_invites SET use_count = use_count + 1
+		WHERE code = $1 AND circle_id = $2
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND (max_uses IS NULL OR use_count < max_uses)
+	`, code, circleID)
+	if err != nil {
+		return fmt.Errorf("failed to consume invite: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errInvalidInvite
+	}
+
+	if err := joinCircleTx(tx, userID, circleID, userID, joinSourceInvite); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ========== サークル参加申請（LIFF・Bot共通） ==========
+
+var (
+	// errJoinRequestNotFound は参加申請が存在しない場合のエラー
+	errJoinRequestNotFound = errors.New("join request not found")
+
+	// errNotCircleManager はオーナー・会計以外が管理操作をしようとした場合のエラー
+	errNotCircleManager = errors.New("not a manager of this circle")
+)
+
+// isValidCircleVisibility は公開範囲の値が正しいか判定する
+func isValidCircleVisibility(visibility string) bool {
+	switch visibility {
+	case CircleVisibilityPublic, CircleVisibilityUnlisted, CircleVisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// joinCircleOrRequest はサークルに参加する
+// 非公開サークルの場合は参加申請を作成して管理者に通知し、requestedにtrueを返す
+func joinCircleOrRequest(userID string, circle *Circle) (requested bool, err error) {
+	settings, err := GetCircleSettings(circle.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get circle settings: %w", err)
+	}
+
+	if settings == nil || settings.Visibility != CircleVisibilityPrivate {
+		if err := JoinCircle(userID, circle.ID, userID, joinSourceDirect); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	membership, err := GetUserCircleStatus(userID, circle.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if membership != nil {
+		switch membership.Status {
+		case MembershipActive:
+			return false, errAlreadyCircleMember
+		case MembershipSuspended:
+			return false, errMembershipSuspended
+		}
+	}
+
+	request, created, err := CreateCircleJoinRequest(circle.ID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	// 申請済みの場合は再通知しない
+	if created {
+		go notifyManagersOfJoinRequest(circle, request)
+	}
+	return true, nil
+}
+
+// decideJoinRequest は参加申請を承認・却下して申請者に通知する
+// 申請中でなかった（処理済みなど）場合はdoneにfalseを返す
+func decideJoinRequest(requestID int, actorUserID string, approve bool) (request *CircleJoinRequest, done bool, err error) {
+	request, err = GetCircleJoinRequest(requestID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get join request: %w", err)
+	}
+	if request == nil {
+		return nil, false, errJoinRequestNotFound
+	}
+
+	role, err := GetCircleRole(actorUserID, request.CircleID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get role: %w", err)
+	}
+	if !isCircleManager(role) {
+		return nil, false, errNotCircleManager
+	}
+
+	status := JoinRequestDenied
+	if approve {
+		status = JoinRequestApproved
+	}
+
+	done, err = DecideCircleJoinRequest(requestID, status, actorUserID)
+	if err != nil || !done {
+		return request, done, err
+	}
+
+	if approve {
+		if primary, err := GetPrimaryCircle(request.UserID); err == nil && primary == nil {
+			if err := SetPrimaryCircle(request.UserID, request.CircleID); err != nil {
+				log.Printf("主サークル設定エラー: %v", err)
+			}
+		}
+	}
+
+	// 申請者に通知（非同期）
+	go func() {
+		circle, err := GetCircleByID(request.CircleID)
+		if err != nil || circle == nil {
+			log.Printf("サークル取得エラー: %v", err)
+			return
+		}
+
+		var text string
+		if approve {
+			text = fmt.Sprintf("🎉 「%s」への参加申請が承認されました！", circle.Name)
+		} else {
+			text = fmt.Sprintf("「%s」への参加申請は承認されませんでした。", circle.Name)
+		}
+		if err := PushMessage(request.UserID, text); err != nil {
+			log.Printf("参加申請結果の通知エラー: %v", err)
+		}
+	}()
+
+	request.Status = status
+	return request, true, nil
+}
+
+// notifyManagersOfJoinRequest はサークルの管理者に参加申請を承認ボタン付きで通知する
+func notifyManagersOfJoinRequest(circle *Circle, request *CircleJoinRequest) {
+	managerIDs, err := GetCircleManagerIDs(circle.ID)
+	if err != nil {
+		log.Printf("管理者取得エラー: %v", err)
+		return
+	}
+
+	name := request.UserName
+	if name == "" {
+		name = "ゲスト"
+	}
+	text := fmt.Sprintf("🙋 参加申請\n\n%sさんが「%s」への参加を申請しました。", name, circle.Name)
+
+	content := ButtonsTemplateContent{
+		AltText: text,
+		Text:    truncateText(text, templateTextMaxLen),
+		Actions: []ActionObject{
+			{
+				Type:        "postback",
+				Label:       "✅ 承認する",
+				Data:        fmt.Sprintf("action=%s&request=%d", postbackApproveJoin, request.ID),
+				DisplayText: "承認する",
+			},
+			{
+				Type:        "postback",
+				Label:       "❌ 却下する",
+				Data:        fmt.Sprintf("action=%s&request=%d", postbackDenyJoin, request.ID),
+				DisplayText: "却下する",
+			},
+		},
+	}
+
+	for _, managerID := range managerIDs {
+		if err := SendMessage(PushDelivery{managerID}, content); err != nil {
+			log.Printf("参加申請通知エラー: %v", err)
+			// ボタン付きで送れなかった場合はテキストで通知
+			PushMessage(managerID, text+"\n\nサークル管理画面から確認してください。")
+		}
+	}
+}
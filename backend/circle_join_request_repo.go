@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ========== サークル参加申請リポジトリ ==========
+
+// circleJoinRequestColumns は参加申請のSELECT句（scanCircleJoinRequestと対応）
+const circleJoinRequestColumns = `
+	r.id, r.circle_id, r.user_id, COALESCE(u.name, ''), r.status, r.decided_by, r.decided_at, r.created_at`
+
+// scanCircleJoinRequest は参加申請の行を読み取る
+func scanCircleJoinRequest(row rowScanner) (*CircleJoinRequest, error) {
+	var req CircleJoinRequest
+	var decidedBy sql.NullString
+	var decidedAt sql.NullTime
+
+	err := row.Scan(&req.ID, &req.CircleID, &req.UserID, &req.UserName, &req.Status, &decidedBy, &decidedAt, &req.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if decidedBy.Valid {
+		req.DecidedBy = &decidedBy.String
+	}
+	if decidedAt.Valid {
+		req.DecidedAt = &decidedAt.Time
+	}
+	return &req, nil
+}
+
+// CreateCircleJoinRequest は参加申請を作成する
+// 既に申請中の場合は既存の申請を返し、createdはfalseになる
+func CreateCircleJoinRequest(circleID int, userID string) (request *CircleJoinRequest, created bool, err error) {
+	var id int
+	err = db.QueryRow(`
+		INSERT INTO circle_join_requests (circle_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (circle_id, user_id) WHERE status = 'pending' DO NOTHING
+		RETURNING id
+	`, circleID, userID).Scan(&id)
+
+	if err == nil {
+		created = true
+		log.Printf("[サークル] 参加申請: user=%s, circle=%d", userID, circleID)
+	} else if err == sql.ErrNoRows {
+		// 申請中のものがある
+		err = db.QueryRow(`
+			SELECT id FROM circle_join_requests
+			WHERE circle_id = $1 AND user_id = $2 AND status = 'pending'
+		`, circleID, userID).Scan(&id)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get join request: %w", err)
+		}
+	} else {
+		return nil, false, fmt.Errorf("failed to create join request: %w", err)
+	}
+
+	request, err = GetCircleJoinRequest(id)
+	if err != nil {
+		return nil, false, err
+	}
+	return request, created, nil
+}
+
+// GetCircleJoinRequest はIDで参加申請を取得する
+func GetCircleJoinRequest(requestID int) (*CircleJoinRequest, error) {
+	req, err := scanCircleJoinRequest(db.QueryRow(`
+		SELECT `+circleJoinRequestColumns+`
+		FROM circle_join_requests r
+		LEFT JOIN users u ON r.user_id = u.user_id
+		WHERE r.id = $1
+	`, requestID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetPendingCircleJoinRequests はサークルの申請中の参加申請を取得する
+func GetPendingCircleJoinRequests(circleID int) ([]CircleJoinRequest, error) {
+	rows, err := db.Query(`
+		SELECT `+circleJoinRequestColumns+`
+		FROM circle_join_requests r
+		LEFT JOIN users u ON r.user_id = u.user_id
+		WHERE r.circle_id = $1 AND r.status = 'pending'
+		ORDER BY r.created_at
+	`, circleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []CircleJoinRequest
+	for rows.Next() {
+		req, err := scanCircleJoinRequest(rows)
+		if err != nil {
+			log.Printf("スキャンエラー: %v", err)
+			continue
+		}
+		requests = append(requests, *req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// DecideCircleJoinRequest は申請中の参加申請を承認・却下し、監査ログに記録する
+// 承認の場合は申請者をサークルに参加させる
+// 申請中でない場合は何もせずfalseを返す
+func DecideCircleJoinRequest(requestID int, status, decidedBy string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var circleID int
+	var userID string
+	err = tx.QueryRow(`
+		UPDATE circle_join_requests
+		SET status = $2, decided_by = $3, decided_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING circle_id, user_id
+	`, requestID, status, decidedBy).Scan(&circleID, &userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to decide join request: %w", err)
+	}
+
+	action := AuditJoinRequestDenied
+	if status == JoinRequestApproved {
+		action = AuditJoinRequestApproved
+	}
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  decidedBy,
+		TargetID: &userID,
+		Action:   action,
+		Details:  map[string]interface{}{"requestId": requestID},
+	}); err != nil {
+		return false, err
+	}
+
+	// 承認と参加は同じトランザクションで行い、参加に失敗したら申請中に戻す
+	// 申請後に招待コードなどで参加済みの場合はそのまま承認扱い
+	if status == JoinRequestApproved {
+		if err := joinCircleTx(tx, userID, circleID, decidedBy, joinSourceRequest); err != nil && !errors.Is(err, errAlreadyCircleMember) {
+			return false, fmt.Errorf("failed to join circle: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[サークル] 参加申請%s: request=%d, by=%s", status, requestID, decidedBy)
+	return true, nil
+}
+
+// GetCircleManagerIDs はサークルの管理者（オーナー・会計）のユーザーIDを取得する
+func GetCircleManagerIDs(circleID int) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT user_id FROM user_circles
+		WHERE circle_id = $1 AND status = 'active' AND role IN ('owner', 'treasurer')
+	`, circleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			log.Printf("スキャンエラー: %v", err)
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
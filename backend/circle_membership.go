@@ -0,0 +1,34 @@
+package main
+
+import "errors"
+
+// ========== 在籍状態（OB・OG、休止） ==========
+
+// errMembershipSuspended は休止中のメンバーが自分で再参加しようとした場合のエラー
+var errMembershipSuspended = errors.New("membership is suspended")
+
+// サークルへの参加経路（監査ログに記録する）
+const (
+	joinSourceDirect  = "direct"       // 検索・サークル名で参加
+	joinSourceInvite  = "invite"       // 招待コード
+	joinSourceRequest = "join_request" // 参加申請の承認
+	joinSourceCreated = "created"      // サークルを作成
+)
+
+// suspendedMembershipMessage は休止中のメンバーが参加しようとした場合のBotの返信
+const suspendedMembershipMessage = "このサークルでは休止中のため参加できません。\nサークルのオーナーに連絡してください。"
+
+// membershipTransitions は変更先の状態ごとに、変更できる元の状態
+// 退出・退会したメンバーは対象外（再参加の手続きを通す）
+// アーカイブ（MembershipArchived）はサークルの削除でのみ設定し、一括変更の変更先にも変更元にもしない
+var membershipTransitions = map[string][]string{
+	MembershipActive:    {MembershipSuspended, MembershipAlumni},
+	MembershipSuspended: {MembershipActive},
+	MembershipAlumni:    {MembershipActive, MembershipSuspended},
+}
+
+// isValidMembershipTransitionTarget は一括変更の変更先として指定できる状態か判定する
+func isValidMembershipTransitionTarget(status string) bool {
+	_, ok := membershipTransitions[status]
+	return ok
+}
@@ -0,0 +1,73 @@
+package main
+
+import "errors"
+
+// ========== サークル権限 ==========
+
+var (
+	// errNoCircleOwnerLeft は変更で在籍中のオーナーがいなくなる場合のエラー
+	errNoCircleOwnerLeft = errors.New("circle must keep at least one active owner")
+
+	// errNotCircleMember は対象のユーザーがサークルに在籍していない場合のエラー
+	errNotCircleMember = errors.New("not a member of this circle")
+)
+
+// circleRoleRank はロールの強さ（大きいほど権限が強い）
+var circleRoleRank = map[string]int{
+	CircleRoleMember:    1,
+	CircleRoleTreasurer: 2,
+	CircleRoleOwner:     3,
+}
+
+// isValidCircleRole はロール名が正しいか判定する
+func isValidCircleRole(role string) bool {
+	_, ok := circleRoleRank[role]
+	return ok
+}
+
+// isCircleManager はオーナーまたは会計か判定する
+func isCircleManager(role string) bool {
+	return role == CircleRoleOwner || role == CircleRoleTreasurer
+}
+
+// canRemoveMember はメンバーを外せるか判定する
+// オーナーと会計のみ、自分より弱いロールのメンバーを外せる
+func canRemoveMember(actorRole, targetRole string) bool {
+	return isCircleManager(actorRole) && circleRoleRank[actorRole] > circleRoleRank[targetRole]
+}
+
+// canChangeRoles はロールを変更できるか判定する（オーナーのみ）
+func canChangeRoles(role string) bool {
+	return role == CircleRoleOwner
+}
+
+// canEditCircleSettings はサークル設定を変更できるか判定する（オーナーのみ）
+func canEditCircleSettings(role string) bool {
+	return role == CircleRoleOwner
+}
+
+// canCreateCircleEvent はサークルのイベントを作成できるか判定する
+func canCreateCircleEvent(role string, settings *CircleSettings) bool {
+	if role == "" {
+		return false
+	}
+	if settings != nil && settings.EventCreation == EventCreationManagers {
+		return isCircleManager(role)
+	}
+	return true
+}
+
+// checkCircleEventPermission はユーザーがサークルのイベントを作成できるか確認する
+func checkCircleEventPermission(userID string, circleID int) (bool, error) {
+	role, err := GetCircleRole(userID, circleID)
+	if err != nil {
+		return false, err
+	}
+
+	settings, err := GetCircleSettings(circleID)
+	if err != nil {
+		return false, err
+	}
+
+	return canCreateCircleEvent(role, settings), nil
+}
@@ -5,35 +5,64 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // ========== サークル操作 ==========
 
+// circleColumns はサークルのSELECT句（scanCircleと対応、circlesの別名はc）
+// アイコン本体は重いため、更新日時だけを取得してURLを組み立てる
+const circleColumns = `
+	c.id, c.name, c.created_by, c.description,
+	COALESCE(EXTRACT(EPOCH FROM c.icon_updated_at)::BIGINT, 0), c.created_at`
+
+// scanCircle はサークルの行を読み取る
+// extraにはcircleColumnsの後に続く列の格納先を渡す
+func scanCircle(row rowScanner, extra ...interface{}) (*Circle, error) {
+	var circle Circle
+	var iconVersion int64
+
+	dest := append([]interface{}{&circle.ID, &circle.Name, &circle.CreatedBy, &circle.Description,
+		&iconVersion, &circle.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if iconVersion > 0 {
+		circle.IconURL = circleIconURL(circle.ID, iconVersion)
+	}
+	return &circle, nil
+}
+
+// circleIconURL はサークルアイコンのURLを返す（更新時にキャッシュを無効化するためバージョン付き）
+func circleIconURL(circleID int, version int64) string {
+	return fmt.Sprintf("/api/circles/%d/icon?v=%d", circleID, version)
+}
+
 // CreateCircle はサークルを作成する
 func CreateCircle(name, createdBy string) (*Circle, error) {
-	var circle Circle
-	err := db.QueryRow(`
-		INSERT INTO circles (name, created_by)
+	circle, err := scanCircle(db.QueryRow(`
+		INSERT INTO circles AS c (name, created_by)
 		VALUES ($1, $2)
-		RETURNING id, name, created_by, created_at
-	`, name, createdBy).Scan(&circle.ID, &circle.Name, &circle.CreatedBy, &circle.CreatedAt)
+		RETURNING `+circleColumns,
+		name, createdBy))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create circle: %w", err)
 	}
 
 	log.Printf("[サークル] 作成: %s (ID: %d, 作成者: %s)", name, circle.ID, createdBy)
-	return &circle, nil
+	return circle, nil
 }
 
-// GetCircleByID はIDでサークルを取得する
+// GetCircleByID はIDでサークルを取得する（削除済みは除く）
 func GetCircleByID(circleID int) (*Circle, error) {
-	var circle Circle
-	err := db.QueryRow(`
-		SELECT id, name, created_by, created_at
-		FROM circles
-		WHERE id = $1
-	`, circleID).Scan(&circle.ID, &circle.Name, &circle.CreatedBy, &circle.CreatedAt)
+	circle, err := scanCircle(db.QueryRow(`
+		SELECT `+circleColumns+`
+		FROM circles c
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+	`, circleID))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -41,17 +70,16 @@ func GetCircleByID(circleID int) (*Circle, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &circle, nil
+	return circle, nil
 }
 
-// GetCircleByName は名前でサークルを取得する
+// GetCircleByName は名前でサークルを取得する（削除済みは除く）
 func GetCircleByName(name string) (*Circle, error) {
-	var circle Circle
-	err := db.QueryRow(`
-		SELECT id, name, created_by, created_at
-		FROM circles
-		WHERE name = $1
-	`, name).Scan(&circle.ID, &circle.Name, &circle.CreatedBy, &circle.CreatedAt)
+	circle, err := scanCircle(db.QueryRow(`
+		SELECT `+circleColumns+`
+		FROM circles c
+		WHERE c.name = $1 AND c.deleted_at IS NULL
+	`, name))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -59,16 +87,17 @@ func GetCircleByName(name string) (*Circle, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &circle, nil
+	return circle, nil
 }
 
 // SearchCirclesByName はサークル名で部分一致検索する
+// 公開サークルのみが対象（限定公開・非公開サークルは表示しない）
 func SearchCirclesByName(query string) ([]Circle, error) {
 	rows, err := db.Query(`
-		SELECT id, name, created_by, created_at
-		FROM circles
-		WHERE name ILIKE $1
-		ORDER BY name
+		SELECT `+circleColumns+`
+		FROM circles c
+		WHERE c.name ILIKE $1 AND c.visibility = 'public' AND c.deleted_at IS NULL
+		ORDER BY c.name
 		LIMIT 20
 	`, "%"+query+"%")
 
@@ -79,110 +108,458 @@ func SearchCirclesByName(query string) ([]Circle, error) {
 
 	var circles []Circle
 	for rows.Next() {
-		var c Circle
-		if err := rows.Scan(&c.ID, &c.Name, &c.CreatedBy, &c.CreatedAt); err != nil {
+		c, err := scanCircle(rows)
+		if err != nil {
 			log.Printf("スキャンエラー: %v", err)
 			continue
 		}
-		circles = append(circles, c)
+		circles = append(circles, *c)
 	}
 	return circles, nil
 }
 
 // ========== ユーザーサークル関係操作 ==========
 
-// JoinCircle はユーザーをサークルに参加させる
-func JoinCircle(userID string, circleID int) error {
+// JoinCircle はユーザーをサークルに参加させ、監査ログに記録する
+// actorIDは操作したユーザー（参加申請の承認では管理者）、sourceは参加経路
+func JoinCircle(userID string, circleID int, actorID, source string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := joinCircleTx(tx, userID, circleID, actorID, source); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// joinCircleTx はトランザクション内でユーザーをサークルに参加させ、監査ログに記録する
+// 招待コードの消費や参加申請の承認と一緒にコミットするために使う
+func joinCircleTx(tx *sql.Tx, userID string, circleID int, actorID, source string) error {
 	// 既に参加しているか確認
 	var existingID int
 	var status string
-	err := db.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT id, status FROM user_circles
 		WHERE user_id = $1 AND circle_id = $2
+		FOR UPDATE
 	`, userID, circleID).Scan(&existingID, &status)
 
-	if err == nil {
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	rejoin := err == nil
+
+	if rejoin {
 		// 既存レコードがある場合
-		if status == "active" {
-			return fmt.Errorf("already a member of this circle")
+		if status == MembershipActive {
+			return errAlreadyCircleMember
+		}
+		// 休止中のメンバーはオーナーが戻すまで再参加できない
+		if status == MembershipSuspended {
+			return errMembershipSuspended
 		}
+	}
+
+	role, err := initialCircleRole(tx, userID, circleID)
+	if err != nil {
+		return err
+	}
+
+	if rejoin {
 		// 以前退出/退会していた場合は再参加
-		_, err = db.Exec(`
+		_, err = tx.Exec(`
 			UPDATE user_circles
-			SET status = 'active', joined_at = NOW(), left_at = NULL
+			SET status = 'active', role = $2, joined_at = NOW(), left_at = NULL
 			WHERE id = $1
-		`, existingID)
+		`, existingID, role)
 		if err != nil {
 			return fmt.Errorf("failed to rejoin circle: %w", err)
 		}
-		log.Printf("[サークル] 再参加: user=%s, circle=%d", userID, circleID)
-		return nil
+	} else {
+		// 新規参加
+		_, err = tx.Exec(`
+			INSERT INTO user_circles (user_id, circle_id, status, role)
+			VALUES ($1, $2, 'active', $3)
+		`, userID, circleID, role)
+		if err != nil {
+			return fmt.Errorf("failed to join circle: %w", err)
+		}
 	}
 
-	if err != sql.ErrNoRows {
+	details := map[string]interface{}{"source": source, "role": role}
+	if rejoin {
+		details["previousStatus"] = status
+	}
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  actorID,
+		TargetID: &userID,
+		Action:   AuditMemberJoined,
+		Details:  details,
+	}); err != nil {
 		return err
 	}
 
-	// 新規参加
-	_, err = db.Exec(`
-		INSERT INTO user_circles (user_id, circle_id, status)
-		VALUES ($1, $2, 'active')
-	`, userID, circleID)
+	if rejoin {
+		log.Printf("[サークル] 再参加: user=%s, circle=%d", userID, circleID)
+	} else {
+		log.Printf("[サークル] 参加: user=%s, circle=%d", userID, circleID)
+	}
+	return nil
+}
 
+// initialCircleRole は参加時のロールを決める
+// サークル作成者がオーナー不在のサークルに参加する場合はオーナー、それ以外は一般メンバー
+func initialCircleRole(tx *sql.Tx, userID string, circleID int) (string, error) {
+	var becomesOwner bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM circles WHERE id = $2 AND created_by = $1
+		) AND NOT EXISTS(
+			SELECT 1 FROM user_circles
+			WHERE circle_id = $2 AND role = 'owner' AND status = 'active'
+		)
+	`, userID, circleID).Scan(&becomesOwner)
 	if err != nil {
-		return fmt.Errorf("failed to join circle: %w", err)
+		return "", fmt.Errorf("failed to determine circle role: %w", err)
 	}
 
-	log.Printf("[サークル] 参加: user=%s, circle=%d", userID, circleID)
-	return nil
+	if becomesOwner {
+		return CircleRoleOwner, nil
+	}
+	return CircleRoleMember, nil
 }
 
 // LeaveCircle はユーザーがサークルから退出する（自分で抜ける）
+// 最後のオーナーが他のメンバーを残して抜ける場合はerrNoCircleOwnerLeftを返す
 func LeaveCircle(userID string, circleID int) error {
-	result, err := db.Exec(`
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockCircle(tx, circleID); err != nil {
+		return err
+	}
+
+	var role string
+	err = tx.QueryRow(`
 		UPDATE user_circles
 		SET status = 'left', left_at = NOW()
 		WHERE user_id = $1 AND circle_id = $2 AND status = 'active'
-	`, userID, circleID)
+		RETURNING role
+	`, userID, circleID).Scan(&role)
 
+	if err == sql.ErrNoRows {
+		return errNotCircleMember
+	}
 	if err != nil {
 		return fmt.Errorf("failed to leave circle: %w", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("not a member of this circle")
+	// 最後のオーナーは、他のメンバーが残っている間は抜けられない
+	if role == CircleRoleOwner {
+		var owners, members int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FILTER (WHERE role = 'owner'), COUNT(*)
+			FROM user_circles
+			WHERE circle_id = $1 AND status = 'active'
+		`, circleID).Scan(&owners, &members)
+		if err != nil {
+			return fmt.Errorf("failed to count circle members: %w", err)
+		}
+		if owners == 0 && members > 0 {
+			return errNoCircleOwnerLeft
+		}
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  userID,
+		TargetID: &userID,
+		Action:   AuditMemberLeft,
+		Details:  map[string]interface{}{"role": role},
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	log.Printf("[サークル] 退出: user=%s, circle=%d", userID, circleID)
 	return nil
 }
 
+// MemberRemoval はメンバーを退会させる内容
+type MemberRemoval struct {
+	CircleID         int
+	TargetUserID     string
+	ActorID          string
+	Reason           string
+	DebtPolicy       string // DebtPolicyKeep, DebtPolicyCancel, DebtPolicyTransfer
+	TransferToUserID string // DebtPolicyTransferの場合の付け替え先
+}
+
+// OpenDebt は退会したメンバーの未完了イベントでの未承認の支払い
+type OpenDebt struct {
+	ParticipantID int    `json:"participantId"`
+	EventID       int    `json:"eventId"`
+	EventName     string `json:"eventName"`
+	OrganizerID   string `json:"organizerId"`
+	Amount        int    `json:"amount"`
+	Reported      bool   `json:"reported"` // 支払い報告済み（承認待ち）
+}
+
+// MemberRemovalResult は退会させたメンバーの未払い分の処理結果
+type MemberRemovalResult struct {
+	Kept        []OpenDebt `json:"kept"`
+	Cancelled   []OpenDebt `json:"cancelled"`
+	Transferred []OpenDebt `json:"transferred"`
+}
+
 // RemoveFromCircle はユーザーをサークルから退会させる（他人を外す）
-func RemoveFromCircle(targetUserID string, circleID int) error {
-	result, err := db.Exec(`
+// 未完了のイベントの未払い分はDebtPolicyに従って処理し、内容を監査ログに記録する
+// 支払い報告済み（承認待ち）の分は会計者が確認するため、どの方針でもそのまま残す
+func RemoveFromCircle(r *MemberRemoval) (*MemberRemovalResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.QueryRow(`
 		UPDATE user_circles
 		SET status = 'removed', left_at = NOW()
 		WHERE user_id = $1 AND circle_id = $2 AND status = 'active'
-	`, targetUserID, circleID)
+		RETURNING role
+	`, r.TargetUserID, r.CircleID).Scan(&role)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user is not a member of this circle")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to remove from circle: %w", err)
+		return nil, fmt.Errorf("failed to remove from circle: %w", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("user is not a member of this circle")
+	// 主サークルだった場合は他の在籍中のサークルに切り替える
+	if err := reassignPrimaryCircles(tx, []string{r.TargetUserID}, r.CircleID); err != nil {
+		return nil, err
+	}
+
+	// 付け替え先が既に参加者か会計者のイベントは付け替えられない
+	rows, err := tx.Query(`
+		SELECT ep.id, e.id, e.event_name, e.organizer_id, e.split_amount, ep.paid,
+		       e.organizer_id = $3 OR EXISTS(
+		           SELECT 1 FROM event_participants o WHERE o.event_id = e.id AND o.user_id = $3
+		       )
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		WHERE ep.user_id = $1 AND e.circle_id = $2 AND e.status IN ('selecting', 'confirmed')
+		  AND ep.approved_at IS NULL
+		ORDER BY e.id
+		FOR UPDATE OF ep
+	`, r.TargetUserID, r.CircleID, r.TransferToUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open debts: %w", err)
+	}
+
+	result := &MemberRemovalResult{Kept: []OpenDebt{}, Cancelled: []OpenDebt{}, Transferred: []OpenDebt{}}
+	for rows.Next() {
+		var d OpenDebt
+		var transferConflict bool
+		if err := rows.Scan(&d.ParticipantID, &d.EventID, &d.EventName, &d.OrganizerID, &d.Amount, &d.Reported, &transferConflict); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch {
+		case d.Reported:
+			result.Kept = append(result.Kept, d)
+		case r.DebtPolicy == DebtPolicyCancel:
+			result.Cancelled = append(result.Cancelled, d)
+		case r.DebtPolicy == DebtPolicyTransfer && !transferConflict:
+			result.Transferred = append(result.Transferred, d)
+		default:
+			result.Kept = append(result.Kept, d)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, d := range result.Cancelled {
+		if _, err := tx.Exec(`DELETE FROM event_participants WHERE id = $1`, d.ParticipantID); err != nil {
+			return nil, fmt.Errorf("failed to cancel debt: %w", err)
+		}
+	}
+
+	for _, d := range result.Transferred {
+		_, err := tx.Exec(`
+			UPDATE event_participants ep
+			SET user_id = u.user_id, user_name = `+circleMemberName+`
+			FROM users u
+			JOIN user_circles uc ON uc.user_id = u.user_id AND uc.circle_id = $3
+			WHERE ep.id = $1 AND u.user_id = $2
+		`, d.ParticipantID, r.TransferToUserID, r.CircleID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transfer debt: %w", err)
+		}
+	}
+
+	details := map[string]interface{}{
+		"role":        role,
+		"debtPolicy":  r.DebtPolicy,
+		"kept":        result.Kept,
+		"cancelled":   result.Cancelled,
+		"transferred": result.Transferred,
+	}
+	if r.DebtPolicy == DebtPolicyTransfer {
+		details["transferTo"] = r.TransferToUserID
+	}
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: r.CircleID,
+		ActorID:  r.ActorID,
+		TargetID: &r.TargetUserID,
+		Action:   AuditMemberRemoved,
+		Reason:   r.Reason,
+		Details:  details,
+	}); err != nil {
+		return nil, err
 	}
 
-	log.Printf("[サークル] 退会: user=%s, circle=%d", targetUserID, circleID)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[サークル] 退会: user=%s, circle=%d, debts=%s (kept=%d, cancelled=%d, transferred=%d)",
+		r.TargetUserID, r.CircleID, r.DebtPolicy, len(result.Kept), len(result.Cancelled), len(result.Transferred))
+	return result, nil
+}
+
+// reassignPrimaryCircles はcircleIDを主サークルにしているユーザーの主サークルを、
+// 他の在籍中のサークル（最近参加したもの。なければNULL）に切り替える
+func reassignPrimaryCircles(exec sqlExecer, userIDs []string, circleID int) error {
+	_, err := exec.Exec(`
+		UPDATE users u
+		SET primary_circle_id = (
+			SELECT uc.circle_id FROM user_circles uc
+			WHERE uc.user_id = u.user_id AND uc.status = 'active'
+			ORDER BY uc.joined_at DESC LIMIT 1
+		), updated_at = NOW()
+		WHERE u.user_id = ANY($1::TEXT[]) AND u.primary_circle_id = $2
+	`, pq.Array(userIDs), circleID)
+	if err != nil {
+		return fmt.Errorf("failed to update primary circles: %w", err)
+	}
 	return nil
 }
 
+// MembershipChange は在籍状態を変更したメンバー
+type MembershipChange struct {
+	UserID     string `json:"userId"`
+	FromStatus string `json:"fromStatus"`
+}
+
+// TransitionCircleMembers はメンバーの在籍状態を一括で変更し、監査ログに記録する
+// 変更できない状態のメンバーは対象外。OB・OGになったメンバーは一般メンバーに戻す
+// 在籍中でなくなったサークルが主サークルの場合は、他の在籍中のサークルに切り替える
+func TransitionCircleMembers(circleID int, userIDs []string, status, actorID, reason string) ([]MembershipChange, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockCircle(tx, circleID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		UPDATE user_circles uc
+		SET status = $3,
+		    role = CASE WHEN $3 = 'alumni' THEN 'member' ELSE uc.role END,
+		    left_at = CASE WHEN $3 = 'active' THEN NULL ELSE NOW() END
+		FROM (
+			SELECT id, status FROM user_circles
+			WHERE circle_id = $1 AND user_id = ANY($2::TEXT[]) AND status = ANY($4::TEXT[])
+			FOR UPDATE
+		) old
+		WHERE uc.id = old.id
+		RETURNING uc.user_id, old.status
+	`, circleID, pq.Array(userIDs), status, pq.Array(membershipTransitions[status]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update membership status: %w", err)
+	}
+
+	changes := []MembershipChange{}
+	for rows.Next() {
+		var change MembershipChange
+		if err := rows.Scan(&change.UserID, &change.FromStatus); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	owners, err := countCircleOwners(tx, circleID)
+	if err != nil {
+		return nil, err
+	}
+	if owners == 0 {
+		return nil, errNoCircleOwnerLeft
+	}
+
+	if status != MembershipActive && len(changes) > 0 {
+		changedIDs := make([]string, 0, len(changes))
+		for _, change := range changes {
+			changedIDs = append(changedIDs, change.UserID)
+		}
+		if err := reassignPrimaryCircles(tx, changedIDs, circleID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range changes {
+		target := change.UserID
+		err := recordCircleAudit(tx, &CircleAuditLog{
+			CircleID: circleID,
+			ActorID:  actorID,
+			TargetID: &target,
+			Action:   AuditMemberStatusChanged,
+			Reason:   reason,
+			Details:  map[string]interface{}{"from": change.FromStatus, "to": status},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[サークル] 在籍状態変更: circle=%d, status=%s, count=%d", circleID, status, len(changes))
+	return changes, nil
+}
+
 // GetUserCircles はユーザーが所属するサークル一覧を取得する
 func GetUserCircles(userID string) ([]Circle, error) {
 	rows, err := db.Query(`
-		SELECT c.id, c.name, c.created_by, c.created_at
+		SELECT `+circleColumns+`, uc.role
 		FROM circles c
 		JOIN user_circles uc ON c.id = uc.circle_id
 		WHERE uc.user_id = $1 AND uc.status = 'active'
@@ -196,24 +573,30 @@ func GetUserCircles(userID string) ([]Circle, error) {
 
 	var circles []Circle
 	for rows.Next() {
-		var c Circle
-		if err := rows.Scan(&c.ID, &c.Name, &c.CreatedBy, &c.CreatedAt); err != nil {
+		var role string
+		c, err := scanCircle(rows, &role)
+		if err != nil {
 			log.Printf("スキャンエラー: %v", err)
 			continue
 		}
-		circles = append(circles, c)
+		c.MyRole = role
+		circles = append(circles, *c)
 	}
 	return circles, nil
 }
 
+// circleMemberName はサークル内の表示名（未設定ならLINEの名前）を返す式
+// （users u / user_circles uc を結合して使う）
+const circleMemberName = `COALESCE(NULLIF(uc.display_name, ''), u.name)`
+
 // GetCircleMembers はサークルのメンバー一覧を取得する
 func GetCircleMembers(circleID int, excludeUserID string) ([]CircleMember, error) {
 	rows, err := db.Query(`
-		SELECT u.user_id, u.name, uc.joined_at
+		SELECT u.user_id, `+circleMemberName+`, uc.display_name, uc.role, uc.status, uc.joined_at
 		FROM users u
 		JOIN user_circles uc ON u.user_id = uc.user_id
 		WHERE uc.circle_id = $1 AND uc.status = 'active' AND u.step = 3 AND u.user_id != $2
-		ORDER BY u.name
+		ORDER BY `+circleMemberName+`
 	`, circleID, excludeUserID)
 
 	if err != nil {
@@ -224,7 +607,7 @@ func GetCircleMembers(circleID int, excludeUserID string) ([]CircleMember, error
 	var members []CircleMember
 	for rows.Next() {
 		var m CircleMember
-		if err := rows.Scan(&m.UserID, &m.Name, &m.JoinedAt); err != nil {
+		if err := rows.Scan(&m.UserID, &m.Name, &m.DisplayName, &m.Role, &m.Status, &m.JoinedAt); err != nil {
 			log.Printf("スキャンエラー: %v", err)
 			continue
 		}
@@ -236,11 +619,11 @@ func GetCircleMembers(circleID int, excludeUserID string) ([]CircleMember, error
 // GetAllCircleMembers はサークルの全メンバー一覧を取得する（自分を含む）
 func GetAllCircleMembers(circleID int) ([]CircleMember, error) {
 	rows, err := db.Query(`
-		SELECT u.user_id, u.name, uc.joined_at
+		SELECT u.user_id, `+circleMemberName+`, uc.display_name, uc.role, uc.status, uc.joined_at
 		FROM users u
 		JOIN user_circles uc ON u.user_id = uc.user_id
 		WHERE uc.circle_id = $1 AND uc.status = 'active' AND u.step = 3
-		ORDER BY u.name
+		ORDER BY `+circleMemberName+`
 	`, circleID)
 
 	if err != nil {
@@ -251,7 +634,7 @@ func GetAllCircleMembers(circleID int) ([]CircleMember, error) {
 	var members []CircleMember
 	for rows.Next() {
 		var m CircleMember
-		if err := rows.Scan(&m.UserID, &m.Name, &m.JoinedAt); err != nil {
+		if err := rows.Scan(&m.UserID, &m.Name, &m.DisplayName, &m.Role, &m.Status, &m.JoinedAt); err != nil {
 			log.Printf("スキャンエラー: %v", err)
 			continue
 		}
@@ -260,6 +643,86 @@ func GetAllCircleMembers(circleID int) ([]CircleMember, error) {
 	return members, nil
 }
 
+// GetCircleMembersByStatus は指定した状態（休止中・OB・OGなど）のメンバー一覧を取得する
+func GetCircleMembersByStatus(circleID int, status string) ([]CircleMember, error) {
+	rows, err := db.Query(`
+		SELECT u.user_id, `+circleMemberName+`, uc.display_name, uc.role, uc.status, uc.joined_at
+		FROM users u
+		JOIN user_circles uc ON u.user_id = uc.user_id
+		WHERE uc.circle_id = $1 AND uc.status = $2 AND u.step = 3
+		ORDER BY `+circleMemberName+`
+	`, circleID, status)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []CircleMember
+	for rows.Next() {
+		var m CircleMember
+		if err := rows.Scan(&m.UserID, &m.Name, &m.DisplayName, &m.Role, &m.Status, &m.JoinedAt); err != nil {
+			log.Printf("スキャンエラー: %v", err)
+			continue
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// GetCircleMemberName はサークル内の表示名を取得する
+// メンバーでない場合は空文字を返す
+func GetCircleMemberName(userID string, circleID int) (string, error) {
+	var name string
+	err := db.QueryRow(`
+		SELECT `+circleMemberName+`
+		FROM users u
+		JOIN user_circles uc ON u.user_id = uc.user_id
+		WHERE uc.user_id = $1 AND uc.circle_id = $2
+	`, userID, circleID).Scan(&name)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+// UpdateCircleDisplayName はサークル内の表示名を変更する（空文字で未設定に戻す）
+// 未完了のイベントの未承認の参加者名も新しい表示名に揃える
+func UpdateCircleDisplayName(userID string, circleID int, displayName string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE user_circles SET display_name = $3
+		WHERE user_id = $1 AND circle_id = $2 AND status = 'active'
+	`, userID, circleID, displayName)
+	if err != nil {
+		return fmt.Errorf("failed to update display name: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("user is not a member of this circle")
+	}
+
+	_, err = tx.Exec(`
+		UPDATE event_participants ep
+		SET user_name = `+circleMemberName+`
+		FROM events e, users u, user_circles uc
+		WHERE ep.event_id = e.id AND ep.user_id = $1 AND ep.approved_at IS NULL
+		  AND e.circle_id = $2 AND e.status IN ('selecting', 'confirmed')
+		  AND u.user_id = ep.user_id AND uc.user_id = ep.user_id AND uc.circle_id = e.circle_id
+	`, userID, circleID)
+	if err != nil {
+		return fmt.Errorf("failed to update participant names: %w", err)
+	}
+
+	return tx.Commit()
+}
+
 // IsCircleMember はユーザーがサークルのメンバーかどうか確認する
 func IsCircleMember(userID string, circleID int) (bool, error) {
 	var exists bool
@@ -288,13 +751,50 @@ func GetOrCreateCircle(name, createdBy string) (*Circle, error) {
 	return CreateCircle(name, createdBy)
 }
 
-// SetPrimaryCircle はユーザーの主サークルを設定する
+// SetPrimaryCircle はユーザーの主サークルを設定し、変更した場合は監査ログに記録する
 func SetPrimaryCircle(userID string, circleID int) error {
-	_, err := db.Exec(`
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT primary_circle_id FROM users WHERE user_id = $1 FOR UPDATE
+	`, userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if previous.Valid && int(previous.Int64) == circleID {
+		return nil
+	}
+
+	if _, err := tx.Exec(`
 		UPDATE users SET primary_circle_id = $1, updated_at = NOW()
 		WHERE user_id = $2
-	`, circleID, userID)
-	return err
+	`, circleID, userID); err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{}
+	if previous.Valid {
+		details["previousCircleId"] = previous.Int64
+	}
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  userID,
+		TargetID: &userID,
+		Action:   AuditPrimaryCircleSet,
+		Details:  details,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetUserCircleStatus はユーザーのサークル参加状況を取得する
@@ -303,10 +803,10 @@ func GetUserCircleStatus(userID string, circleID int) (*UserCircle, error) {
 	var leftAt sql.NullTime
 
 	err := db.QueryRow(`
-		SELECT id, user_id, circle_id, status, joined_at, left_at
+		SELECT id, user_id, circle_id, status, role, joined_at, left_at
 		FROM user_circles
 		WHERE user_id = $1 AND circle_id = $2
-	`, userID, circleID).Scan(&uc.ID, &uc.UserID, &uc.CircleID, &uc.Status, &uc.JoinedAt, &leftAt)
+	`, userID, circleID).Scan(&uc.ID, &uc.UserID, &uc.CircleID, &uc.Status, &uc.Role, &uc.JoinedAt, &leftAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -332,78 +832,315 @@ func GetCircleMemberCount(circleID int) (int, error) {
 	return count, err
 }
 
-// ========== レガシー互換性 ==========
+// ========== ロール・設定 ==========
+
+// GetCircleRole はサークル内でのユーザーのロールを取得する（メンバーでない場合は空文字）
+func GetCircleRole(userID string, circleID int) (string, error) {
+	var role string
+	err := db.QueryRow(`
+		SELECT role FROM user_circles
+		WHERE user_id = $1 AND circle_id = $2 AND status = 'active'
+	`, userID, circleID).Scan(&role)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// UpdateCircleRole はメンバーのロールを変更し、監査ログに記録する
+// 最後のオーナーを降格する場合はerrNoCircleOwnerLeftを返す
+func UpdateCircleRole(userID string, circleID int, role, actorID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-// GetUsersByCircleLegacy は旧circle名でメンバーを取得する（後方互換性）
-func GetUsersByCircleLegacy(circleName string, excludeUserID string) ([]User, error) {
-	// まずcirclesテーブルからIDを取得
-	circle, err := GetCircleByName(circleName)
-	if err != nil || circle == nil {
-		// 旧方式にフォールバック
-		return GetUsersByCircleOld(circleName, excludeUserID)
+	if err := lockCircle(tx, circleID); err != nil {
+		return err
 	}
 
-	// 新方式で取得
-	rows, err := db.Query(`
-		SELECT u.user_id, u.name, COALESCE(u.circle, '') as circle
-		FROM users u
-		JOIN user_circles uc ON u.user_id = uc.user_id
-		WHERE uc.circle_id = $1 AND uc.status = 'active' AND u.step = 3 AND u.user_id != $2
-		ORDER BY u.name
-	`, circle.ID, excludeUserID)
+	var previous string
+	err = tx.QueryRow(`
+		UPDATE user_circles uc SET role = $3
+		FROM (
+			SELECT id, role FROM user_circles
+			WHERE user_id = $1 AND circle_id = $2 AND status = 'active'
+			FOR UPDATE
+		) old
+		WHERE uc.id = old.id
+		RETURNING old.role
+	`, userID, circleID, role).Scan(&previous)
 
+	if err == sql.ErrNoRows {
+		return errNotCircleMember
+	}
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to update circle role: %w", err)
 	}
-	defer rows.Close()
 
-	var users []User
-	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.UserID, &u.Name, &u.Circle); err != nil {
-			continue
+	// 最後のオーナーを降格するとサークルを管理できなくなるため禁止
+	if previous == CircleRoleOwner && role != CircleRoleOwner {
+		owners, err := countCircleOwners(tx, circleID)
+		if err != nil {
+			return err
 		}
-		users = append(users, u)
+		if owners == 0 {
+			return errNoCircleOwnerLeft
+		}
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  actorID,
+		TargetID: &userID,
+		Action:   AuditRoleChanged,
+		Details:  map[string]interface{}{"from": previous, "to": role},
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	return users, nil
+
+	log.Printf("[サークル] ロール変更: user=%s, circle=%d, role=%s", userID, circleID, role)
+	return nil
 }
 
-// GetUsersByCircleOld は旧方式でメンバーを取得する
-func GetUsersByCircleOld(circleName string, excludeUserID string) ([]User, error) {
-	rows, err := db.Query(`
-		SELECT user_id, name, circle
-		FROM users
-		WHERE circle = $1 AND step = 3 AND user_id != $2
-		ORDER BY name
-	`, circleName, excludeUserID)
+// lockCircle はトランザクションの終わりまでサークルの行をロックする
+// 在籍中のオーナーを減らしうる変更は先にこのロックを取り、別々のオーナーを同時に減らして0人になるのを防ぐ
+func lockCircle(tx *sql.Tx, circleID int) error {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM circles WHERE id = $1 FOR UPDATE`, circleID).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to lock circle: %w", err)
+	}
+	return nil
+}
 
+// countCircleOwners はサークルの在籍中のオーナー数を取得する
+func countCircleOwners(tx *sql.Tx, circleID int) (int, error) {
+	var count int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM user_circles
+		WHERE circle_id = $1 AND role = 'owner' AND status = 'active'
+	`, circleID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count circle owners: %w", err)
+	}
+	return count, nil
+}
+
+// GetCircleSettings はサークルの設定を取得する
+func GetCircleSettings(circleID int) (*CircleSettings, error) {
+	var settings CircleSettings
+	err := db.QueryRow(`
+		SELECT event_creation, visibility FROM circles WHERE id = $1 AND deleted_at IS NULL
+	`, circleID).Scan(&settings.EventCreation, &settings.Visibility)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &settings, nil
+}
+
+// UpdateCircleSettings はサークルの設定を更新する
+func UpdateCircleSettings(circleID int, settings *CircleSettings) error {
+	_, err := db.Exec(`
+		UPDATE circles SET event_creation = $2, visibility = $3 WHERE id = $1
+	`, circleID, settings.EventCreation, settings.Visibility)
+	if err != nil {
+		return fmt.Errorf("failed to update circle settings: %w", err)
+	}
+	return nil
+}
+
+// ========== サークル情報の編集・削除 ==========
+
+// UpdateCircleProfile はサークル名と説明を更新する
+func UpdateCircleProfile(circleID int, name, description string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRow(`
+		SELECT name FROM circles WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, circleID).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("circle not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get circle: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE circles SET name = $2, description = $3 WHERE id = $1
+	`, circleID, name, description); err != nil {
+		return fmt.Errorf("failed to update circle: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit circle update: %w", err)
+	}
+
+	if name != oldName {
+		log.Printf("[サークル] 名前変更: circle=%d, %s -> %s", circleID, oldName, name)
+	}
+	return nil
+}
+
+// UpdateCircleIcon はサークルアイコンを保存する
+func UpdateCircleIcon(circleID int, data []byte, contentType string) error {
+	_, err := db.Exec(`
+		UPDATE circles
+		SET icon_data = $2, icon_content_type = $3, icon_updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, circleID, data, contentType)
+	if err != nil {
+		return fmt.Errorf("failed to update circle icon: %w", err)
+	}
+	return nil
+}
+
+// DeleteCircleIcon はサークルアイコンを削除する
+func DeleteCircleIcon(circleID int) error {
+	_, err := db.Exec(`
+		UPDATE circles
+		SET icon_data = NULL, icon_content_type = NULL, icon_updated_at = NULL
+		WHERE id = $1 AND deleted_at IS NULL
+	`, circleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete circle icon: %w", err)
+	}
+	return nil
+}
+
+// GetCircleIcon はサークルアイコンの画像データを取得する
+// アイコンがない場合はnilを返す
+func GetCircleIcon(circleID int) (data []byte, contentType string, err error) {
+	var ct sql.NullString
+	err = db.QueryRow(`
+		SELECT icon_data, icon_content_type FROM circles
+		WHERE id = $1 AND deleted_at IS NULL AND icon_data IS NOT NULL
+	`, circleID).Scan(&data, &ct)
+
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, ct.String, nil
+}
+
+// DeleteCircle はサークルを論理削除する
+// メンバーシップとイベントはアーカイブし、招待コードと申請中の参加申請は無効にする
+func DeleteCircle(circleID int, deletedBy string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE circles SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL
+	`, circleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete circle: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("circle not found")
+	}
 
-	var users []User
+	// 在籍中・休止中・OB・OGのメンバーシップをアーカイブする（退出・退会済みはそのまま）
+	rows, err := tx.Query(`
+		UPDATE user_circles SET status = $2, left_at = COALESCE(left_at, NOW())
+		WHERE circle_id = $1 AND status = ANY($3::TEXT[])
+		RETURNING user_id
+	`, circleID, MembershipArchived, pq.Array([]string{MembershipActive, MembershipSuspended, MembershipAlumni}))
+	if err != nil {
+		return fmt.Errorf("failed to archive memberships: %w", err)
+	}
+	var memberIDs []string
 	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.UserID, &u.Name, &u.Circle); err != nil {
-			continue
+		var memberID string
+		if err := rows.Scan(&memberID); err != nil {
+			rows.Close()
+			return err
 		}
-		users = append(users, u)
+		memberIDs = append(memberIDs, memberID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := reassignPrimaryCircles(tx, memberIDs, circleID); err != nil {
+		return err
+	}
+
+	statements := []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{"events", `
+			UPDATE events SET status = 'archived', updated_at = NOW()
+			WHERE circle_id = $1 AND status != 'archived'`, []interface{}{circleID}},
+		{"invites", `
+			UPDATE circle_invites SET revoked_at = NOW()
+			WHERE circle_id = $1 AND revoked_at IS NULL`, []interface{}{circleID}},
+		{"dues schedules", `
+			UPDATE circle_dues_schedules SET active = FALSE, updated_at = NOW()
+			WHERE circle_id = $1 AND active`, []interface{}{circleID}},
+		{"join requests", `
+			UPDATE circle_join_requests SET status = 'denied', decided_by = $2, decided_at = NOW()
+			WHERE circle_id = $1 AND status = 'pending'`, []interface{}{circleID, deletedBy}},
+	}
+
+	for _, st := range statements {
+		if _, err := tx.Exec(st.query, st.args...); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", st.name, err)
+		}
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  deletedBy,
+		Action:   AuditCircleDeleted,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit circle deletion: %w", err)
 	}
-	return users, nil
+
+	log.Printf("[サークル] 削除: circle=%d, by=%s", circleID, deletedBy)
+	return nil
 }
 
 // ========== ユーティリティ ==========
 
 // GetPrimaryCircle はユーザーの主サークルを取得する
 func GetPrimaryCircle(userID string) (*Circle, error) {
-	var circle Circle
-	err := db.QueryRow(`
-		SELECT c.id, c.name, c.created_by, c.created_at
+	circle, err := scanCircle(db.QueryRow(`
+		SELECT `+circleColumns+`
 		FROM circles c
 		JOIN users u ON c.id = u.primary_circle_id
-		WHERE u.user_id = $1
-	`, userID).Scan(&circle.ID, &circle.Name, &circle.CreatedBy, &circle.CreatedAt)
+		WHERE u.user_id = $1 AND c.deleted_at IS NULL
+	`, userID))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -411,7 +1148,7 @@ func GetPrimaryCircle(userID string) (*Circle, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &circle, nil
+	return circle, nil
 }
 
 // CreateCircleAndJoin はサークルを作成してユーザーを参加させる
@@ -421,7 +1158,7 @@ func CreateCircleAndJoin(name, userID string) (*Circle, error) {
 		return nil, err
 	}
 
-	if err := JoinCircle(userID, circle.ID); err != nil {
+	if err := JoinCircle(userID, circle.ID, userID, joinSourceCreated); err != nil {
 		return nil, err
 	}
 
@@ -432,28 +1169,5 @@ func CreateCircleAndJoin(name, userID string) (*Circle, error) {
 	return circle, nil
 }
 
-// JoinCircleByName はサークル名でサークルに参加する
-func JoinCircleByName(userID, circleName string) (*Circle, error) {
-	circle, err := GetCircleByName(circleName)
-	if err != nil {
-		return nil, err
-	}
-	if circle == nil {
-		return nil, fmt.Errorf("circle not found: %s", circleName)
-	}
-
-	if err := JoinCircle(userID, circle.ID); err != nil {
-		return nil, err
-	}
-
-	// 主サークルが設定されていなければ設定
-	user, err := GetUser(userID)
-	if err == nil && user != nil && user.PrimaryCircleID == nil {
-		SetPrimaryCircle(userID, circle.ID)
-	}
-
-	return circle, nil
-}
-
 // dummy for time import
 var _ = time.Now
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// ========== 定期クリーンアップ ==========
+
+const (
+	// cleanupInterval はクリーンアップの実行間隔
+	cleanupInterval = 1 * time.Hour
+
+	// webhookEventTTL は処理済みWebhookイベントIDの保持期間
+	// LINEの再送はこの期間内に届く前提
+	webhookEventTTL = 7 * 24 * time.Hour
+)
+
+// cleanupTask は定期実行するクリーンアップ処理
+type cleanupTask struct {
+	name string
+	run  func() (int64, error)
+}
+
+// cleanupTasks は実行するクリーンアップ処理の一覧
+var cleanupTasks = []cleanupTask{
+	{"処理済みWebhookイベント", func() (int64, error) { return DeleteExpiredWebhookEvents(webhookEventTTL) }},
+	{"期限切れの会話状態", DeleteExpiredConversations},
+}
+
+// runCleanupTasks はすべてのクリーンアップ処理を実行する
+func runCleanupTasks() {
+	for _, t := range cleanupTasks {
+		deleted, err := t.run()
+		if err != nil {
+			log.Printf("[クリーンアップ] %s: エラー: %v", t.name, err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("[クリーンアップ] %s: %d件削除", t.name, deleted)
+		}
+	}
+}
+
+// startCleanupScheduler は定期クリーンアップを起動する
+func startCleanupScheduler() {
+	go func() {
+		log.Println("[クリーンアップ] スケジューラーを起動しました")
+
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		runCleanupTasks()
+		for range ticker.C {
+			runCleanupTasks()
+		}
+	}()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========== 会話状態管理 ==========
+
+const (
+	// conversationTTL は入力待ち状態の有効期間（これを過ぎると放置とみなしてリセット）
+	conversationTTL = 30 * time.Minute
+
+	// cancelCommand はどの会話状態からでも受け付ける中断コマンド
+	cancelCommand = "キャンセル"
+)
+
+// 会話状態
+const (
+	stateRegisterName         = "register_name"          // 登録: 名前待ち
+	stateRegisterCircleChoice = "register_circle_choice" // 登録: サークル新規作成/既存参加の選択待ち
+	stateRegisterCircleCreate = "register_circle_create" // 登録: 新規サークル名待ち
+	stateRegisterCircleJoin   = "register_circle_join"   // 登録: 参加するサークル名待ち
+)
+
+// conversationHandler は会話状態ごとのメッセージ処理
+type conversationHandler func(user *User, conv *Conversation, message, replyToken string)
+
+// conversationHandlers は会話状態と処理の対応表
+var conversationHandlers = map[string]conversationHandler{
+	stateRegisterName:         handleNameInput,
+	stateRegisterCircleChoice: handleCircleInput,
+	stateRegisterCircleCreate: handleCircleInput,
+	stateRegisterCircleJoin:   handleCircleInput,
+
+	stateSplitCircle:       handleSplitCircleInput,
+	stateSplitName:         handleSplitNameInput,
+	stateSplitAmount:       handleSplitAmountInput,
+	stateSplitParticipants: handleSplitParticipantInput,
+	stateSplitConfirm:      handleSplitConfirmInput,
+}
+
+// setConversation はユーザーの会話状態を設定する
+// payloadはJSONで保存され、次のメッセージ処理時にDecodeで取り出せる
+func setConversation(userID, state string, payload interface{}) error {
+	if payload == nil {
+		payload = struct{}{}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation payload: %w", err)
+	}
+
+	return SaveConversation(userID, state, data, conversationTTL)
+}
+
+// clearConversation はユーザーの会話状態を削除する
+func clearConversation(userID string) {
+	if err := DeleteConversation(userID); err != nil {
+		log.Printf("会話状態削除エラー: %v", err)
+	}
+}
+
+// Decode は会話状態の作業データを取り出す
+func (c *Conversation) Decode(v interface{}) error {
+	if len(c.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(c.Payload, v)
+}
+
+// dispatchConversation は会話状態に応じた処理を呼び出す
+// 処理した場合はtrueを返す
+func dispatchConversation(user *User, conv *Conversation, message, replyToken string) bool {
+	handler, ok := conversationHandlers[conv.State]
+	if !ok {
+		log.Printf("不明な会話状態をリセット: user=%s, state=%s", user.UserID, conv.State)
+		clearConversation(user.UserID)
+		return false
+	}
+
+	handler(user, conv, message, replyToken)
+	return true
+}
+
+// handleCancel は「キャンセル」コマンドを処理する
+func handleCancel(user *User, conv *Conversation, replyToken string) {
+	clearConversation(user.UserID)
+
+	if user.Step != 3 {
+		ReplyMessage(replyToken, "登録を中断しました。\nもう一度メッセージを送ると最初から登録できます。")
+		return
+	}
+
+	if conv == nil {
+		showMainMenu(user, replyToken, "キャンセルする操作はありません。")
+		return
+	}
+	showMainMenu(user, replyToken, "操作をキャンセルしました。")
+}
+
+// handleConversationTimeout は放置された会話をリセットしたことを伝える
+func handleConversationTimeout(user *User, replyToken string) {
+	clearConversation(user.UserID)
+
+	if user.Step != 3 {
+		restartUserRegistration(user, replyToken, "一定時間操作がなかったため、登録を最初からやり直します。\n")
+		return
+	}
+	showMainMenu(user, replyToken, "一定時間操作がなかったため、途中の操作をリセットしました。\n操作を選択してください：")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ========== 会話状態リポジトリ ==========
+
+// GetConversation はユーザーの会話状態を取得する（期限切れのものも返す）
+func GetConversation(userID string) (*Conversation, error) {
+	var conv Conversation
+	var payload []byte
+	err := db.QueryRow(`
+		SELECT user_id, state, payload, expires_at, expires_at <= NOW()
+		FROM conversation_states
+		WHERE user_id = $1
+	`, userID).Scan(&conv.UserID, &conv.State, &payload, &conv.ExpiresAt, &conv.Expired)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conv.Payload = payload
+	return &conv, nil
+}
+
+// SaveConversation は会話状態を保存する（既存の状態は上書き）
+func SaveConversation(userID, state string, payload []byte, ttl time.Duration) error {
+	_, err := db.Exec(`
+		INSERT INTO conversation_states (user_id, state, payload, expires_at, updated_at)
+		VALUES ($1, $2, $3, NOW() + ($4 * INTERVAL '1 second'), NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET state = EXCLUDED.state, payload = EXCLUDED.payload,
+		    expires_at = EXCLUDED.expires_at, updated_at = NOW()
+	`, userID, state, string(payload), int64(ttl.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return nil
+}
+
+// DeleteConversation はユーザーの会話状態を削除する
+func DeleteConversation(userID string) error {
+	_, err := db.Exec(`DELETE FROM conversation_states WHERE user_id = $1`, userID)
+	return err
+}
+
+// DeleteExpiredConversations は期限切れの会話状態を削除する
+func DeleteExpiredConversations() (int64, error) {
+	result, err := db.Exec(`DELETE FROM conversation_states WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired conversations: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -42,13 +43,8 @@ func createTables() error {
 	CREATE TABLE IF NOT EXISTS users (
 		user_id TEXT PRIMARY KEY,
 		name TEXT,
-		circle TEXT,
 		primary_circle_id INTEGER,
 		step INTEGER NOT NULL,
-		split_event_step INTEGER DEFAULT 0,
-		temp_event_id INTEGER,
-		approval_step INTEGER DEFAULT 0,
-		approval_event_id INTEGER,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
@@ -65,8 +61,15 @@ func createTables() error {
 	circlesTable := `
 	CREATE TABLE IF NOT EXISTS circles (
 		id SERIAL PRIMARY KEY,
-		name TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
 		created_by TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		icon_data BYTEA,
+		icon_content_type TEXT,
+		icon_updated_at TIMESTAMP,
+		event_creation TEXT NOT NULL DEFAULT 'all',
+		visibility TEXT NOT NULL DEFAULT 'public',
+		deleted_at TIMESTAMP,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
@@ -77,6 +80,7 @@ func createTables() error {
 		user_id TEXT NOT NULL,
 		circle_id INTEGER NOT NULL,
 		status TEXT DEFAULT 'active',
+		role TEXT NOT NULL DEFAULT 'member',
 		joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		left_at TIMESTAMP,
 		UNIQUE(user_id, circle_id)
@@ -87,11 +91,12 @@ func createTables() error {
 		id SERIAL PRIMARY KEY,
 		event_name TEXT NOT NULL,
 		organizer_id TEXT NOT NULL,
-		circle TEXT NOT NULL,
 		circle_id INTEGER,
 		total_amount INTEGER NOT NULL,
 		split_amount INTEGER NOT NULL,
 		status TEXT NOT NULL,
+		dues_schedule_id INTEGER,
+		dues_period_start DATE,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
@@ -108,9 +113,156 @@ func createTables() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
+	// サークル招待コード
+	circleInvitesTable := `
+	CREATE TABLE IF NOT EXISTS circle_invites (
+		id SERIAL PRIMARY KEY,
+		circle_id INTEGER NOT NULL REFERENCES circles(id),
+		code TEXT NOT NULL UNIQUE,
+		created_by TEXT NOT NULL,
+		expires_at TIMESTAMP,
+		max_uses INTEGER,
+		use_count INTEGER NOT NULL DEFAULT 0,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 会費の定期徴収スケジュール
+	circleDuesSchedulesTable := `
+	CREATE TABLE IF NOT EXISTS circle_dues_schedules (
+		id SERIAL PRIMARY KEY,
+		circle_id INTEGER NOT NULL REFERENCES circles(id),
+		name TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		period TEXT NOT NULL,
+		start_date DATE NOT NULL,
+		next_due_date DATE NOT NULL,
+		organizer_id TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 会費を免除するメンバー
+	circleDuesExemptionsTable := `
+	CREATE TABLE IF NOT EXISTS circle_dues_exemptions (
+		schedule_id INTEGER NOT NULL REFERENCES circle_dues_schedules(id),
+		user_id TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (schedule_id, user_id)
+	);`
+
+	// サークル会計の出納帳（収入・支出・会計担当間の移動）
+	// 支払い承認による収入はparticipant_idで紐付け、1件の支払いにつき1行のみ
+	circleLedgerEntriesTable := `
+	CREATE TABLE IF NOT EXISTS circle_ledger_entries (
+		id SERIAL PRIMARY KEY,
+		circle_id INTEGER NOT NULL REFERENCES circles(id),
+		entry_type TEXT NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		description TEXT NOT NULL DEFAULT '',
+		holder_id TEXT NOT NULL,
+		to_user_id TEXT,
+		event_id INTEGER REFERENCES events(id),
+		participant_id INTEGER UNIQUE REFERENCES event_participants(id),
+		receipt_data BYTEA,
+		receipt_content_type TEXT,
+		occurred_on DATE NOT NULL DEFAULT CURRENT_DATE,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// サークルの監査ログ（追記のみ）
+	circleAuditLogsTable := `
+	CREATE TABLE IF NOT EXISTS circle_audit_logs (
+		id SERIAL PRIMARY KEY,
+		circle_id INTEGER NOT NULL REFERENCES circles(id),
+		actor_id TEXT NOT NULL,
+		target_id TEXT,
+		action TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		details JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// LINEアカウントを持たないゲスト（サークルごと、名前のみ）
+	// 後から登録したユーザーに統合した場合はmerged_user_idを設定する
+	circleGuestsTable := `
+	CREATE TABLE IF NOT EXISTS circle_guests (
+		id SERIAL PRIMARY KEY,
+		circle_id INTEGER NOT NULL REFERENCES circles(id),
+		name TEXT NOT NULL,
+		contact TEXT NOT NULL DEFAULT '',
+		created_by TEXT NOT NULL,
+		merged_user_id TEXT,
+		merged_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// イベントの内訳（合計金額の明細）
+	eventItemsTable := `
+	CREATE TABLE IF NOT EXISTS event_items (
+		id SERIAL PRIMARY KEY,
+		event_id INTEGER NOT NULL REFERENCES events(id),
+		name TEXT NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount >= 0),
+		position INTEGER NOT NULL DEFAULT 0
+	);`
+
+	// イベントのレシート・メモ（会計者が添付、画像はBlobStoreに保存）
+	eventReceiptsTable := `
+	CREATE TABLE IF NOT EXISTS event_receipts (
+		id SERIAL PRIMARY KEY,
+		event_id INTEGER NOT NULL REFERENCES events(id),
+		note TEXT NOT NULL DEFAULT '',
+		blob_key TEXT,
+		content_type TEXT,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 非公開サークルへの参加申請
+	circleJoinRequestsTable := `
+	CREATE TABLE IF NOT EXISTS circle_join_requests (
+		id SERIAL PRIMARY KEY,
+		circle_id INTEGER NOT NULL REFERENCES circles(id),
+		user_id TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		decided_by TEXT,
+		decided_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 処理済みWebhookイベント（再送による二重処理防止）
+	webhookEventsTable := `
+	CREATE TABLE IF NOT EXISTS webhook_events (
+		webhook_event_id TEXT PRIMARY KEY,
+		is_redelivery BOOLEAN DEFAULT FALSE,
+		processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 月次明細の送信記録（同じ月の明細を二重に送らない）
+	monthlyStatementSendsTable := `
+	CREATE TABLE IF NOT EXISTS monthly_statement_sends (
+		user_id TEXT NOT NULL,
+		month TEXT NOT NULL,
+		sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, month)
+	);`
+
+	// Botとの会話状態（ユーザーごとに1件）
+	conversationStatesTable := `
+	CREATE TABLE IF NOT EXISTS conversation_states (
+		user_id TEXT PRIMARY KEY,
+		state TEXT NOT NULL,
+		payload JSONB NOT NULL DEFAULT '{}',
+		expires_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
 	indexEvents := `
 	CREATE INDEX IF NOT EXISTS idx_events_organizer ON events(organizer_id);
-	CREATE INDEX IF NOT EXISTS idx_events_circle ON events(circle);
 	CREATE INDEX IF NOT EXISTS idx_events_circle_id ON events(circle_id);`
 
 	indexParticipants := `
@@ -122,6 +274,45 @@ func createTables() error {
 	CREATE INDEX IF NOT EXISTS idx_user_circles_circle ON user_circles(circle_id);
 	CREATE INDEX IF NOT EXISTS idx_user_circles_status ON user_circles(status);`
 
+	indexCircleInvites := `
+	CREATE INDEX IF NOT EXISTS idx_circle_invites_circle ON circle_invites(circle_id);`
+
+	// 申請中の参加申請はユーザー・サークルごとに1件まで
+	indexCircleJoinRequests := `
+	CREATE INDEX IF NOT EXISTS idx_circle_join_requests_circle ON circle_join_requests(circle_id, status);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_circle_join_requests_pending
+		ON circle_join_requests(circle_id, user_id) WHERE status = 'pending';`
+
+	indexEventItems := `
+	CREATE INDEX IF NOT EXISTS idx_event_items_event ON event_items(event_id, position);`
+
+	indexEventReceipts := `
+	CREATE INDEX IF NOT EXISTS idx_event_receipts_event ON event_receipts(event_id);`
+
+	indexCircleGuests := `
+	CREATE INDEX IF NOT EXISTS idx_circle_guests_circle ON circle_guests(circle_id);`
+
+	indexCircleLedgerEntries := `
+	CREATE INDEX IF NOT EXISTS idx_circle_ledger_entries_circle ON circle_ledger_entries(circle_id, occurred_on, id);`
+
+	indexCircleAuditLogs := `
+	CREATE INDEX IF NOT EXISTS idx_circle_audit_logs_circle ON circle_audit_logs(circle_id, created_at);`
+
+	// 監査ログは追記のみ（更新・削除はエラーにする）
+	circleAuditLogsAppendOnly := `
+	CREATE OR REPLACE FUNCTION reject_circle_audit_log_change() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'circle_audit_logs is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS circle_audit_logs_append_only ON circle_audit_logs;
+	CREATE TRIGGER circle_audit_logs_append_only
+		BEFORE UPDATE OR DELETE ON circle_audit_logs
+		FOR EACH ROW EXECUTE PROCEDURE reject_circle_audit_log_change();`
+
+	indexWebhookEvents := `
+	CREATE INDEX IF NOT EXISTS idx_webhook_events_processed_at ON webhook_events(processed_at);`
+
 	// テーブル作成
 	tables := []struct {
 		name string
@@ -133,9 +324,30 @@ func createTables() error {
 		{"user_circles", userCirclesTable},
 		{"events", eventsTable},
 		{"event_participants", participantsTable},
+		{"circle_invites", circleInvitesTable},
+		{"circle_join_requests", circleJoinRequestsTable},
+		{"circle_guests", circleGuestsTable},
+		{"event_items", eventItemsTable},
+		{"event_receipts", eventReceiptsTable},
+		{"circle_dues_schedules", circleDuesSchedulesTable},
+		{"circle_dues_exemptions", circleDuesExemptionsTable},
+		{"circle_ledger_entries", circleLedgerEntriesTable},
+		{"circle_audit_logs", circleAuditLogsTable},
+		{"webhook_events", webhookEventsTable},
+		{"conversation_states", conversationStatesTable},
+		{"monthly_statement_sends", monthlyStatementSendsTable},
 		{"events_indexes", indexEvents},
 		{"participants_indexes", indexParticipants},
 		{"user_circles_indexes", indexUserCircles},
+		{"circle_invites_indexes", indexCircleInvites},
+		{"circle_join_requests_indexes", indexCircleJoinRequests},
+		{"circle_guests_indexes", indexCircleGuests},
+		{"event_items_indexes", indexEventItems},
+		{"event_receipts_indexes", indexEventReceipts},
+		{"circle_ledger_entries_indexes", indexCircleLedgerEntries},
+		{"circle_audit_logs_indexes", indexCircleAuditLogs},
+		{"circle_audit_logs_append_only", circleAuditLogsAppendOnly},
+		{"webhook_events_indexes", indexWebhookEvents},
 	}
 
 	for _, t := range tables {
@@ -144,16 +356,46 @@ func createTables() error {
 		}
 	}
 
+	// usersの旧会話状態カラム（split_event_step・temp_event_id・approval_step・approval_event_id）は
+	// conversation_statesへの移行後は使わないが、以前のバージョンに戻せるよう削除せずに残す
+	// 削除は後のリリースで行う
+
 	// 既存のテーブルに新しいカラムを追加（エラーは無視）
 	migrations := []string{
-		`ALTER TABLE users ADD COLUMN IF NOT EXISTS split_event_step INTEGER DEFAULT 0`,
-		`ALTER TABLE users ADD COLUMN IF NOT EXISTS temp_event_id INTEGER`,
-		`ALTER TABLE users ADD COLUMN IF NOT EXISTS approval_step INTEGER DEFAULT 0`,
-		`ALTER TABLE users ADD COLUMN IF NOT EXISTS approval_event_id INTEGER`,
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS primary_circle_id INTEGER`,
 		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS reported_at TIMESTAMP`,
 		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP`,
 		`ALTER TABLE events ADD COLUMN IF NOT EXISTS circle_id INTEGER`,
+		`ALTER TABLE user_circles ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member'`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS event_creation TEXT NOT NULL DEFAULT 'all'`,
+		// Webhookイベントは処理中(FALSE)として記録し、処理が終わってからTRUEにする（既存の記録は処理済み）
+		`ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS completed BOOLEAN NOT NULL DEFAULT TRUE`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS icon_data BYTEA`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS icon_content_type TEXT`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS icon_updated_at TIMESTAMP`,
+		`ALTER TABLE circles ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		// 削除済みサークルの名前は再利用できるよう、名前の一意制約は未削除のサークルに限定
+		`ALTER TABLE circles DROP CONSTRAINT IF EXISTS circles_name_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_circles_name_active ON circles(name) WHERE deleted_at IS NULL`,
+		`ALTER TABLE user_circles ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE events ADD COLUMN IF NOT EXISTS dues_schedule_id INTEGER`,
+		`ALTER TABLE events ADD COLUMN IF NOT EXISTS dues_period_start DATE`,
+		// 会費は1期間につき1イベントのみ生成する（通常のイベントはNULLなので対象外）
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_events_dues_period ON events(dues_schedule_id, dues_period_start)`,
+		`CREATE INDEX IF NOT EXISTS idx_circle_dues_schedules_due ON circle_dues_schedules(next_due_date) WHERE active`,
+		// ゲストの参加者はuser_idにゲスト用のID（guest:<id>）を入れ、guest_idで紐付ける
+		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS guest_id INTEGER REFERENCES circle_guests(id)`,
+		`CREATE INDEX IF NOT EXISTS idx_participants_guest ON event_participants(guest_id) WHERE guest_id IS NOT NULL`,
+		// 支払い報告の証拠画像（振込明細のスクリーンショットなど）
+		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS proof_data BYTEA`,
+		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS proof_content_type TEXT`,
+		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS proof_thumbnail BYTEA`,
+		`ALTER TABLE event_participants ADD COLUMN IF NOT EXISTS proof_uploaded_at TIMESTAMP`,
+		`ALTER TABLE events ADD COLUMN IF NOT EXISTS payment_deadline DATE`,
+		// 月次明細の集計用（承認月で絞り込む）
+		`CREATE INDEX IF NOT EXISTS idx_participants_approved_at ON event_participants(approved_at) WHERE approved_at IS NOT NULL`,
 	}
 
 	for _, m := range migrations {
@@ -164,78 +406,43 @@ func createTables() error {
 		}
 	}
 
-	// 既存データのマイグレーション（circles, user_circlesへの移行）
-	if err := migrateCircleData(); err != nil {
-		log.Printf("Circle migration warning: %v", err)
-	}
-
-	log.Println("Tables created successfully")
-	return nil
-}
-
-// migrateCircleData は既存のusers.circleデータを新テーブルに移行する
-func migrateCircleData() error {
-	// 既にマイグレーション済みかチェック
-	var count int
-	err := db.QueryRow(`SELECT COUNT(*) FROM circles`).Scan(&count)
-	if err != nil {
+	// バージョン管理されたマイグレーション（旧サークル名カラムの移行など）
+	if err := runSchemaMigrations(); err != nil {
 		return err
 	}
-	if count > 0 {
-		// 既にデータがある場合はスキップ
-		return nil
-	}
 
-	log.Println("Migrating circle data to new tables...")
-
-	// 1. 既存のサークル名をcirclesテーブルに移行
-	_, err = db.Exec(`
-		INSERT INTO circles (name, created_by)
-		SELECT DISTINCT circle, MIN(user_id)
-		FROM users
-		WHERE circle IS NOT NULL AND circle != '' AND step = 3
-		GROUP BY circle
-		ON CONFLICT (name) DO NOTHING
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to migrate circles: %w", err)
+	// オーナー不在のサークルは作成者（在籍中の場合）をオーナーにする
+	if err := assignMissingCircleOwners(); err != nil {
+		log.Printf("Circle owner migration warning: %v", err)
 	}
 
-	// 2. user_circlesに関係を作成
-	_, err = db.Exec(`
-		INSERT INTO user_circles (user_id, circle_id, status)
-		SELECT u.user_id, c.id, 'active'
-		FROM users u
-		JOIN circles c ON u.circle = c.name
-		WHERE u.circle IS NOT NULL AND u.circle != '' AND u.step = 3
-		ON CONFLICT (user_id, circle_id) DO NOTHING
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to migrate user_circles: %w", err)
-	}
+	log.Println("Tables created successfully")
+	return nil
+}
 
-	// 3. users.primary_circle_idを設定
-	_, err = db.Exec(`
-		UPDATE users u
-		SET primary_circle_id = c.id
+// assignMissingCircleOwners はオーナーがいないサークルの作成者をオーナーにする
+func assignMissingCircleOwners() error {
+	_, err := db.Exec(`
+		UPDATE user_circles uc SET role = 'owner'
 		FROM circles c
-		WHERE u.circle = c.name AND u.primary_circle_id IS NULL
+		WHERE uc.circle_id = c.id AND uc.user_id = c.created_by AND uc.status = 'active'
+		  AND NOT EXISTS (
+		      SELECT 1 FROM user_circles o
+		      WHERE o.circle_id = uc.circle_id AND o.role = 'owner' AND o.status = 'active'
+		  )
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to set primary_circle_id: %w", err)
+		return fmt.Errorf("failed to assign circle owners: %w", err)
 	}
+	return nil
+}
 
-	// 4. events.circle_idを設定
-	_, err = db.Exec(`
-		UPDATE events e
-		SET circle_id = c.id
-		FROM circles c
-		WHERE e.circle = c.name AND e.circle_id IS NULL
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to set events.circle_id: %w", err)
+// CurrentDate はDBのタイムゾーンでの今日の日付を返す
+// DATE列（会費の徴収日など）はDBのCURRENT_DATEと比較するため、アプリ側の時計ではなくDBの日付に揃える
+func CurrentDate() (time.Time, error) {
+	var date time.Time
+	if err := db.QueryRow(`SELECT CURRENT_DATE`).Scan(&date); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get current date: %w", err)
 	}
-
-	log.Println("Circle data migration completed")
-	return nil
+	return date, nil
 }
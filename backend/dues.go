@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========== 会費の定期徴収 ==========
+
+const (
+	// duesInterval は会費生成の確認間隔
+	duesInterval = 1 * time.Hour
+
+	// maxDuesCatchUpPeriods はスケジューラーが止まっていた場合に1回でさかのぼって生成する最大期間数
+	// （管理者が停止していた期間は再開時に次の徴収日を進めるため、さかのぼらない）
+	maxDuesCatchUpPeriods = 12
+)
+
+// duesPeriodMonths は徴収周期ごとの月数
+var duesPeriodMonths = map[string]int{
+	DuesPeriodMonthly:  1,
+	DuesPeriodSemester: 6,
+}
+
+// isValidDuesPeriod は徴収周期の値が正しいか判定する
+func isValidDuesPeriod(period string) bool {
+	_, ok := duesPeriodMonths[period]
+	return ok
+}
+
+// nextDuesPeriodStart は次の期間の開始日を返す
+func nextDuesPeriodStart(period string, start time.Time) time.Time {
+	return start.AddDate(0, duesPeriodMonths[period], 0)
+}
+
+// duesEventName は会費イベントの名前（対象期間付き）を返す
+func duesEventName(s *DuesSchedule, periodStart time.Time) string {
+	if s.Period == DuesPeriodMonthly {
+		return fmt.Sprintf("%s（%d年%d月分）", s.Name, periodStart.Year(), periodStart.Month())
+	}
+
+	periodEnd := nextDuesPeriodStart(s.Period, periodStart).AddDate(0, 0, -1)
+	return fmt.Sprintf("%s（%d年%d月〜%d年%d月分）", s.Name,
+		periodStart.Year(), periodStart.Month(), periodEnd.Year(), periodEnd.Month())
+}
+
+// generateDues は徴収日を迎えた会費を生成する
+func generateDues() {
+	schedules, err := GetDueDuesSchedules()
+	if err != nil {
+		log.Printf("[会費] スケジュール取得エラー: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		generateDuesForSchedule(&schedules[i])
+	}
+}
+
+// generateDuesForSchedule は1つのスケジュールについて未生成の期間の会費を生成する
+func generateDuesForSchedule(s *DuesSchedule) {
+	// 支払先がサークルを抜けていたら、誰も承認できないため停止する
+	isMember, err := IsCircleMember(s.OrganizerID, s.CircleID)
+	if err != nil {
+		log.Printf("[会費] メンバー確認エラー: %v", err)
+		return
+	}
+	if !isMember {
+		log.Printf("[会費] 支払先がサークルに在籍していないため停止: schedule=%d, organizer=%s", s.ID, s.OrganizerID)
+		if err := DeactivateDuesSchedule(s.ID, auditSystemActor, "支払先がサークルに在籍していないため自動停止"); err != nil {
+			log.Printf("[会費] 停止エラー: %v", err)
+		}
+		return
+	}
+
+	circle, err := GetCircleByID(s.CircleID)
+	if err != nil || circle == nil {
+		log.Printf("[会費] サークル取得エラー: %v", err)
+		return
+	}
+
+	// 今日を迎えているかはDBの日付（CURRENT_DATE）で判定する
+	for n := 0; n < maxDuesCatchUpPeriods; n++ {
+		periodStart := s.NextDueDate
+		next := nextDuesPeriodStart(s.Period, periodStart)
+		eventName := duesEventName(s, periodStart)
+
+		eventID, participantIDs, err := GenerateDuesEvent(s, eventName, next)
+		if errors.Is(err, errDuesNotClaimed) {
+			// 徴収日前、または会費設定時の即時生成などで他の処理が生成済み
+			return
+		}
+		if err != nil {
+			log.Printf("[会費] 生成エラー: schedule=%d, period=%s: %v", s.ID, periodStart.Format("2006-01-02"), err)
+			return
+		}
+		s.NextDueDate = next
+
+		if eventID == 0 {
+			continue
+		}
+
+		log.Printf("[会費] 生成: schedule=%d, event=%d, %s, %d人", s.ID, eventID, eventName, len(participantIDs))
+		go notifyDuesParticipants(circle, s, eventName, participantIDs)
+	}
+}
+
+// notifyDuesParticipants は会費の対象メンバーに通知する
+func notifyDuesParticipants(circle *Circle, s *DuesSchedule, eventName string, participantIDs []string) {
+	organizerName := "会計"
+	if organizer, err := GetUser(s.OrganizerID); err == nil && organizer != nil {
+		organizerName = organizer.Name
+	}
+
+	text := fmt.Sprintf("【会費のお知らせ】\n%sの会費の徴収時期になりました。\n\n%s\n金額: %s円\n支払先: %s\n\n支払いが完了したら「支払いました」と送信してください。",
+		circle.Name, eventName, formatAmount(s.Amount), organizerName)
+
+	for _, userID := range participantIDs {
+		if err := PushMessage(userID, text); err != nil {
+			log.Printf("[会費] 通知エラー (%s): %v", userID, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// startDuesScheduler は会費の定期生成を起動する
+func startDuesScheduler() {
+	go func() {
+		log.Println("[会費] スケジューラーを起動しました")
+
+		ticker := time.NewTicker(duesInterval)
+		defer ticker.Stop()
+
+		generateDues()
+		for range ticker.C {
+			generateDues()
+		}
+	}()
+}
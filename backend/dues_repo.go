@@ -0,0 +1,377 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========== 会費リポジトリ ==========
+
+// duesScheduleColumns は会費スケジュールのSELECT句（scanDuesScheduleと対応）
+const duesScheduleColumns = `
+	id, circle_id, name, amount, period, start_date, next_due_date, organizer_id, active, created_by, created_at`
+
+// scanDuesSchedule は会費スケジュールの行を読み取る（免除メンバーは含まない）
+func scanDuesSchedule(row rowScanner) (*DuesSchedule, error) {
+	var s DuesSchedule
+	err := row.Scan(&s.ID, &s.CircleID, &s.Name, &s.Amount, &s.Period, &s.StartDate, &s.NextDueDate,
+		&s.OrganizerID, &s.Active, &s.CreatedBy, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.ExemptUserIDs = []string{}
+	return &s, nil
+}
+
+// loadDuesExemptions は会費スケジュールの免除メンバーを読み込む
+func loadDuesExemptions(s *DuesSchedule) error {
+	rows, err := db.Query(`
+		SELECT user_id FROM circle_dues_exemptions WHERE schedule_id = $1 ORDER BY user_id
+	`, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.ExemptUserIDs = []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return err
+		}
+		s.ExemptUserIDs = append(s.ExemptUserIDs, userID)
+	}
+	return rows.Err()
+}
+
+// replaceDuesExemptions は免除メンバーを入れ替える
+func replaceDuesExemptions(tx *sql.Tx, scheduleID int, userIDs []string) error {
+	if _, err := tx.Exec(`DELETE FROM circle_dues_exemptions WHERE schedule_id = $1`, scheduleID); err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO circle_dues_exemptions (schedule_id, user_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, scheduleID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateDuesSchedule は会費スケジュールを作成する
+func CreateDuesSchedule(s *DuesSchedule) (*DuesSchedule, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	created, err := scanDuesSchedule(tx.QueryRow(`
+		INSERT INTO circle_dues_schedules
+			(circle_id, name, amount, period, start_date, next_due_date, organizer_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $5, $6, $7)
+		RETURNING `+duesScheduleColumns,
+		s.CircleID, s.Name, s.Amount, s.Period, s.StartDate, s.OrganizerID, s.CreatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dues schedule: %w", err)
+	}
+
+	if err := replaceDuesExemptions(tx, created.ID, s.ExemptUserIDs); err != nil {
+		return nil, fmt.Errorf("failed to save dues exemptions: %w", err)
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: s.CircleID,
+		ActorID:  s.CreatedBy,
+		TargetID: &s.OrganizerID,
+		Action:   AuditDuesCreated,
+		Details:  duesAuditDetails(created.ID, s),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit dues schedule: %w", err)
+	}
+
+	created.ExemptUserIDs = s.ExemptUserIDs
+	log.Printf("[会費] スケジュール作成: circle=%d, schedule=%d, %s %d円/%s", s.CircleID, created.ID, s.Name, s.Amount, s.Period)
+	return created, nil
+}
+
+// GetDuesSchedule はIDで会費スケジュールを取得する
+func GetDuesSchedule(scheduleID int) (*DuesSchedule, error) {
+	s, err := scanDuesSchedule(db.QueryRow(`
+		SELECT `+duesScheduleColumns+`
+		FROM circle_dues_schedules
+		WHERE id = $1
+	`, scheduleID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadDuesExemptions(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCircleDuesSchedules はサークルの会費スケジュール一覧を取得する
+func GetCircleDuesSchedules(circleID int) ([]DuesSchedule, error) {
+	rows, err := db.Query(`
+		SELECT `+duesScheduleColumns+`
+		FROM circle_dues_schedules
+		WHERE circle_id = $1
+		ORDER BY active DESC, created_at DESC
+	`, circleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []DuesSchedule
+	for rows.Next() {
+		s, err := scanDuesSchedule(rows)
+		if err != nil {
+			log.Printf("スキャンエラー: %v", err)
+			continue
+		}
+		schedules = append(schedules, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range schedules {
+		if err := loadDuesExemptions(&schedules[i]); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// UpdateDuesSchedule は会費スケジュールを更新する（次の期間から反映）
+// 停止していたスケジュールを再開する場合は、停止中の期間をさかのぼって生成しないよう
+// 次の徴収日を今日（DBの日付）以降の最初の期間の開始日まで進める
+func UpdateDuesSchedule(s *DuesSchedule, actorID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var wasActive bool
+	var nextDueDate, today time.Time
+	err = tx.QueryRow(`
+		SELECT active, next_due_date, CURRENT_DATE FROM circle_dues_schedules WHERE id = $1 FOR UPDATE
+	`, s.ID).Scan(&wasActive, &nextDueDate, &today)
+	if err != nil {
+		return fmt.Errorf("failed to get dues schedule: %w", err)
+	}
+
+	details := duesAuditDetails(s.ID, s)
+	if s.Active && !wasActive {
+		for nextDueDate.Before(today) {
+			nextDueDate = nextDuesPeriodStart(s.Period, nextDueDate)
+		}
+		details["nextDueDate"] = nextDueDate.Format("2006-01-02")
+	}
+	s.NextDueDate = nextDueDate
+
+	if _, err := tx.Exec(`
+		UPDATE circle_dues_schedules
+		SET name = $2, amount = $3, organizer_id = $4, active = $5, next_due_date = $6, updated_at = NOW()
+		WHERE id = $1
+	`, s.ID, s.Name, s.Amount, s.OrganizerID, s.Active, nextDueDate); err != nil {
+		return fmt.Errorf("failed to update dues schedule: %w", err)
+	}
+
+	if err := replaceDuesExemptions(tx, s.ID, s.ExemptUserIDs); err != nil {
+		return fmt.Errorf("failed to save dues exemptions: %w", err)
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: s.CircleID,
+		ActorID:  actorID,
+		TargetID: &s.OrganizerID,
+		Action:   AuditDuesUpdated,
+		Details:  details,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit dues schedule: %w", err)
+	}
+	return nil
+}
+
+// DeactivateDuesSchedule は会費スケジュールを停止し、監査ログに記録する
+// 自動停止の場合はactorIDにauditSystemActorを渡す
+func DeactivateDuesSchedule(scheduleID int, actorID, reason string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var circleID int
+	err = tx.QueryRow(`
+		UPDATE circle_dues_schedules SET active = FALSE, updated_at = NOW()
+		WHERE id = $1 AND active
+		RETURNING circle_id
+	`, scheduleID).Scan(&circleID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to deactivate dues schedule: %w", err)
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  actorID,
+		Action:   AuditDuesStopped,
+		Reason:   reason,
+		Details:  map[string]interface{}{"scheduleId": scheduleID},
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[会費] スケジュール停止: schedule=%d", scheduleID)
+	return nil
+}
+
+// duesAuditDetails は会費スケジュールの監査ログの詳細
+func duesAuditDetails(scheduleID int, s *DuesSchedule) map[string]interface{} {
+	return map[string]interface{}{
+		"scheduleId":    scheduleID,
+		"name":          s.Name,
+		"amount":        s.Amount,
+		"period":        s.Period,
+		"active":        s.Active,
+		"exemptUserIds": s.ExemptUserIDs,
+	}
+}
+
+// GetDueDuesSchedules は徴収日を迎えた有効な会費スケジュールを取得する
+func GetDueDuesSchedules() ([]DuesSchedule, error) {
+	rows, err := db.Query(`
+		SELECT ` + duesScheduleColumns + `
+		FROM circle_dues_schedules s
+		WHERE s.active AND s.next_due_date <= CURRENT_DATE
+		  AND EXISTS (SELECT 1 FROM circles c WHERE c.id = s.circle_id AND c.deleted_at IS NULL)
+		ORDER BY s.next_due_date
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []DuesSchedule
+	for rows.Next() {
+		s, err := scanDuesSchedule(rows)
+		if err != nil {
+			log.Printf("スキャンエラー: %v", err)
+			continue
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// errDuesNotClaimed は会費の期間を生成できなかった（徴収日前、または他の処理が生成済み）場合のエラー
+var errDuesNotClaimed = errors.New("dues period is not due or already claimed")
+
+// GenerateDuesEvent は1期間分の会費イベントを作成し、次回徴収日を進める
+// 対象は在籍中のメンバー（支払先と免除メンバーを除く）
+// 先に次回徴収日を進めてスケジュールの行をロックし、同じ期間を並行して生成しないようにする
+// 徴収日前（DBのCURRENT_DATEで判定）や、他の処理が既に進めていた場合はerrDuesNotClaimedを返す
+// 既に生成済みの期間の場合はeventIDに0を返し、徴収日だけを進める
+func GenerateDuesEvent(s *DuesSchedule, eventName string, nextDueDate time.Time) (eventID int, participantIDs []string, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE circle_dues_schedules SET next_due_date = $3, updated_at = NOW()
+		WHERE id = $1 AND active AND next_due_date = $2 AND next_due_date <= CURRENT_DATE
+	`, s.ID, s.NextDueDate, nextDueDate)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to advance dues schedule: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	if claimed == 0 {
+		return 0, nil, errDuesNotClaimed
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO events
+			(event_name, organizer_id, circle_id, total_amount, split_amount, status, dues_schedule_id, dues_period_start)
+		VALUES ($1, $2, $3, 0, $4, 'confirmed', $5, $6)
+		ON CONFLICT (dues_schedule_id, dues_period_start) DO NOTHING
+		RETURNING id
+	`, eventName, s.OrganizerID, s.CircleID, s.Amount, s.ID, s.NextDueDate).Scan(&eventID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, nil, fmt.Errorf("failed to create dues event: %w", err)
+	}
+
+	if eventID != 0 {
+		rows, err := tx.Query(`
+			INSERT INTO event_participants (event_id, user_id, user_name, paid)
+			SELECT $1, u.user_id, `+circleMemberName+`, false
+			FROM user_circles uc
+			JOIN users u ON uc.user_id = u.user_id
+			WHERE uc.circle_id = $2 AND uc.status = 'active' AND uc.user_id != $3
+			  AND NOT EXISTS (
+			      SELECT 1 FROM circle_dues_exemptions ex
+			      WHERE ex.schedule_id = $4 AND ex.user_id = uc.user_id
+			  )
+			RETURNING user_id
+		`, eventID, s.CircleID, s.OrganizerID, s.ID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to create dues participants: %w", err)
+		}
+		for rows.Next() {
+			var userID string
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return 0, nil, err
+			}
+			participantIDs = append(participantIDs, userID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, nil, err
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE events SET total_amount = split_amount * $2 WHERE id = $1
+		`, eventID, len(participantIDs)); err != nil {
+			return 0, nil, fmt.Errorf("failed to update dues total: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit dues event: %w", err)
+	}
+	return eventID, participantIDs, nil
+}
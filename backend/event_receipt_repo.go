@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// ========== イベントのレシートリポジトリ ==========
+
+// eventReceiptImageURL はレシート画像のURLを返す
+func eventReceiptImageURL(eventID, receiptID int) string {
+	return fmt.Sprintf("/api/liff/events/%d/receipts/%d/image", eventID, receiptID)
+}
+
+// scanEventReceipt はレシートの行を読み取る
+func scanEventReceipt(row rowScanner) (*EventReceipt, error) {
+	var r EventReceipt
+	var blobKey, contentType sql.NullString
+	if err := row.Scan(&r.ID, &r.EventID, &r.Note, &blobKey, &contentType, &r.CreatedBy, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if blobKey.Valid {
+		r.BlobKey = blobKey.String
+		r.ContentType = contentType.String
+		r.ImageURL = eventReceiptImageURL(r.EventID, r.ID)
+	}
+	return &r, nil
+}
+
+// CreateEventReceipt はイベントにレシートを追加する（画像はBlobStoreに保存済みのキーを渡す）
+func CreateEventReceipt(r *EventReceipt) (*EventReceipt, error) {
+	var blobKey, contentType *string
+	if r.BlobKey != "" {
+		blobKey, contentType = &r.BlobKey, &r.ContentType
+	}
+
+	receipt, err := scanEventReceipt(db.QueryRow(`
+		INSERT INTO event_receipts (event_id, note, blob_key, content_type, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, event_id, note, blob_key, content_type, created_by, created_at
+	`, r.EventID, r.Note, blobKey, contentType, r.CreatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event receipt: %w", err)
+	}
+	return receipt, nil
+}
+
+// GetEventReceipt はイベントのレシートを取得する
+func GetEventReceipt(eventID, receiptID int) (*EventReceipt, error) {
+	receipt, err := scanEventReceipt(db.QueryRow(`
+		SELECT id, event_id, note, blob_key, content_type, created_by, created_at
+		FROM event_receipts
+		WHERE id = $1 AND event_id = $2
+	`, receiptID, eventID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// GetEventReceipts はイベントのレシートを追加した順に取得する
+func GetEventReceipts(eventID int) ([]EventReceipt, error) {
+	rows, err := db.Query(`
+		SELECT id, event_id, note, blob_key, content_type, created_by, created_at
+		FROM event_receipts
+		WHERE event_id = $1
+		ORDER BY id
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []EventReceipt{}
+	for rows.Next() {
+		receipt, err := scanEventReceipt(rows)
+		if err != nil {
+			log.Printf("レシートスキャンエラー: %v", err)
+			continue
+		}
+		receipts = append(receipts, *receipt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// DeleteEventReceipt はイベントのレシートを削除し、削除した行を返す（なければnil）
+// 画像の削除は呼び出し側でBlobStoreから行う
+func DeleteEventReceipt(eventID, receiptID int) (*EventReceipt, error) {
+	receipt, err := scanEventReceipt(db.QueryRow(`
+		DELETE FROM event_receipts
+		WHERE id = $1 AND event_id = $2
+		RETURNING id, event_id, note, blob_key, content_type, created_by, created_at
+	`, receiptID, eventID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// IsEventParticipant はユーザーがイベントの参加者か確認する
+func IsEventParticipant(eventID int, userID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM event_participants WHERE event_id = $1 AND user_id = $2)
+	`, eventID, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetEventParticipantUserIDs はイベントのLINEユーザーの参加者（ゲストを除く）を取得する
+func GetEventParticipantUserIDs(eventID int) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT user_id FROM event_participants
+		WHERE event_id = $1 AND guest_id IS NULL
+		ORDER BY id
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			log.Printf("参加者スキャンエラー: %v", err)
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // ========== イベントリポジトリ ==========
@@ -10,11 +14,13 @@ import (
 // GetEvent はイベントを取得する
 func GetEvent(eventID int) (*Event, error) {
 	var event Event
+	var circleID sql.NullInt64
+	var deadline sql.NullTime
 	err := db.QueryRow(`
-		SELECT id, event_name, organizer_id, circle, total_amount, split_amount, status, created_at, updated_at
+		SELECT id, event_name, organizer_id, circle_id, total_amount, split_amount, status, payment_deadline, created_at, updated_at
 		FROM events WHERE id = $1
-	`, eventID).Scan(&event.ID, &event.EventName, &event.OrganizerID, &event.Circle,
-		&event.TotalAmount, &event.SplitAmount, &event.Status, &event.CreatedAt, &event.UpdatedAt)
+	`, eventID).Scan(&event.ID, &event.EventName, &event.OrganizerID, &circleID,
+		&event.TotalAmount, &event.SplitAmount, &event.Status, &deadline, &event.CreatedAt, &event.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -22,28 +28,108 @@ func GetEvent(eventID int) (*Event, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if circleID.Valid {
+		id := int(circleID.Int64)
+		event.CircleID = &id
+	}
+	if deadline.Valid {
+		event.Deadline = &deadline.Time
+	}
 	return &event, nil
 }
 
-// CreateEvent は新しいイベントを作成する
-func CreateEvent(eventName, organizerID, circle string, totalAmount, splitAmount int) (int, error) {
+// SplitEventInput は割り勘イベントの作成内容
+type SplitEventInput struct {
+	EventName      string
+	OrganizerID    string
+	CircleID       int
+	TotalAmount    int
+	ParticipantIDs []string      // サークルのメンバー
+	Guests         []CircleGuest // 登録済みのゲスト
+	NewGuests      []guestInput  // イベントと同時にサークルに登録するゲスト
+	Deadline       *time.Time    // 支払い期限（任意）
+	Items          []EventItem   // 内訳（任意）
+}
+
+// CreateSplitEvent はイベントと参加者（新しいゲストの登録を含む）・支払い期限・内訳を1つのトランザクションで作成する
+// 参加者の名前はサークル内の表示名を優先する。存在しないユーザーは参加者にしない
+// 戻り値はイベントIDと参加者として登録したユーザーID（ゲストを除く）
+func CreateSplitEvent(in *SplitEventInput, splitAmount int) (int, []string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var eventID int
-	err := db.QueryRow(`
-		INSERT INTO events (event_name, organizer_id, circle, total_amount, split_amount, status)
-		VALUES ($1, $2, $3, $4, $5, 'confirmed')
+	err = tx.QueryRow(`
+		INSERT INTO events (event_name, organizer_id, circle_id, total_amount, split_amount, status, payment_deadline)
+		VALUES ($1, $2, $3, $4, $5, 'confirmed', $6)
 		RETURNING id
-	`, eventName, organizerID, circle, totalAmount, splitAmount).Scan(&eventID)
+	`, in.EventName, in.OrganizerID, in.CircleID, in.TotalAmount, splitAmount, in.Deadline).Scan(&eventID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create event: %w", err)
+	}
 
+	if err := insertEventItems(tx, eventID, in.Items); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := tx.Query(`
+		INSERT INTO event_participants (event_id, user_id, user_name, paid)
+		SELECT $1, u.user_id, `+circleMemberName+`, false
+		FROM users u
+		LEFT JOIN user_circles uc ON uc.user_id = u.user_id AND uc.circle_id = $2
+		WHERE u.user_id = ANY($3::TEXT[])
+		RETURNING user_id
+	`, eventID, in.CircleID, pq.Array(in.ParticipantIDs))
 	if err != nil {
-		return 0, err
+		return 0, nil, fmt.Errorf("failed to create participants: %w", err)
+	}
+	var participantIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		participantIDs = append(participantIDs, userID)
 	}
-	return eventID, nil
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	guests := append([]CircleGuest{}, in.Guests...)
+	for _, g := range in.NewGuests {
+		guest, err := insertCircleGuest(tx, in.CircleID, g.Name, g.Contact, in.OrganizerID)
+		if err != nil {
+			return 0, nil, err
+		}
+		guests = append(guests, *guest)
+	}
+	for _, guest := range guests {
+		if _, err := tx.Exec(`
+			INSERT INTO event_participants (event_id, user_id, user_name, paid, guest_id)
+			VALUES ($1, $2, $3, false, $4)
+		`, eventID, guestUserID(guest.ID), guest.Name, guest.ID); err != nil {
+			return 0, nil, fmt.Errorf("failed to create guest participant: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return eventID, participantIDs, nil
 }
 
 // EventSummary はイベント一覧用のサマリー情報
 type EventSummary struct {
 	ID          int
 	Name        string
+	CircleID    *int
+	CircleName  string
 	TotalAmount int
 	SplitAmount int
 	Status      string
@@ -51,13 +137,15 @@ type EventSummary struct {
 }
 
 // GetEventsByOrganizer は指定ユーザーが作成したイベント一覧を取得する
-func GetEventsByOrganizer(organizerID string) ([]EventSummary, error) {
+// circleIDを指定した場合はそのサークルのイベントのみ
+func GetEventsByOrganizer(organizerID string, circleID *int) ([]EventSummary, error) {
 	rows, err := db.Query(`
-		SELECT id, event_name, total_amount, split_amount, status, created_at
-		FROM events
-		WHERE organizer_id = $1
-		ORDER BY created_at DESC
-	`, organizerID)
+		SELECT e.id, e.event_name, e.circle_id, COALESCE(c.name, ''), e.total_amount, e.split_amount, e.status, e.created_at
+		FROM events e
+		LEFT JOIN circles c ON e.circle_id = c.id
+		WHERE e.organizer_id = $1 AND ($2::INTEGER IS NULL OR e.circle_id = $2)
+		ORDER BY e.created_at DESC
+	`, organizerID, circleID)
 	if err != nil {
 		return nil, err
 	}
@@ -66,10 +154,15 @@ func GetEventsByOrganizer(organizerID string) ([]EventSummary, error) {
 	var events []EventSummary
 	for rows.Next() {
 		var e EventSummary
-		if err := rows.Scan(&e.ID, &e.Name, &e.TotalAmount, &e.SplitAmount, &e.Status, &e.CreatedAt); err != nil {
+		var eventCircleID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.Name, &eventCircleID, &e.CircleName, &e.TotalAmount, &e.SplitAmount, &e.Status, &e.CreatedAt); err != nil {
 			log.Printf("イベントスキャンエラー: %v", err)
 			continue
 		}
+		if eventCircleID.Valid {
+			id := int(eventCircleID.Int64)
+			e.CircleID = &id
+		}
 		events = append(events, e)
 	}
 
@@ -119,41 +212,127 @@ func GetUnpaidEventsForUser(userID string) ([]UnpaidEventInfo, error) {
 	return events, nil
 }
 
-// UserPaymentStatus はユーザーの支払い状況
-type UserPaymentStatus struct {
-	EventName string
-	Amount    int
-	Paid      bool
+// SetEventDeadline はイベントの支払い期限を設定する（nilで解除）
+func SetEventDeadline(eventID int, deadline *time.Time) error {
+	_, err := db.Exec(`
+		UPDATE events SET payment_deadline = $2, updated_at = NOW() WHERE id = $1
+	`, eventID, deadline)
+	if err != nil {
+		return fmt.Errorf("failed to set event deadline: %w", err)
+	}
+	return nil
+}
+
+// insertEventItems はイベントの内訳を並び順どおりに追加する
+func insertEventItems(exec sqlExecer, eventID int, items []EventItem) error {
+	for i, item := range items {
+		if _, err := exec.Exec(`
+			INSERT INTO event_items (event_id, name, amount, position) VALUES ($1, $2, $3, $4)
+		`, eventID, item.Name, item.Amount, i); err != nil {
+			return fmt.Errorf("failed to create event item: %w", err)
+		}
+	}
+	return nil
 }
 
-// GetUserPaymentStatus はユーザーの支払い状況一覧を取得する
-func GetUserPaymentStatus(userID string) ([]UserPaymentStatus, error) {
+// GetEventItems はイベントの内訳を登録順に取得する
+func GetEventItems(eventID int) ([]EventItem, error) {
 	rows, err := db.Query(`
-		SELECT e.event_name, e.split_amount, ep.paid
-		FROM events e
-		JOIN event_participants ep ON e.id = ep.event_id
-		WHERE ep.user_id = $1
-		ORDER BY e.created_at DESC
-		LIMIT 10
-	`, userID)
+		SELECT name, amount FROM event_items WHERE event_id = $1 ORDER BY position, id
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []EventItem{}
+	for rows.Next() {
+		var item EventItem
+		if err := rows.Scan(&item.Name, &item.Amount); err != nil {
+			log.Printf("内訳スキャンエラー: %v", err)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetEventParticipants はイベントの参加者を登録順に取得する
+func GetEventParticipants(eventID int) ([]Participant, error) {
+	rows, err := db.Query(`
+		SELECT id, event_id, user_id, user_name, paid, reported_at, approved_at, guest_id,
+		       proof_data IS NOT NULL, created_at
+		FROM event_participants
+		WHERE event_id = $1
+		ORDER BY id
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []Participant
+	for rows.Next() {
+		var p Participant
+		var reportedAt, approvedAt sql.NullTime
+		var guestID sql.NullInt64
+		if err := rows.Scan(&p.ID, &p.EventID, &p.UserID, &p.UserName, &p.Paid, &reportedAt, &approvedAt,
+			&guestID, &p.HasProof, &p.CreatedAt); err != nil {
+			log.Printf("参加者スキャンエラー: %v", err)
+			continue
+		}
+		if reportedAt.Valid {
+			p.ReportedAt = &reportedAt.Time
+		}
+		if approvedAt.Valid {
+			p.ApprovedAt = &approvedAt.Time
+		}
+		if guestID.Valid {
+			id := int(guestID.Int64)
+			p.GuestID = &id
+		}
+		participants = append(participants, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return participants, nil
+}
+
+// GetEventRejections はイベントの支払い報告の差し戻しを監査ログから取得する
+// 差し戻すと報告日時が消えるため、履歴には監査ログを使う（サークルのイベントのみ記録がある）
+func GetEventRejections(circleID, eventID int) ([]EventHistoryEntry, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(a.target_id, ''), a.created_at
+		FROM circle_audit_logs a
+		WHERE a.circle_id = $1 AND a.action = $2 AND a.details->>'eventId' = $3::TEXT
+		ORDER BY a.id
+	`, circleID, AuditPaymentRejected, eventID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var statuses []UserPaymentStatus
+	var entries []EventHistoryEntry
 	for rows.Next() {
-		var s UserPaymentStatus
-		if err := rows.Scan(&s.EventName, &s.Amount, &s.Paid); err != nil {
-			log.Printf("ステータススキャンエラー: %v", err)
+		entry := EventHistoryEntry{Type: "rejected"}
+		if err := rows.Scan(&entry.UserID, &entry.At); err != nil {
+			log.Printf("差し戻し履歴スキャンエラー: %v", err)
 			continue
 		}
-		statuses = append(statuses, s)
+		entries = append(entries, entry)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return statuses, nil
+	return entries, nil
 }
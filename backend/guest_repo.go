@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// ========== ゲストリポジトリ ==========
+
+// guestUserIDPrefix はゲストの参加者レコードのuser_idの接頭辞
+// LINEのユーザーID（U始まり）とは重ならない
+const guestUserIDPrefix = "guest:"
+
+// guestUserID はゲストの参加者レコードに入れるuser_idを返す
+func guestUserID(guestID int) string {
+	return guestUserIDPrefix + strconv.Itoa(guestID)
+}
+
+// guestColumns はcircle_guestsの取得カラム
+const guestColumns = `id, circle_id, name, contact, created_by, merged_user_id, merged_at, created_at`
+
+// scanCircleGuest はゲストの行を読み取る
+func scanCircleGuest(row rowScanner) (*CircleGuest, error) {
+	var g CircleGuest
+	var mergedUserID sql.NullString
+	var mergedAt sql.NullTime
+	if err := row.Scan(&g.ID, &g.CircleID, &g.Name, &g.Contact, &g.CreatedBy, &mergedUserID, &mergedAt, &g.CreatedAt); err != nil {
+		return nil, err
+	}
+	if mergedUserID.Valid {
+		g.MergedUserID = &mergedUserID.String
+	}
+	if mergedAt.Valid {
+		g.MergedAt = &mergedAt.Time
+	}
+	return &g, nil
+}
+
+// CreateCircleGuest はサークルにゲストを登録する
+func CreateCircleGuest(circleID int, name, contact, createdBy string) (*CircleGuest, error) {
+	return insertCircleGuest(db, circleID, name, contact, createdBy)
+}
+
+// insertCircleGuest はゲストを登録する（イベント作成と同じトランザクションでも使う）
+func insertCircleGuest(q sqlRowQueryer, circleID int, name, contact, createdBy string) (*CircleGuest, error) {
+	guest, err := scanCircleGuest(q.QueryRow(`
+		INSERT INTO circle_guests (circle_id, name, contact, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+guestColumns, circleID, name, contact, createdBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guest: %w", err)
+	}
+	return guest, nil
+}
+
+// GetCircleGuest はサークルのゲストを取得する
+func GetCircleGuest(circleID, guestID int) (*CircleGuest, error) {
+	guest, err := scanCircleGuest(db.QueryRow(`
+		SELECT `+guestColumns+` FROM circle_guests
+		WHERE id = $1 AND circle_id = $2
+	`, guestID, circleID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return guest, nil
+}
+
+// GetCircleGuests はサークルの未統合のゲストを名前順に取得する
+func GetCircleGuests(circleID int) ([]CircleGuest, error) {
+	rows, err := db.Query(`
+		SELECT `+guestColumns+` FROM circle_guests
+		WHERE circle_id = $1 AND merged_user_id IS NULL
+		ORDER BY name, id
+	`, circleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guests := []CircleGuest{}
+	for rows.Next() {
+		guest, err := scanCircleGuest(rows)
+		if err != nil {
+			log.Printf("ゲストスキャンエラー: %v", err)
+			continue
+		}
+		guests = append(guests, *guest)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return guests, nil
+}
+
+// MarkGuestPaid はゲストの支払いを受け取り済みとして記録する（報告と承認を同時に行う）
+// サークルのイベントなら出納帳への記帳と監査ログの記録をする
+// イベントのゲストの未承認の参加者レコードでない場合はfalseを返す
+func MarkGuestPaid(eventID, participantID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE event_participants
+		SET paid = true, reported_at = COALESCE(reported_at, NOW()), approved_at = NOW()
+		WHERE id = $1 AND event_id = $2 AND guest_id IS NOT NULL AND approved_at IS NULL
+	`, participantID, eventID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := recordPaymentIncome(tx, participantID); err != nil {
+		return false, err
+	}
+	if err := recordParticipantAudit(tx, participantID, AuditGuestPaymentMarked); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// GuestMergeResult はゲストの統合結果
+type GuestMergeResult struct {
+	ParticipantIDs  []int `json:"participantIds"`  // 統合した参加者レコード
+	SkippedEventIDs []int `json:"skippedEventIds"` // ユーザーが既に参加者のため統合できなかったイベント
+}
+
+// MergeCircleGuest はゲストの参加履歴を登録ユーザーに付け替え、監査ログに記録する
+// 付け替えた参加者レコードは通常の参加者として扱う（guest_idを外す）
+// ユーザーが既に参加者になっているイベントは二重になるため付け替えない
+// ゲストが見つからないか統合済みの場合はnilを返す
+func MergeCircleGuest(circleID, guestID int, userID, actorID string) (*GuestMergeResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var guestName string
+	err = tx.QueryRow(`
+		UPDATE circle_guests SET merged_user_id = $3, merged_at = NOW()
+		WHERE id = $1 AND circle_id = $2 AND merged_user_id IS NULL
+		RETURNING name
+	`, guestID, circleID, userID).Scan(&guestName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge guest: %w", err)
+	}
+
+	result := &GuestMergeResult{ParticipantIDs: []int{}, SkippedEventIDs: []int{}}
+
+	rows, err := tx.Query(`
+		UPDATE event_participants ep
+		SET user_id = $2, guest_id = NULL, user_name = (
+			SELECT `+circleMemberName+`
+			FROM users u
+			JOIN user_circles uc ON u.user_id = uc.user_id
+			WHERE uc.user_id = $2 AND uc.circle_id = $3
+		)
+		WHERE ep.guest_id = $1
+		  AND NOT EXISTS (
+		      SELECT 1 FROM event_participants o WHERE o.event_id = ep.event_id AND o.user_id = $2
+		  )
+		RETURNING ep.id
+	`, guestID, userID, circleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge guest participants: %w", err)
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.ParticipantIDs = append(result.ParticipantIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT event_id FROM event_participants
+		WHERE guest_id = $1
+		ORDER BY event_id
+	`, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get skipped events: %w", err)
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.SkippedEventIDs = append(result.SkippedEventIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  actorID,
+		TargetID: &userID,
+		Action:   AuditGuestMerged,
+		Details: map[string]interface{}{
+			"guestId":         guestID,
+			"guestName":       guestName,
+			"participantIds":  result.ParticipantIDs,
+			"skippedEventIds": result.SkippedEventIDs,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[ゲスト統合] circle=%d, guest=%d, user=%s, participants=%d, skipped=%d",
+		circleID, guestID, userID, len(result.ParticipantIDs), len(result.SkippedEventIDs))
+	return result, nil
+}
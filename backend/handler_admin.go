@@ -65,6 +65,18 @@ func handleGetUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// handleGetMetrics はメトリクス取得
+func handleGetMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"metrics": metrics.Snapshot(),
+		"webhookQueue": gin.H{
+			"depth":    webhookDispatcher.QueueDepth(),
+			"capacity": webhookDispatcher.QueueCapacity(),
+		},
+	})
+}
+
 // ========== リッチメニュー管理ハンドラー ==========
 
 // handleRichMenuCreate はリッチメニューのセットアップエンドポイント
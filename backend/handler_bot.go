@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,7 +16,8 @@ import (
 
 // ========== Webhookハンドラー ==========
 
-// handleWebhook はLINE Webhookを処理（署名検証はミドルウェアで実施済み）
+// handleWebhook はLINE Webhookを受け付ける（署名検証はミドルウェアで実施済み）
+// イベントはワーカープールに渡して非同期に処理し、LINEにはすぐに200を返す
 func handleWebhook(c *gin.Context) {
 	var req WebhookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -26,29 +29,122 @@ func handleWebhook(c *gin.Context) {
 	log.Printf("Webhook受信（署名検証済み）")
 	log.Printf("イベント数: %d", len(req.Events))
 
+	rejected := 0
 	for _, event := range req.Events {
-		log.Printf("イベントタイプ: %s", event.Type)
+		if !webhookDispatcher.Enqueue(event) {
+			rejected++
+		}
+	}
+
+	if rejected > 0 {
+		// 受け付けられなかった場合は200以外を返してLINEに再送させる
+		// （投入済みのイベントは再送時に重複排除される）
+		log.Printf("Webhookキューが満杯のため%d件を受け付けられませんでした", rejected)
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// webhookEventClaimTimeout は処理中として記録したWebhookイベントを、処理が終わらなかったとみなすまでの時間
+// （処理中にプロセスが停止した場合、これを過ぎてから届いた再送は処理し直す）
+const webhookEventClaimTimeout = 5 * time.Minute
 
-		if event.Type == "message" && event.Message.Type == "text" {
-			userID := event.Source.UserID
-			messageText := event.Message.Text
-			replyToken := event.ReplyToken
+// processWebhookEvent は1件のWebhookイベントを処理する
+// 処理が終わってから処理済みとして記録し、panicした場合は記録を消して再送時に処理し直せるようにする
+func processWebhookEvent(event WebhookEvent) {
+	log.Printf("イベントタイプ: %s", event.Type)
 
-			log.Printf("メッセージ受信: UserID=%s", userID)
+	// 再送などで同じイベントが届いた場合は処理しない
+	if !claimWebhookEvent(event) {
+		return
+	}
 
-			// メッセージを記録（スレッドセーフ）
-			addReceivedMessage(ReceivedMessage{
-				Timestamp: time.Now(),
-				UserID:    userID,
-				Text:      messageText,
-			})
+	completed := false
+	defer func() {
+		finishWebhookEvent(event.WebhookEventID, completed)
+	}()
 
-			// handleMessage関数を利用
-			handleMessage(userID, messageText, replyToken)
+	dispatchWebhookEvent(event)
+	completed = true
+}
+
+// dispatchWebhookEvent はイベントの種類に応じた処理を呼び出す
+func dispatchWebhookEvent(event WebhookEvent) {
+	// 返信トークンが失効していた場合にPushで送り直せるよう送信元を記録
+	if event.ReplyToken != "" && event.Source.UserID != "" {
+		rememberReplyTarget(event.ReplyToken, event.Source.UserID)
+		defer forgetReplyTarget(event.ReplyToken)
+	}
+
+	if event.Type == "message" && event.Message.Type == "text" {
+		userID := event.Source.UserID
+		messageText := event.Message.Text
+		replyToken := event.ReplyToken
+
+		log.Printf("メッセージ受信: UserID=%s", userID)
+
+		// メッセージを記録（スレッドセーフ）
+		addReceivedMessage(ReceivedMessage{
+			Timestamp: time.Now(),
+			UserID:    userID,
+			Text:      messageText,
+		})
+
+		// handleMessage関数を利用
+		handleMessage(userID, messageText, replyToken)
+	}
+
+	if event.Type == "message" && event.Message.Type == "image" {
+		log.Printf("画像受信: UserID=%s", event.Source.UserID)
+		handleImageMessage(event.Source.UserID, event.Message, event.ReplyToken)
+	}
+
+	if event.Type == "postback" {
+		log.Printf("ポストバック受信: UserID=%s", event.Source.UserID)
+		handlePostback(event.Source.UserID, event.Postback.Data, event.ReplyToken)
+	}
+}
+
+// claimWebhookEvent はWebhookイベントを処理中として記録し、処理してよいかを返す
+// 処理済み、または他のワーカーが処理中の場合はfalseを返す
+func claimWebhookEvent(event WebhookEvent) bool {
+	if event.WebhookEventID == "" {
+		return true
+	}
+
+	claimed, err := ClaimWebhookEvent(event.WebhookEventID, event.DeliveryContext.IsRedelivery, webhookEventClaimTimeout)
+	if err != nil {
+		// 記録に失敗しても処理は継続する（取りこぼしよりは良い）
+		log.Printf("Webhookイベント記録エラー: %v", err)
+		return true
+	}
+
+	if !claimed {
+		log.Printf("重複イベントをスキップ: webhookEventId=%s (再送=%t)", event.WebhookEventID, event.DeliveryContext.IsRedelivery)
+		return false
+	}
+	return true
+}
+
+// finishWebhookEvent は処理が終わったWebhookイベントを処理済みにする
+// 処理が完了しなかった場合は記録を削除し、再送時に処理し直せるようにする
+func finishWebhookEvent(webhookEventID string, completed bool) {
+	if webhookEventID == "" {
+		return
+	}
+
+	if completed {
+		if err := CompleteWebhookEvent(webhookEventID); err != nil {
+			log.Printf("Webhookイベント記録エラー: %v", err)
 		}
+		return
 	}
 
-	c.Status(http.StatusOK)
+	if err := ReleaseWebhookEvent(webhookEventID); err != nil {
+		log.Printf("Webhookイベント記録の削除エラー: %v", err)
+	}
 }
 
 // ========== メッセージ処理 ==========
@@ -76,18 +172,38 @@ func handleMessage(userID, message, replyToken string) {
 		return
 	}
 
-	// 登録段階に応じて対応を変える
-	switch user.Step {
-	case 1:
-		handleNameInput(user, message, replyToken)
-	case 2:
-		handleCircleInput(user, message, replyToken)
-	case 3:
-		// 登録完了後のメッセージ処理
-		handleRegisteredUserMessage(user, message, replyToken)
-	default:
-		ReplyMessage(replyToken, "エラーが発生しました")
+	conv, err := GetConversation(userID)
+	if err != nil {
+		log.Printf("会話状態取得エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
+		return
+	}
+
+	// 「キャンセル」はどの状態からでも受け付ける
+	if message == cancelCommand {
+		handleCancel(user, conv, replyToken)
+		return
+	}
+
+	// 放置された会話はリセット
+	if conv != nil && conv.Expired {
+		handleConversationTimeout(user, replyToken)
+		return
+	}
+
+	// 入力待ちの状態があればその処理に渡す
+	if conv != nil && dispatchConversation(user, conv, message, replyToken) {
+		return
+	}
+
+	// 登録途中で会話状態が失われた場合は名前入力からやり直す
+	if user.Step != 3 {
+		restartUserRegistration(user, replyToken, "")
+		return
 	}
+
+	// 登録完了後のメッセージ処理
+	handleRegisteredUserMessage(user, message, replyToken)
 }
 
 // ========== ユーザー登録フロー ==========
@@ -97,7 +213,6 @@ func startUserRegistration(userID, replyToken string) {
 	newUser := &User{
 		UserID: userID,
 		Name:   "",
-		Circle: "",
 		Step:   1,
 	}
 	if err := SaveUser(newUser); err != nil {
@@ -105,11 +220,32 @@ func startUserRegistration(userID, replyToken string) {
 		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
 		return
 	}
+	if err := setConversation(userID, stateRegisterName, nil); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
+		return
+	}
 	ReplyMessage(replyToken, "初めまして！お名前を教えてください！")
 }
 
+// restartUserRegistration は登録途中のユーザーの登録を名前入力からやり直す
+func restartUserRegistration(user *User, replyToken, notice string) {
+	user.Step = 1
+	if err := UpdateUser(user); err != nil {
+		log.Printf("ユーザー更新エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
+		return
+	}
+	if err := setConversation(user.UserID, stateRegisterName, nil); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
+		return
+	}
+	ReplyMessage(replyToken, notice+"お名前を教えてください！")
+}
+
 // handleNameInput は名前入力処理
-func handleNameInput(user *User, name, replyToken string) {
+func handleNameInput(user *User, conv *Conversation, name, replyToken string) {
 	user.Name = name
 	user.Step = 2
 	if err := UpdateUser(user); err != nil {
@@ -117,6 +253,11 @@ func handleNameInput(user *User, name, replyToken string) {
 		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
 		return
 	}
+	if err := setConversation(user.UserID, stateRegisterCircleChoice, nil); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
+		return
+	}
 
 	// サークル作成/参加の選択肢を表示
 	buttons := []QuickReplyButton{
@@ -146,13 +287,12 @@ func handleNameInput(user *User, name, replyToken string) {
 }
 
 // handleCircleInput はサークル名入力処理
-func handleCircleInput(user *User, message, replyToken string) {
+func handleCircleInput(user *User, conv *Conversation, message, replyToken string) {
 	// サークル作成/参加の選択をハンドリング
 	switch message {
 	case "サークル:新規作成":
-		user.SplitEventStep = 1 // 新規作成モード
-		if err := UpdateUser(user); err != nil {
-			log.Printf("ユーザー更新エラー: %v", err)
+		if err := setConversation(user.UserID, stateRegisterCircleCreate, nil); err != nil {
+			log.Printf("会話状態保存エラー: %v", err)
 			ReplyMessage(replyToken, "エラーが発生しました。")
 			return
 		}
@@ -160,25 +300,31 @@ func handleCircleInput(user *User, message, replyToken string) {
 		return
 
 	case "サークル:既存参加":
-		user.SplitEventStep = 2 // 既存参加モード
-		if err := UpdateUser(user); err != nil {
-			log.Printf("ユーザー更新エラー: %v", err)
+		if err := setConversation(user.UserID, stateRegisterCircleJoin, nil); err != nil {
+			log.Printf("会話状態保存エラー: %v", err)
 			ReplyMessage(replyToken, "エラーが発生しました。")
 			return
 		}
-		ReplyMessage(replyToken, "参加するサークル名を入力してください：\n（サークル名は完全一致で検索されます）")
+		ReplyMessage(replyToken, "参加するサークル名を入力してください：\n（サークル名は完全一致で検索されます）\n\n招待コードをお持ちの場合は「招待コード:〇〇」と送信してください。")
+		return
+	}
+
+	// 招待コードでの参加はどのモードでも受け付ける
+	if strings.HasPrefix(message, inviteCodePrefix) {
+		handleInviteCodeRegistration(user, strings.TrimPrefix(message, inviteCodePrefix), replyToken)
 		return
 	}
 
 	// サークル名の入力をハンドリング
-	if user.SplitEventStep == 1 {
+	switch conv.State {
+	case stateRegisterCircleCreate:
 		// 新規作成モード
 		handleCircleCreate(user, message, replyToken)
-	} else if user.SplitEventStep == 2 {
+	case stateRegisterCircleJoin:
 		// 既存参加モード
 		handleCircleJoin(user, message, replyToken)
-	} else {
-		// モードが設定されていない場合（レガシー互換）
+	default:
+		// モードが選択されていない場合（レガシー互換）
 		handleCircleLegacy(user, message, replyToken)
 	}
 }
@@ -201,13 +347,12 @@ func handleCircleCreate(user *User, circleName, replyToken string) {
 	}
 
 	// ユーザー情報を更新
-	user.Circle = circleName
 	user.PrimaryCircleID = &circle.ID
 	user.Step = 3
-	user.SplitEventStep = 0
 	if err := UpdateUser(user); err != nil {
 		log.Printf("ユーザー更新エラー: %v", err)
 	}
+	clearConversation(user.UserID)
 
 	text := fmt.Sprintf("登録完了しました！\n\n名前: %s\nサークル: %s（新規作成）\n\nこれから CirclePay をご利用いただけます！", user.Name, circleName)
 	showMainMenu(user, replyToken, text)
@@ -242,10 +387,13 @@ func handleCircleJoin(user *User, circleName, replyToken string) {
 		return
 	}
 
-	// サークルに参加
-	if err := JoinCircle(user.UserID, circle.ID); err != nil {
-		if err.Error() == "already a member of this circle" {
+	// サークルに参加（非公開サークルは参加申請）
+	requested, err := joinCircleOrRequest(user.UserID, circle)
+	if err != nil {
+		if errors.Is(err, errAlreadyCircleMember) {
 			ReplyMessage(replyToken, "既にこのサークルに参加しています。")
+		} else if errors.Is(err, errMembershipSuspended) {
+			ReplyMessage(replyToken, suspendedMembershipMessage)
 		} else {
 			log.Printf("サークル参加エラー: %v", err)
 			ReplyMessage(replyToken, "エラーが発生しました。もう一度お試しください。")
@@ -253,22 +401,53 @@ func handleCircleJoin(user *User, circleName, replyToken string) {
 		return
 	}
 
+	if requested {
+		completeJoinRequestRegistration(user, circle, replyToken)
+		return
+	}
+	completeCircleJoinRegistration(user, circle, replyToken)
+}
+
+// completeCircleJoinRegistration は既存サークル参加後に登録を完了する
+func completeCircleJoinRegistration(user *User, circle *Circle, replyToken string) {
 	// ユーザー情報を更新
-	user.Circle = circleName
 	user.PrimaryCircleID = &circle.ID
 	user.Step = 3
-	user.SplitEventStep = 0
 	if err := UpdateUser(user); err != nil {
 		log.Printf("ユーザー更新エラー: %v", err)
 	}
+	clearConversation(user.UserID)
 
 	// メンバー数を取得
 	memberCount, _ := GetCircleMemberCount(circle.ID)
 
-	text := fmt.Sprintf("登録完了しました！\n\n名前: %s\nサークル: %s（%d人参加中）\n\nこれから CirclePay をご利用いただけます！", user.Name, circleName, memberCount)
+	text := fmt.Sprintf("登録完了しました！\n\n名前: %s\nサークル: %s（%d人参加中）\n\nこれから CirclePay をご利用いただけます！", user.Name, circle.Name, memberCount)
+	showMainMenu(user, replyToken, text)
+}
+
+// completeJoinRequestRegistration は非公開サークルへの参加申請後に登録を完了する
+// サークルへの参加は管理者の承認後になる
+func completeJoinRequestRegistration(user *User, circle *Circle, replyToken string) {
+	user.Step = 3
+	if err := UpdateUser(user); err != nil {
+		log.Printf("ユーザー更新エラー: %v", err)
+	}
+	clearConversation(user.UserID)
+
+	text := fmt.Sprintf("登録完了しました！\n\n名前: %s\n\n「%s」は承認制のサークルのため、参加申請を送信しました。管理者が承認するとお知らせします。", user.Name, circle.Name)
 	showMainMenu(user, replyToken, text)
 }
 
+// handleInviteCodeRegistration は登録中に招待コードでサークルに参加する
+func handleInviteCodeRegistration(user *User, code, replyToken string) {
+	circle, err := redeemCircleInvite(user.UserID, code)
+	if err != nil {
+		replyInviteError(err, replyToken)
+		return
+	}
+	completeCircleJoinRegistration(user, circle, replyToken)
+}
+
 // handleCircleLegacy はレガシー互換のサークル処理（直接サークル名入力）
 func handleCircleLegacy(user *User, circleName, replyToken string) {
 	// サークルを取得または作成
@@ -279,11 +458,25 @@ func handleCircleLegacy(user *User, circleName, replyToken string) {
 		return
 	}
 
-	// サークルに参加
-	JoinCircle(user.UserID, circle.ID)
+	// サークルに参加（非公開サークルは参加申請）
+	// 新規作成した場合は作成時に参加済みなので、既に参加している場合はそのまま登録を完了する
+	requested, err := joinCircleOrRequest(user.UserID, circle)
+	switch {
+	case errors.Is(err, errAlreadyCircleMember):
+	case errors.Is(err, errMembershipSuspended):
+		ReplyMessage(replyToken, suspendedMembershipMessage)
+		return
+	case err != nil:
+		log.Printf("サークル参加エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。もう一度お試しください。")
+		return
+	}
+	if requested {
+		completeJoinRequestRegistration(user, circle, replyToken)
+		return
+	}
 
 	// ユーザー情報を更新
-	user.Circle = circleName
 	user.PrimaryCircleID = &circle.ID
 	user.Step = 3
 	if err := UpdateUser(user); err != nil {
@@ -291,8 +484,9 @@ func handleCircleLegacy(user *User, circleName, replyToken string) {
 		ReplyMessage(replyToken, "エラーが発生しました。")
 		return
 	}
+	clearConversation(user.UserID)
 
-	text := fmt.Sprintf("登録完了しました！\n名前: %s\nサークル: %s\n\nこれから CirclePay をご利用いただけます！", user.Name, user.Circle)
+	text := fmt.Sprintf("登録完了しました！\n名前: %s\nサークル: %s\n\nこれから CirclePay をご利用いただけます！", user.Name, circle.Name)
 	showMainMenu(user, replyToken, text)
 }
 
@@ -312,6 +506,12 @@ func handleRegisteredUserMessage(user *User, message, replyToken string) {
 		return
 	}
 
+	// 招待コードのハンドリング
+	if strings.HasPrefix(message, inviteCodePrefix) {
+		handleInviteCodeJoin(user, strings.TrimPrefix(message, inviteCodePrefix), replyToken)
+		return
+	}
+
 	// サークル参加のハンドリング
 	if strings.HasPrefix(message, "サークル参加:") {
 		circleName := strings.TrimPrefix(message, "サークル参加:")
@@ -325,6 +525,8 @@ func handleRegisteredUserMessage(user *User, message, replyToken string) {
 		handlePaymentReport(user, replyToken)
 	case "📊 状況確認":
 		showMyPaymentStatus(user, replyToken)
+	case "📝 割り勘作成":
+		startSplitEvent(user, replyToken)
 	case "👤 会計者になる":
 		sendLIFFButton(user, replyToken)
 	case "🔄 サークル追加":
@@ -341,7 +543,7 @@ func handleRegisteredUserMessage(user *User, message, replyToken string) {
 
 // showCircleAddMenu はサークル追加メニューを表示
 func showCircleAddMenu(user *User, replyToken string) {
-	ReplyMessage(replyToken, "追加で参加するサークル名を入力してください：\n\n（「サークル参加:〇〇」の形式で送信）\n例: サークル参加:テニスサークル")
+	ReplyMessage(replyToken, "追加で参加するサークル名を入力してください：\n\n（「サークル参加:〇〇」の形式で送信）\n例: サークル参加:テニスサークル\n\n招待コードをお持ちの場合は「招待コード:〇〇」と送信してください。")
 }
 
 // showUserCircles はユーザーの所属サークル一覧を表示
@@ -404,9 +606,12 @@ func handleAdditionalCircleJoin(user *User, circleName, replyToken string) {
 		return
 	}
 
-	if err := JoinCircle(user.UserID, circle.ID); err != nil {
-		if err.Error() == "already a member of this circle" {
+	requested, err := joinCircleOrRequest(user.UserID, circle)
+	if err != nil {
+		if errors.Is(err, errAlreadyCircleMember) {
 			ReplyMessage(replyToken, "既にこのサークルに参加しています。")
+		} else if errors.Is(err, errMembershipSuspended) {
+			ReplyMessage(replyToken, suspendedMembershipMessage)
 		} else {
 			log.Printf("サークル参加エラー: %v", err)
 			ReplyMessage(replyToken, "エラーが発生しました")
@@ -414,10 +619,42 @@ func handleAdditionalCircleJoin(user *User, circleName, replyToken string) {
 		return
 	}
 
+	if requested {
+		ReplyMessage(replyToken, fmt.Sprintf("「%s」は承認制のサークルです。\n参加申請を送信しました。管理者が承認するとお知らせします。", circle.Name))
+		return
+	}
+
 	memberCount, _ := GetCircleMemberCount(circle.ID)
 	ReplyMessage(replyToken, fmt.Sprintf("「%s」に参加しました！（%d人参加中）", circleName, memberCount))
 }
 
+// handleInviteCodeJoin は招待コードでの追加サークル参加処理
+func handleInviteCodeJoin(user *User, code, replyToken string) {
+	circle, err := redeemCircleInvite(user.UserID, code)
+	if err != nil {
+		replyInviteError(err, replyToken)
+		return
+	}
+
+	memberCount, _ := GetCircleMemberCount(circle.ID)
+	ReplyMessage(replyToken, fmt.Sprintf("「%s」に参加しました！（%d人参加中）", circle.Name, memberCount))
+}
+
+// replyInviteError は招待コード参加の失敗理由を返信する
+func replyInviteError(err error, replyToken string) {
+	switch {
+	case errors.Is(err, errInvalidInvite):
+		ReplyMessage(replyToken, "招待コードが無効か、期限切れです。\nサークルの管理者に新しい招待コードを確認してください。")
+	case errors.Is(err, errAlreadyCircleMember):
+		ReplyMessage(replyToken, "既にこのサークルに参加しています。")
+	case errors.Is(err, errMembershipSuspended):
+		ReplyMessage(replyToken, suspendedMembershipMessage)
+	default:
+		log.Printf("招待コード参加エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+	}
+}
+
 // ========== メインメニュー表示 ==========
 
 // showMainMenu はQuick Replyでメインメニューを表示
@@ -447,6 +684,14 @@ func showMainMenu(user *User, replyToken, messageText string) {
 				Text:  "📋 サークル一覧",
 			},
 		},
+		{
+			Type: "action",
+			Action: ActionObject{
+				Type:  "message",
+				Label: "📝 割り勘作成",
+				Text:  "📝 割り勘作成",
+			},
+		},
 		{
 			Type: "action",
 			Action: ActionObject{
@@ -497,7 +742,12 @@ func handlePaymentReport(user *User, replyToken string) {
 
 // handlePaymentConfirm は支払い確定処理
 func handlePaymentConfirm(user *User, eventID int, replyToken string) {
-	if err := ReportPayment(eventID, user.UserID); err != nil {
+	participantID, err := ReportPayment(eventID, user.UserID)
+	if err == sql.ErrNoRows {
+		ReplyMessage(replyToken, "このイベントの参加者ではありません")
+		return
+	}
+	if err != nil {
 		log.Printf("支払い報告エラー: %v", err)
 		ReplyMessage(replyToken, "エラーが発生しました")
 		return
@@ -511,39 +761,118 @@ func handlePaymentConfirm(user *User, eventID int, replyToken string) {
 		return
 	}
 
-	// 会計者に通知（非同期）
-	go func() {
-		notifyText := fmt.Sprintf("💰 支払い報告\n\n%sさんが「%s」の支払いを報告しました。\n\n承認画面から確認してください。",
-			user.Name, event.EventName)
-		PushMessage(event.OrganizerID, notifyText)
-	}()
+	// 会計者に承認ボタン付きで通知（非同期）
+	go notifyOrganizerOfPaymentReport(user, event, participantID)
 
-	ReplyMessage(replyToken, "支払いを報告しました！会計者の承認をお待ちください。")
+	ReplyMessage(replyToken, "支払いを報告しました！会計者の承認をお待ちください。\n\n振込明細などの画像を送ると、支払いの証拠として添付できます。")
+}
+
+// handleImageMessage は受信した画像を最後に報告した承認待ちの支払いに証拠として添付する
+func handleImageMessage(userID string, message Message, replyToken string) {
+	user, err := GetUser(userID)
+	if err != nil {
+		log.Printf("ユーザー取得エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました。しばらくしてからもう一度お試しください。")
+		return
+	}
+	if user == nil || user.Step != 3 {
+		ReplyMessage(replyToken, "先にユーザー登録を完了してください。")
+		return
+	}
+
+	// 外部URLの画像はコンテンツ取得APIで取得できない
+	if message.ContentProvider.Type != "" && message.ContentProvider.Type != "line" {
+		ReplyMessage(replyToken, "この画像は受け付けられません。端末に保存した画像を送ってください。")
+		return
+	}
+
+	target, err := GetLatestPendingReport(user.UserID)
+	if err != nil {
+		log.Printf("支払い報告取得エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+	if target == nil {
+		ReplyMessage(replyToken, "承認待ちの支払い報告がありません。\n「💰 支払いました」から報告してから画像を送ってください。")
+		return
+	}
+
+	data, err := GetMessageContent(message.ID, maxPaymentProofSize)
+	if errors.Is(err, errContentTooLarge) {
+		ReplyMessage(replyToken, "画像は5MB以下にしてください。")
+		return
+	}
+	if err != nil {
+		log.Printf("画像取得エラー: %v", err)
+		ReplyMessage(replyToken, "画像を受け取れませんでした。もう一度送ってください。")
+		return
+	}
+
+	proof, err := newPaymentProof(data)
+	if errors.Is(err, errUnsupportedImage) {
+		ReplyMessage(replyToken, "PNG・JPEG・GIF・WebPの画像を送ってください。")
+		return
+	}
+	if err != nil {
+		log.Printf("証拠画像作成エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	saved, err := SavePaymentProof(target.ParticipantID, proof)
+	if err != nil {
+		log.Printf("証拠画像保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+	if !saved {
+		ReplyMessage(replyToken, "この支払いは既に処理されています。")
+		return
+	}
+
+	go notifyOrganizerOfPaymentProof(user, target)
+
+	ReplyMessage(replyToken, fmt.Sprintf("「%s」の支払い報告に画像を添付しました。", target.EventName))
 }
 
 // ========== 状況確認 ==========
 
-// showMyPaymentStatus は自分の支払い状況を表示
+// recentPaymentStatusCount はBotで表示する支払い状況の件数（それより前はLIFFの履歴で確認する）
+const recentPaymentStatusCount = 10
+
+// showMyPaymentStatus は自分の直近の支払い状況を表示
 func showMyPaymentStatus(user *User, replyToken string) {
-	statuses, err := GetUserPaymentStatus(user.UserID)
+	payments, err := GetMyPaymentHistory(user.UserID, 0, recentPaymentStatusCount)
 	if err != nil {
 		log.Printf("ステータス取得エラー: %v", err)
 		ReplyMessage(replyToken, "エラーが発生しました")
 		return
 	}
 
-	if len(statuses) == 0 {
+	if len(payments) == 0 {
 		ReplyMessage(replyToken, "参加中のイベントはありません")
 		return
 	}
 
 	var status string
-	for _, s := range statuses {
-		paidStatus := "✅ 支払い済み"
-		if !s.Paid {
+	for _, p := range payments {
+		var paidStatus string
+		switch p.State {
+		case paymentStateApproved:
+			paidStatus = "✅ 支払い済み（" + p.ApprovedAt.Format("1/2") + "）"
+		case paymentStateReported:
+			paidStatus = "📨 承認待ち"
+			if p.ReportedAt != nil {
+				paidStatus += "（" + p.ReportedAt.Format("1/2") + "報告）"
+			}
+		default:
 			paidStatus = "⏳ 未払い"
 		}
-		status += fmt.Sprintf("・%s: %d円 %s\n", s.EventName, s.Amount, paidStatus)
+		status += fmt.Sprintf("・%s %s: %s円 %s\n", p.CreatedAt.Format("1/2"), p.EventName, formatAmount(p.Amount), paidStatus)
+	}
+
+	if len(payments) == recentPaymentStatusCount {
+		status += "\nそれ以前の履歴: " + paymentHistoryURL()
 	}
 
 	ReplyMessage(replyToken, "【あなたの支払い状況】\n\n"+status)
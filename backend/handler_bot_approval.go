@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+)
+
+// ========== Botでの支払い承認 ==========
+
+// ポストバックのアクション名
+const (
+	postbackApprovePayment = "payment_approve"
+	postbackRejectPayment  = "payment_reject"
+)
+
+// templateTextMaxLen はボタンテンプレートの本文の最大文字数
+const templateTextMaxLen = 160
+
+// handlePostback はポストバックイベントを処理する
+func handlePostback(userID, data, replyToken string) {
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		log.Printf("ポストバック解析エラー: %v (data=%s)", err, data)
+		return
+	}
+
+	switch values.Get("action") {
+	case postbackApprovePayment, postbackRejectPayment:
+		participantID, err := strconv.Atoi(values.Get("participant"))
+		if err != nil {
+			log.Printf("ポストバックの参加者IDが不正: %s", data)
+			ReplyMessage(replyToken, "無効な操作です")
+			return
+		}
+		handlePaymentDecision(userID, participantID, values.Get("action") == postbackApprovePayment, replyToken)
+	case postbackApproveJoin, postbackDenyJoin:
+		requestID, err := strconv.Atoi(values.Get("request"))
+		if err != nil {
+			log.Printf("ポストバックの申請IDが不正: %s", data)
+			ReplyMessage(replyToken, "無効な操作です")
+			return
+		}
+		handleJoinRequestDecision(userID, requestID, values.Get("action") == postbackApproveJoin, replyToken)
+	default:
+		log.Printf("不明なポストバック: %s", data)
+	}
+}
+
+// handlePaymentDecision はトーク上の承認・差し戻しボタンを処理する
+func handlePaymentDecision(userID string, participantID int, approve bool, replyToken string) {
+	var done bool
+	var err error
+	if approve {
+		done, err = approvePayment(participantID, userID)
+	} else {
+		done, err = rejectPayment(participantID, userID)
+	}
+
+	if errors.Is(err, errNotOrganizer) {
+		log.Printf("承認権限なし: %s", userID)
+		ReplyMessage(replyToken, "このイベントの会計者ではないため操作できません。")
+		return
+	}
+	if err != nil {
+		log.Printf("承認処理エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+	if !done {
+		ReplyMessage(replyToken, "この支払い報告は既に処理済みです。")
+		return
+	}
+
+	info, err := GetApprovalNotifyInfo(participantID)
+	if err != nil {
+		log.Printf("承認情報取得エラー: %v", err)
+		ReplyMessage(replyToken, "処理しました。")
+		return
+	}
+
+	if approve {
+		ReplyMessage(replyToken, fmt.Sprintf("✅ %sさんの「%s」（%d円）の支払いを承認しました。", info.ParticipantName, info.EventName, info.SplitAmount))
+	} else {
+		ReplyMessage(replyToken, fmt.Sprintf("↩️ %sさんの「%s」の支払い報告を差し戻しました。", info.ParticipantName, info.EventName))
+	}
+}
+
+// notifyOrganizerOfPaymentReport は会計者に支払い報告を承認ボタン付きで通知する
+func notifyOrganizerOfPaymentReport(reporter *User, event *Event, participantID int) {
+	text := fmt.Sprintf("💰 支払い報告\n\n%sさんが「%s」（%d円）の支払いを報告しました。",
+		reporter.Name, event.EventName, event.SplitAmount)
+
+	content := ButtonsTemplateContent{
+		AltText: text,
+		Text:    truncateText(text, templateTextMaxLen),
+		Actions: []ActionObject{
+			{
+				Type:        "postback",
+				Label:       "✅ 承認する",
+				Data:        fmt.Sprintf("action=%s&participant=%d", postbackApprovePayment, participantID),
+				DisplayText: "承認する",
+			},
+			{
+				Type:        "postback",
+				Label:       "↩️ 差し戻す",
+				Data:        fmt.Sprintf("action=%s&participant=%d", postbackRejectPayment, participantID),
+				DisplayText: "差し戻す",
+			},
+		},
+	}
+
+	if err := SendMessage(PushDelivery{event.OrganizerID}, content); err != nil {
+		log.Printf("支払い報告通知エラー: %v", err)
+		// ボタン付きで送れなかった場合はテキストで通知
+		PushMessage(event.OrganizerID, text+"\n\n承認画面から確認してください。")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ========== Botでのサークル参加申請の承認 ==========
+
+// 参加申請のポストバックのアクション名
+const (
+	postbackApproveJoin = "join_approve"
+	postbackDenyJoin    = "join_deny"
+)
+
+// handleJoinRequestDecision はトーク上の参加申請の承認・却下ボタンを処理する
+func handleJoinRequestDecision(userID string, requestID int, approve bool, replyToken string) {
+	request, done, err := decideJoinRequest(requestID, userID, approve)
+
+	if errors.Is(err, errJoinRequestNotFound) {
+		ReplyMessage(replyToken, "参加申請が見つかりません。")
+		return
+	}
+	if errors.Is(err, errNotCircleManager) {
+		log.Printf("参加申請の承認権限なし: %s", userID)
+		ReplyMessage(replyToken, "サークルのオーナー・会計ではないため操作できません。")
+		return
+	}
+	if err != nil {
+		log.Printf("参加申請処理エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+	if !done {
+		ReplyMessage(replyToken, "この参加申請は既に処理済みです。")
+		return
+	}
+
+	name := request.UserName
+	if name == "" {
+		name = "申請者"
+	}
+	if approve {
+		ReplyMessage(replyToken, fmt.Sprintf("✅ %sさんの参加を承認しました。", name))
+	} else {
+		ReplyMessage(replyToken, fmt.Sprintf("❌ %sさんの参加申請を却下しました。", name))
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ========== Botでの割り勘イベント作成フロー ==========
+
+// 割り勘作成フローの会話状態
+const (
+	stateSplitCircle       = "split_circle"       // サークル選択待ち
+	stateSplitName         = "split_name"         // イベント名待ち
+	stateSplitAmount       = "split_amount"       // 合計金額待ち
+	stateSplitParticipants = "split_participants" // 参加者選択中
+	stateSplitConfirm      = "split_confirm"      // 作成確認待ち
+)
+
+const (
+	// splitMemberPageSize は参加者選択で1ページに表示するメンバー数
+	// Quick Replyは最大13個なので、操作ボタン4個を引いた数
+	splitMemberPageSize = 9
+
+	// maxSplitTotalAmount は入力できる合計金額の上限
+	maxSplitTotalAmount = 10000000
+
+	// quickReplyLabelMaxLen はQuick Replyのラベルの最大文字数
+	quickReplyLabelMaxLen = 20
+)
+
+// splitDraft は作成中の割り勘イベント（会話状態のペイロード）
+type splitDraft struct {
+	CircleID    int           `json:"circleId"`
+	CircleName  string        `json:"circleName"`
+	EventName   string        `json:"eventName,omitempty"`
+	TotalAmount int           `json:"totalAmount,omitempty"`
+	Members     []splitMember `json:"members,omitempty"`  // 選択候補（表示順）
+	Selected    []int         `json:"selected,omitempty"` // 選択済みメンバーのインデックス
+	Page        int           `json:"page"`
+}
+
+// splitMember は参加者候補
+type splitMember struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+}
+
+// isSelected は指定インデックスのメンバーが選択済みか判定する
+func (d *splitDraft) isSelected(index int) bool {
+	for _, i := range d.Selected {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// toggle は指定インデックスのメンバーの選択を切り替える
+func (d *splitDraft) toggle(index int) {
+	for n, i := range d.Selected {
+		if i == index {
+			d.Selected = append(d.Selected[:n], d.Selected[n+1:]...)
+			return
+		}
+	}
+	d.Selected = append(d.Selected, index)
+}
+
+// pageCount は参加者選択のページ数
+func (d *splitDraft) pageCount() int {
+	return (len(d.Members) + splitMemberPageSize - 1) / splitMemberPageSize
+}
+
+// selectedMembers は選択済みメンバーを表示順で取得する
+func (d *splitDraft) selectedMembers() []splitMember {
+	var members []splitMember
+	for i, m := range d.Members {
+		if d.isSelected(i) {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// startSplitEvent は割り勘イベント作成フローを開始する
+func startSplitEvent(user *User, replyToken string) {
+	circles, err := GetUserCircles(user.UserID)
+	if err != nil {
+		log.Printf("サークル取得エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	if len(circles) == 0 {
+		ReplyMessage(replyToken, "所属しているサークルがありません。\n先にサークルに参加してください。")
+		return
+	}
+
+	// 所属サークルが1つならそのまま進む
+	if len(circles) == 1 {
+		startSplitEventInCircle(user, &circles[0], replyToken)
+		return
+	}
+
+	if err := setConversation(user.UserID, stateSplitCircle, nil); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	var buttons []QuickReplyButton
+	for _, c := range circles {
+		if len(buttons) >= 13 {
+			break
+		}
+		buttons = append(buttons, messageButton(c.Name, c.Name))
+	}
+
+	msg := "割り勘イベントを作成します！\nどのサークルのイベントですか？\n\n（中止するときは「キャンセル」と送信）"
+	if err := ReplyMessageWithQuickReply(replyToken, msg, buttons); err != nil {
+		log.Printf("Quick Reply送信エラー: %v", err)
+		ReplyMessage(replyToken, msg+"\n\nサークル名を入力してください。")
+	}
+}
+
+// startSplitEventInCircle はサークルを決めてイベント名の入力に進む
+func startSplitEventInCircle(user *User, circle *Circle, replyToken string) {
+	allowed, err := checkCircleEventPermission(user.UserID, circle.ID)
+	if err != nil {
+		log.Printf("イベント作成権限確認エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+	if !allowed {
+		clearConversation(user.UserID)
+		showMainMenu(user, replyToken, fmt.Sprintf("「%s」ではオーナーと会計のみがイベントを作成できます。", circle.Name))
+		return
+	}
+
+	draft := splitDraft{CircleID: circle.ID, CircleName: circle.Name}
+	if err := setConversation(user.UserID, stateSplitName, draft); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	ReplyMessage(replyToken, fmt.Sprintf("割り勘イベントを作成します！（サークル: %s）\n\nイベント名を教えてください（例: 新歓飲み会）\n\n（中止するときは「キャンセル」と送信）", circle.Name))
+}
+
+// handleSplitCircleInput はサークル選択処理
+func handleSplitCircleInput(user *User, conv *Conversation, message, replyToken string) {
+	circles, err := GetUserCircles(user.UserID)
+	if err != nil {
+		log.Printf("サークル取得エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	for i := range circles {
+		if circles[i].Name == message {
+			startSplitEventInCircle(user, &circles[i], replyToken)
+			return
+		}
+	}
+
+	ReplyMessage(replyToken, "所属しているサークルの名前を選択してください。\n（中止するときは「キャンセル」と送信）")
+}
+
+// handleSplitNameInput はイベント名入力処理
+func handleSplitNameInput(user *User, conv *Conversation, message, replyToken string) {
+	var draft splitDraft
+	if err := conv.Decode(&draft); err != nil {
+		resetBrokenSplitDraft(user, replyToken, err)
+		return
+	}
+
+	draft.EventName = message
+	if err := setConversation(user.UserID, stateSplitAmount, draft); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	ReplyMessage(replyToken, fmt.Sprintf("イベント名: %s\n\n合計金額を教えてください（数字のみ、例: 15000）", draft.EventName))
+}
+
+// handleSplitAmountInput は合計金額入力処理
+func handleSplitAmountInput(user *User, conv *Conversation, message, replyToken string) {
+	var draft splitDraft
+	if err := conv.Decode(&draft); err != nil {
+		resetBrokenSplitDraft(user, replyToken, err)
+		return
+	}
+
+	amount, ok := parseAmount(message)
+	if !ok || amount <= 0 || amount > maxSplitTotalAmount {
+		ReplyMessage(replyToken, "正しい金額を入力してください（数字のみ、例: 15000）")
+		return
+	}
+
+	// 参加者候補（自分以外のサークルメンバー）を取得
+	members, err := GetCircleMembers(draft.CircleID, user.UserID)
+	if err != nil {
+		log.Printf("メンバー取得エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	if len(members) == 0 {
+		clearConversation(user.UserID)
+		showMainMenu(user, replyToken, fmt.Sprintf("「%s」には他のメンバーがいないため、割り勘を作成できません。", draft.CircleName))
+		return
+	}
+
+	draft.TotalAmount = amount
+	draft.Members = nil
+	for _, m := range members {
+		draft.Members = append(draft.Members, splitMember{UserID: m.UserID, Name: m.Name})
+	}
+	draft.Selected = nil
+	draft.Page = 0
+
+	if err := setConversation(user.UserID, stateSplitParticipants, draft); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	showSplitParticipantPage(&draft, replyToken, fmt.Sprintf("合計金額: %s円\n\n", formatAmount(amount)))
+}
+
+// handleSplitParticipantInput は参加者選択処理
+// 「参加者:番号」で選択を切り替え、前へ/次へでページ移動、決定で確認に進む
+func handleSplitParticipantInput(user *User, conv *Conversation, message, replyToken string) {
+	var draft splitDraft
+	if err := conv.Decode(&draft); err != nil {
+		resetBrokenSplitDraft(user, replyToken, err)
+		return
+	}
+
+	command := strings.TrimPrefix(message, "参加者:")
+	switch command {
+	case "前へ":
+		if draft.Page > 0 {
+			draft.Page--
+		}
+	case "次へ":
+		if draft.Page < draft.pageCount()-1 {
+			draft.Page++
+		}
+	case "全員":
+		draft.Selected = nil
+		for i := range draft.Members {
+			draft.Selected = append(draft.Selected, i)
+		}
+	case "決定":
+		if len(draft.Selected) == 0 {
+			showSplitParticipantPage(&draft, replyToken, "参加者を1人以上選択してください。\n\n")
+			return
+		}
+		if err := setConversation(user.UserID, stateSplitConfirm, draft); err != nil {
+			log.Printf("会話状態保存エラー: %v", err)
+			ReplyMessage(replyToken, "エラーが発生しました")
+			return
+		}
+		showSplitConfirm(&draft, replyToken)
+		return
+	default:
+		// 番号（スペース区切りで複数可）で選択を切り替える
+		toggled := false
+		for _, field := range strings.Fields(command) {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(draft.Members) {
+				continue
+			}
+			draft.toggle(n - 1)
+			toggled = true
+		}
+		if !toggled {
+			showSplitParticipantPage(&draft, replyToken, "番号をタップして参加者を選択してください。\n\n")
+			return
+		}
+	}
+
+	if err := setConversation(user.UserID, stateSplitParticipants, draft); err != nil {
+		log.Printf("会話状態保存エラー: %v", err)
+		ReplyMessage(replyToken, "エラーが発生しました")
+		return
+	}
+
+	showSplitParticipantPage(&draft, replyToken, "")
+}
+
+// handleSplitConfirmInput は作成確認処理
+func handleSplitConfirmInput(user *User, conv *Conversation, message, replyToken string) {
+	var draft splitDraft
+	if err := conv.Decode(&draft); err != nil {
+		resetBrokenSplitDraft(user, replyToken, err)
+		return
+	}
+
+	switch message {
+	case "作成する":
+		// 確認中にサークルを抜けたり権限が変わったりしていないか再確認
+		allowed, err := checkCircleEventPermission(user.UserID, draft.CircleID)
+		if err != nil || !allowed {
+			clearConversation(user.UserID)
+			showMainMenu(user, replyToken, "このサークルでイベントを作成する権限がないため、割り勘を作成できませんでした。")
+			return
+		}
+
+		var participantIDs []string
+		for _, m := range draft.selectedMembers() {
+			participantIDs = append(participantIDs, m.UserID)
+		}
+
+		// 選択後にサークルを抜けたメンバーがいないか確認
+		nonMembers, err := findNonMemberParticipants(draft.CircleID, participantIDs)
+		if err != nil {
+			log.Printf("参加者確認エラー: %v", err)
+			ReplyMessage(replyToken, "エラーが発生しました。もう一度「作成する」と送信してください。")
+			return
+		}
+		if len(nonMembers) > 0 {
+			ReplyMessage(replyToken, "サークルを退出したメンバーが含まれています。\n「参加者を選び直す」から選択し直してください。")
+			return
+		}
+
+		eventID, splitAmount, err := createSplitEvent(user, &SplitEventInput{
+			EventName:      draft.EventName,
+			CircleID:       draft.CircleID,
+			TotalAmount:    draft.TotalAmount,
+			ParticipantIDs: participantIDs,
+		})
+		if err != nil {
+			log.Printf("イベント作成エラー: %v", err)
+			ReplyMessage(replyToken, "エラーが発生しました。もう一度「作成する」と送信してください。")
+			return
+		}
+		clearConversation(user.UserID)
+
+		log.Printf("[Bot割り勘] 作成: event=%d, organizer=%s", eventID, user.UserID)
+		showMainMenu(user, replyToken, fmt.Sprintf("割り勘イベント「%s」を作成しました！\n\n1人あたり %s円\n参加者%d人に通知を送信しました。",
+			draft.EventName, formatAmount(splitAmount), len(participantIDs)))
+
+	case "参加者を選び直す":
+		draft.Page = 0
+		if err := setConversation(user.UserID, stateSplitParticipants, draft); err != nil {
+			log.Printf("会話状態保存エラー: %v", err)
+			ReplyMessage(replyToken, "エラーが発生しました")
+			return
+		}
+		showSplitParticipantPage(&draft, replyToken, "")
+
+	default:
+		showSplitConfirm(&draft, replyToken)
+	}
+}
+
+// showSplitParticipantPage は参加者選択画面を表示する
+func showSplitParticipantPage(draft *splitDraft, replyToken, header string) {
+	var text strings.Builder
+	text.WriteString(header)
+	text.WriteString(fmt.Sprintf("参加者を選択してください（%d/%dページ、%d人選択中）\n\n", draft.Page+1, draft.pageCount(), len(draft.Selected)))
+
+	start := draft.Page * splitMemberPageSize
+	end := min(start+splitMemberPageSize, len(draft.Members))
+
+	var buttons []QuickReplyButton
+	for i := start; i < end; i++ {
+		mark := "⬜"
+		if draft.isSelected(i) {
+			mark = "✅"
+		}
+		text.WriteString(fmt.Sprintf("%s %d. %s\n", mark, i+1, draft.Members[i].Name))
+		buttons = append(buttons, messageButton(fmt.Sprintf("%s %s", mark, draft.Members[i].Name), fmt.Sprintf("参加者:%d", i+1)))
+	}
+
+	if draft.Page > 0 {
+		buttons = append(buttons, messageButton("◀ 前へ", "参加者:前へ"))
+	}
+	if draft.Page < draft.pageCount()-1 {
+		buttons = append(buttons, messageButton("次へ ▶", "参加者:次へ"))
+	}
+	buttons = append(buttons, messageButton("👥 全員", "参加者:全員"))
+	buttons = append(buttons, messageButton("✔ 決定", "参加者:決定"))
+
+	text.WriteString("\nタップで選択/解除、番号をスペース区切りで送っても選べます。\n（中止するときは「キャンセル」と送信）")
+
+	if err := ReplyMessageWithQuickReply(replyToken, text.String(), buttons); err != nil {
+		log.Printf("Quick Reply送信エラー: %v", err)
+		ReplyMessage(replyToken, text.String())
+	}
+}
+
+// showSplitConfirm は作成内容の確認画面を表示する
+func showSplitConfirm(draft *splitDraft, replyToken string) {
+	selected := draft.selectedMembers()
+	splitAmount := draft.TotalAmount / len(selected)
+
+	var text strings.Builder
+	text.WriteString("【割り勘イベントの確認】\n\n")
+	text.WriteString(fmt.Sprintf("サークル: %s\n", draft.CircleName))
+	text.WriteString(fmt.Sprintf("イベント: %s\n", draft.EventName))
+	text.WriteString(fmt.Sprintf("合計金額: %s円\n", formatAmount(draft.TotalAmount)))
+	text.WriteString(fmt.Sprintf("参加者（%d人）:\n", len(selected)))
+	for _, m := range selected {
+		text.WriteString(fmt.Sprintf("・%s\n", m.Name))
+	}
+	text.WriteString(fmt.Sprintf("\n1人あたり: %s円\n\nこの内容で作成しますか？", formatAmount(splitAmount)))
+
+	buttons := []QuickReplyButton{
+		messageButton("✅ 作成する", "作成する"),
+		messageButton("✏️ 参加者を選び直す", "参加者を選び直す"),
+		messageButton("❌ キャンセル", cancelCommand),
+	}
+
+	if err := ReplyMessageWithQuickReply(replyToken, text.String(), buttons); err != nil {
+		log.Printf("Quick Reply送信エラー: %v", err)
+		ReplyMessage(replyToken, text.String()+"\n\n「作成する」または「キャンセル」と送信してください。")
+	}
+}
+
+// resetBrokenSplitDraft は作業データが読めない場合にフローをリセットする
+func resetBrokenSplitDraft(user *User, replyToken string, err error) {
+	log.Printf("割り勘作成データ読み込みエラー: %v", err)
+	clearConversation(user.UserID)
+	showMainMenu(user, replyToken, "エラーが発生しました。最初からやり直してください。")
+}
+
+// ========== ヘルパー ==========
+
+// messageButton はメッセージアクションのQuick Replyボタンを作成する
+func messageButton(label, text string) QuickReplyButton {
+	return QuickReplyButton{
+		Type: "action",
+		Action: ActionObject{
+			Type:  "message",
+			Label: truncateText(label, quickReplyLabelMaxLen),
+			Text:  text,
+		},
+	}
+}
+
+// parseAmount は金額の入力を数値に変換する（全角数字・カンマ・「円」を許容）
+func parseAmount(input string) (int, bool) {
+	normalized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９':
+			return '0' + (r - '０')
+		case r == ',' || r == '，' || r == '円' || r == ' ' || r == '　':
+			return -1
+		}
+		return r
+	}, input)
+
+	amount, err := strconv.Atoi(normalized)
+	if err != nil {
+		return 0, false
+	}
+	return amount, true
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
@@ -105,33 +108,56 @@ func handleJoinCircle(c *gin.Context) {
 	if req.CircleID != nil {
 		// IDで参加
 		circle, err = GetCircleByID(*req.CircleID)
-		if err != nil || circle == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
-			return
-		}
-		err = JoinCircle(userID, *req.CircleID)
 	} else if req.CircleName != "" {
 		// 名前で参加
-		circle, err = JoinCircleByName(userID, req.CircleName)
+		circle, err = GetCircleByName(sanitizeInput(req.CircleName))
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Circle name or ID is required"})
 		return
 	}
 
 	if err != nil {
-		log.Printf("サークル参加エラー: %v", err)
-		if err.Error() == "already a member of this circle" {
+		log.Printf("サークル検索エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join circle"})
+		return
+	}
+	if circle == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "サークルが見つかりません"})
+		return
+	}
+
+	// 非公開サークルは参加申請になる
+	requested, err := joinCircleOrRequest(userID, circle)
+	if err != nil {
+		if errors.Is(err, errAlreadyCircleMember) {
 			c.JSON(http.StatusConflict, gin.H{"error": "既にこのサークルに参加しています"})
 			return
 		}
-		if err.Error() == "circle not found: "+req.CircleName {
-			c.JSON(http.StatusNotFound, gin.H{"error": "サークルが見つかりません"})
+		if errors.Is(err, errMembershipSuspended) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "このサークルでは休止中です。オーナーに連絡してください"})
 			return
 		}
+		log.Printf("サークル参加エラー: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join circle"})
 		return
 	}
 
+	if requested {
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":  "pending",
+			"message": "参加申請を送信しました。管理者の承認をお待ちください",
+			"circle":  circle,
+		})
+		return
+	}
+
+	// 名前で参加した場合、主サークルが設定されていなければ設定
+	if req.CircleID == nil {
+		if primary, err := GetPrimaryCircle(userID); err == nil && primary == nil {
+			SetPrimaryCircle(userID, circle.ID)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
 		"message": "サークルに参加しました",
@@ -151,14 +177,17 @@ func handleLeaveCircle(c *gin.Context) {
 		return
 	}
 
-	// 自分がメンバーか確認
-	isMember, err := IsCircleMember(userID, circleID)
-	if err != nil || !isMember {
+	// 最後のオーナーは、他のメンバーが残っている間は抜けられない
+	err = LeaveCircle(userID, circleID)
+	if errors.Is(err, errNotCircleMember) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
 		return
 	}
-
-	if err := LeaveCircle(userID, circleID); err != nil {
+	if errors.Is(err, errNoCircleOwnerLeft) {
+		c.JSON(http.StatusConflict, gin.H{"error": "他のメンバーをオーナーにしてから退出してください"})
+		return
+	}
+	if err != nil {
 		log.Printf("サークル退出エラー: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave circle"})
 		return
@@ -196,7 +225,11 @@ func handleRemoveFromCircle(c *gin.Context) {
 	}
 
 	var req struct {
-		TargetUserID string `json:"targetUserId" binding:"required"`
+		TargetUserID     string `json:"targetUserId" binding:"required"`
+		Reason           string `json:"reason"`           // 本人への通知と監査ログに使う
+		Debts            string `json:"debts"`            // 未払い分の扱い: 'keep'（省略時）, 'cancel', 'transfer'
+		TransferToUserID string `json:"transferToUserId"` // debtsが'transfer'の場合の付け替え先
+		NotifyOwners     bool   `json:"notifyOwners"`     // オーナーにも通知する
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -204,9 +237,28 @@ func handleRemoveFromCircle(c *gin.Context) {
 		return
 	}
 
-	// 自分がメンバーか確認（メンバーなら誰でも外せる設計）
-	isMember, err := IsCircleMember(userID, circleID)
-	if err != nil || !isMember {
+	debtPolicy := req.Debts
+	if debtPolicy == "" {
+		debtPolicy = DebtPolicyKeep
+	}
+	if !isValidDebtPolicy(debtPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "debts must be keep, cancel or transfer"})
+		return
+	}
+	if debtPolicy == DebtPolicyTransfer && (req.TransferToUserID == "" || req.TransferToUserID == req.TargetUserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "transferToUserId must be another member"})
+		return
+	}
+
+	reason := sanitizeInput(req.Reason)
+	if utf8.RuneCountInString(reason) > maxMembershipReasonLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Reason must be %d characters or less", maxMembershipReasonLen)})
+		return
+	}
+
+	// 自分のロールを確認
+	actorRole, err := GetCircleRole(userID, circleID)
+	if err != nil || actorRole == "" {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
 		return
 	}
@@ -217,7 +269,46 @@ func handleRemoveFromCircle(c *gin.Context) {
 		return
 	}
 
-	if err := RemoveFromCircle(req.TargetUserID, circleID); err != nil {
+	targetRole, err := GetCircleRole(req.TargetUserID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if targetRole == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this circle"})
+		return
+	}
+
+	// オーナー・会計のみ、自分より弱いロールのメンバーを外せる
+	if !canRemoveMember(actorRole, targetRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to remove this member"})
+		return
+	}
+
+	if debtPolicy == DebtPolicyTransfer {
+		isMember, err := IsCircleMember(req.TransferToUserID, circleID)
+		if err != nil {
+			log.Printf("メンバー確認エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+			return
+		}
+		if !isMember {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "transferToUserId is not a member of this circle"})
+			return
+		}
+	}
+
+	removal := &MemberRemoval{
+		CircleID:         circleID,
+		TargetUserID:     req.TargetUserID,
+		ActorID:          userID,
+		Reason:           reason,
+		DebtPolicy:       debtPolicy,
+		TransferToUserID: req.TransferToUserID,
+	}
+	result, err := RemoveFromCircle(removal)
+	if err != nil {
 		log.Printf("メンバー退会エラー: %v", err)
 		if err.Error() == "user is not a member of this circle" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this circle"})
@@ -227,14 +318,17 @@ func handleRemoveFromCircle(c *gin.Context) {
 		return
 	}
 
+	go notifyMemberRemoval(removal, result, req.NotifyOwners)
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
 		"message": "メンバーを退会させました",
+		"debts":   result,
 	})
 }
 
 // handleGetCircleMembers は指定サークルのメンバー一覧を取得する
-// GET /api/liff/circles/:id/members
+// GET /api/liff/circles/:id/members?status=active|suspended|alumni
 func handleGetCircleMembersByID(c *gin.Context) {
 	userID := GetUserID(c)
 	circleIDStr := c.Param("id")
@@ -262,8 +356,17 @@ func handleGetCircleMembersByID(c *gin.Context) {
 	// excludeMyself パラメータで自分を除外するか決定
 	excludeMyself := c.Query("excludeMyself") == "true"
 
+	// status パラメータで休止中・OB・OGのメンバーを取得（省略時は在籍中）
+	status := c.DefaultQuery("status", MembershipActive)
+	if status != MembershipActive && status != MembershipSuspended && status != MembershipAlumni {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
 	var members []CircleMember
-	if excludeMyself {
+	if status != MembershipActive {
+		members, err = GetCircleMembersByStatus(circleID, status)
+	} else if excludeMyself {
 		members, err = GetCircleMembers(circleID, userID)
 	} else {
 		members, err = GetAllCircleMembers(circleID)
@@ -334,3 +437,162 @@ func handleSetPrimaryCircle(c *gin.Context) {
 		"message": "主サークルを設定しました",
 	})
 }
+
+// ========== ロール・設定管理 ==========
+
+// handleUpdateCircleRole はメンバーのロールを変更する（オーナーのみ）
+// POST /api/liff/circles/:id/roles
+func handleUpdateCircleRole(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		TargetUserID string `json:"targetUserId" binding:"required"`
+		Role         string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target user ID and role are required"})
+		return
+	}
+
+	if !isValidCircleRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	actorRole, err := GetCircleRole(userID, circleID)
+	if err != nil || actorRole == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return
+	}
+	if !canChangeRoles(actorRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can change roles"})
+		return
+	}
+
+	// 最後のオーナーを降格するとサークルを管理できなくなるため禁止
+	err = UpdateCircleRole(req.TargetUserID, circleID, req.Role, userID)
+	if errors.Is(err, errNotCircleMember) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this circle"})
+		return
+	}
+	if errors.Is(err, errNoCircleOwnerLeft) {
+		c.JSON(http.StatusConflict, gin.H{"error": "サークルにはオーナーが最低1人必要です"})
+		return
+	}
+	if err != nil {
+		log.Printf("ロール変更エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "ロールを変更しました",
+	})
+}
+
+// handleGetCircleSettings はサークル設定を取得する
+// GET /api/liff/circles/:id/settings
+func handleGetCircleSettings(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	role, err := GetCircleRole(userID, circleID)
+	if err != nil || role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return
+	}
+
+	settings, err := GetCircleSettings(circleID)
+	if err != nil || settings == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"settings": settings,
+		"myRole":   role,
+	})
+}
+
+// handleUpdateCircleSettings はサークル設定を更新する（オーナーのみ）
+// PUT /api/liff/circles/:id/settings
+func handleUpdateCircleSettings(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	// 省略した項目は現在の設定のまま
+	var req struct {
+		EventCreation *string `json:"eventCreation"`
+		Visibility    *string `json:"visibility"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.EventCreation != nil && *req.EventCreation != EventCreationAll && *req.EventCreation != EventCreationManagers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid eventCreation"})
+		return
+	}
+	if req.Visibility != nil && !isValidCircleVisibility(*req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility"})
+		return
+	}
+
+	role, err := GetCircleRole(userID, circleID)
+	if err != nil || role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return
+	}
+	if !canEditCircleSettings(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can change settings"})
+		return
+	}
+
+	settings, err := GetCircleSettings(circleID)
+	if err != nil || settings == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+	if req.EventCreation != nil {
+		settings.EventCreation = *req.EventCreation
+	}
+	if req.Visibility != nil {
+		settings.Visibility = *req.Visibility
+	}
+
+	if err := UpdateCircleSettings(circleID, settings); err != nil {
+		log.Printf("サークル設定更新エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"message":  "設定を更新しました",
+		"settings": settings,
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== 監査ログハンドラー ==========
+
+const (
+	// defaultAuditLogLimit は監査ログを一度に返す件数の既定値
+	defaultAuditLogLimit = 50
+
+	// maxAuditLogLimit は監査ログを一度に返す件数の上限
+	maxAuditLogLimit = 200
+)
+
+// handleGetCircleAuditLogs はサークルの監査ログを新しい順に取得する（オーナーのみ）
+// 続きはレスポンスのnextBeforeIdをbeforeに指定して取得する
+// GET /api/liff/circles/:id/audit-logs?action=&actorId=&targetId=&from=&to=&before=&limit=
+func handleGetCircleAuditLogs(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	limit := defaultAuditLogLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(limit, maxAuditLogLimit)
+	}
+
+	beforeID := 0
+	if v := c.Query("before"); v != "" {
+		beforeID, err = strconv.Atoi(v)
+		if err != nil || beforeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+	}
+
+	if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	logs, err := GetCircleAuditLogs(CircleAuditFilter{
+		CircleID: circleID,
+		Action:   c.Query("action"),
+		ActorID:  c.Query("actorId"),
+		TargetID: c.Query("targetId"),
+		From:     from,
+		To:       to,
+		BeforeID: beforeID,
+		Limit:    limit,
+	})
+	if err != nil {
+		log.Printf("監査ログ取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit logs"})
+		return
+	}
+
+	var nextBeforeID *int
+	if len(logs) == limit {
+		nextBeforeID = &logs[len(logs)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "ok",
+		"logs":         logs,
+		"nextBeforeId": nextBeforeID,
+	})
+}
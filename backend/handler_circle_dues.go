@@ -0,0 +1,302 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== 会費ハンドラー ==========
+
+// maxDuesStartDay は会費の開始日に指定できる日の上限
+// 29日以降だと月によって日付がずれるため
+const maxDuesStartDay = 28
+
+// handleGetCircleDues はサークルの会費スケジュール一覧を取得する（メンバーなら閲覧可）
+// GET /api/liff/circles/:id/dues
+func handleGetCircleDues(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleMember(c, userID, circleID) {
+		return
+	}
+
+	schedules, err := GetCircleDuesSchedules(circleID)
+	if err != nil {
+		log.Printf("会費スケジュール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dues"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"dues":   schedules,
+	})
+}
+
+// handleCreateCircleDues は会費スケジュールを作成する（管理者のみ）
+// POST /api/liff/circles/:id/dues
+func handleCreateCircleDues(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name" binding:"required"`
+		Amount        int      `json:"amount" binding:"required,gt=0"`
+		Period        string   `json:"period" binding:"required"`
+		StartDate     string   `json:"startDate"`   // YYYY-MM-DD（省略時は今日）
+		OrganizerID   string   `json:"organizerId"` // 支払先（省略時は作成者）
+		ExemptUserIDs []string `json:"exemptUserIds"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	name := sanitizeInput(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return
+	}
+	if !isValidDuesPeriod(req.Period) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period"})
+		return
+	}
+
+	var startDate time.Time
+	if req.StartDate != "" {
+		startDate, err = time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date"})
+			return
+		}
+	} else {
+		startDate, err = CurrentDate()
+		if err != nil {
+			log.Printf("日付取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dues"})
+			return
+		}
+	}
+	if startDate.Day() > maxDuesStartDay {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start date must be on or before the 28th"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	organizerID := req.OrganizerID
+	if organizerID == "" {
+		organizerID = userID
+	}
+	if !validateDuesMembers(c, circleID, organizerID, req.ExemptUserIDs) {
+		return
+	}
+
+	schedule, err := CreateDuesSchedule(&DuesSchedule{
+		CircleID:      circleID,
+		Name:          name,
+		Amount:        req.Amount,
+		Period:        req.Period,
+		StartDate:     startDate,
+		OrganizerID:   organizerID,
+		ExemptUserIDs: uniqueStrings(req.ExemptUserIDs),
+		CreatedBy:     userID,
+	})
+	if err != nil {
+		log.Printf("会費スケジュール作成エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dues"})
+		return
+	}
+
+	// 開始日が今日以前ならすぐに生成する
+	go generateDues()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "会費を設定しました",
+		"dues":    schedule,
+	})
+}
+
+// handleUpdateCircleDues は会費スケジュールを更新する（管理者のみ、次の期間から反映）
+// PUT /api/liff/circles/:id/dues/:duesId
+func handleUpdateCircleDues(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	duesID, err := strconv.Atoi(c.Param("duesId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dues ID"})
+		return
+	}
+
+	// 省略した項目は変更しない
+	var req struct {
+		Name          *string   `json:"name"`
+		Amount        *int      `json:"amount"`
+		OrganizerID   *string   `json:"organizerId"`
+		Active        *bool     `json:"active"`
+		ExemptUserIDs *[]string `json:"exemptUserIds"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	schedule, ok := loadCircleDuesSchedule(c, circleID, duesID)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		schedule.Name = sanitizeInput(*req.Name)
+		if schedule.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+			return
+		}
+	}
+	if req.Amount != nil {
+		if *req.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+			return
+		}
+		schedule.Amount = *req.Amount
+	}
+	if req.OrganizerID != nil {
+		schedule.OrganizerID = *req.OrganizerID
+	}
+	if req.Active != nil {
+		schedule.Active = *req.Active
+	}
+	if req.ExemptUserIDs != nil {
+		schedule.ExemptUserIDs = uniqueStrings(*req.ExemptUserIDs)
+	}
+
+	if !validateDuesMembers(c, circleID, schedule.OrganizerID, schedule.ExemptUserIDs) {
+		return
+	}
+
+	if err := UpdateDuesSchedule(schedule, userID); err != nil {
+		log.Printf("会費スケジュール更新エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dues"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "会費を更新しました",
+		"dues":    schedule,
+	})
+}
+
+// handleStopCircleDues は会費スケジュールを停止する（管理者のみ）
+// 生成済みの会費はそのまま残る
+// DELETE /api/liff/circles/:id/dues/:duesId
+func handleStopCircleDues(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	duesID, err := strconv.Atoi(c.Param("duesId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dues ID"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	if _, ok := loadCircleDuesSchedule(c, circleID, duesID); !ok {
+		return
+	}
+
+	if err := DeactivateDuesSchedule(duesID, userID, ""); err != nil {
+		log.Printf("会費スケジュール停止エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop dues"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "会費の徴収を停止しました",
+	})
+}
+
+// loadCircleDuesSchedule はサークルに属する会費スケジュールを取得する
+// 見つからない場合はレスポンスを書き込んでfalseを返す
+func loadCircleDuesSchedule(c *gin.Context, circleID, duesID int) (*DuesSchedule, bool) {
+	schedule, err := GetDuesSchedule(duesID)
+	if err != nil {
+		log.Printf("会費スケジュール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dues"})
+		return nil, false
+	}
+	if schedule == nil || schedule.CircleID != circleID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dues not found"})
+		return nil, false
+	}
+	return schedule, true
+}
+
+// validateDuesMembers は支払先が管理者で、免除メンバーがサークルに在籍しているか確認する
+// 問題がある場合はレスポンスを書き込んでfalseを返す
+func validateDuesMembers(c *gin.Context, circleID int, organizerID string, exemptUserIDs []string) bool {
+	organizerRole, err := GetCircleRole(organizerID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check members"})
+		return false
+	}
+	if !isCircleManager(organizerRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organizer must be an owner or treasurer of this circle"})
+		return false
+	}
+
+	nonMembers, err := findNonMemberParticipants(circleID, exemptUserIDs)
+	if err != nil {
+		log.Printf("メンバー確認エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check members"})
+		return false
+	}
+	if len(nonMembers) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Some exempt users are not members of this circle",
+			"nonMembers": nonMembers,
+		})
+		return false
+	}
+	return true
+}
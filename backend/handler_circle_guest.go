@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== ゲストハンドラー ==========
+
+const (
+	// maxGuestNameLen はゲストの名前の最大文字数
+	maxGuestNameLen = 50
+
+	// maxGuestContactLen はゲストの連絡先の最大文字数
+	maxGuestContactLen = 100
+)
+
+// guestInput は新しく登録するゲストの入力
+type guestInput struct {
+	Name    string `json:"name"`
+	Contact string `json:"contact"` // 任意
+}
+
+// normalizeGuestInput はゲストの入力を整えて検証する
+// 不正ならレスポンスを書き込んでfalseを返す
+func normalizeGuestInput(c *gin.Context, in guestInput) (guestInput, bool) {
+	out := guestInput{Name: sanitizeInput(in.Name), Contact: sanitizeInput(in.Contact)}
+	if out.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Guest name is required"})
+		return out, false
+	}
+	if utf8.RuneCountInString(out.Name) > maxGuestNameLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Guest name must be %d characters or less", maxGuestNameLen)})
+		return out, false
+	}
+	if utf8.RuneCountInString(out.Contact) > maxGuestContactLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Guest contact must be %d characters or less", maxGuestContactLen)})
+		return out, false
+	}
+	return out, true
+}
+
+// handleGetCircleGuests はサークルの未統合のゲスト一覧を取得する（メンバーのみ）
+// GET /api/liff/circles/:id/guests
+func handleGetCircleGuests(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleMember(c, userID, circleID) {
+		return
+	}
+
+	guests, err := GetCircleGuests(circleID)
+	if err != nil {
+		log.Printf("ゲスト取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get guests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"guests": guests,
+	})
+}
+
+// handleCreateCircleGuest はサークルにゲストを登録する（メンバーのみ）
+// POST /api/liff/circles/:id/guests
+func handleCreateCircleGuest(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req guestInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	in, ok := normalizeGuestInput(c, req)
+	if !ok {
+		return
+	}
+
+	if !requireCircleMember(c, userID, circleID) {
+		return
+	}
+
+	guest, err := CreateCircleGuest(circleID, in.Name, in.Contact, userID)
+	if err != nil {
+		log.Printf("ゲスト登録エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "ゲストを登録しました",
+		"guest":   guest,
+	})
+}
+
+// handleMergeCircleGuest はゲストの参加履歴を後から登録したメンバーに統合する（管理者のみ）
+// POST /api/liff/circles/:id/guests/:guestId/merge
+func handleMergeCircleGuest(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+	guestIDStr := c.Param("guestId")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	guestID, err := strconv.Atoi(guestIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID"})
+		return
+	}
+
+	var req struct {
+		UserID string `json:"userId" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	isMember, err := IsCircleMember(req.UserID, circleID)
+	if err != nil {
+		log.Printf("メンバー確認エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge guest"})
+		return
+	}
+	if !isMember {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The user is not a member of this circle"})
+		return
+	}
+
+	result, err := MergeCircleGuest(circleID, guestID, req.UserID, userID)
+	if err != nil {
+		log.Printf("ゲスト統合エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge guest"})
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found or already merged"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": fmt.Sprintf("%d件の参加履歴を統合しました", len(result.ParticipantIDs)),
+		"result":  result,
+	})
+}
+
+// handleMarkGuestPayments はゲストの支払いを受け取り済みとして記録する（会計者のみ）
+// ゲストはLINEで報告できないため、報告と承認を会計者がまとめて行う
+// POST /api/liff/events/:id/guest-payments
+func handleMarkGuestPayments(c *gin.Context) {
+	userID := GetUserID(c)
+	eventIDStr := c.Param("id")
+
+	eventID, err := strconv.Atoi(eventIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req struct {
+		ParticipantIDs []int `json:"participantIds" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No participants specified"})
+		return
+	}
+
+	event, err := GetEvent(eventID)
+	if err != nil {
+		log.Printf("イベント取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if event.OrganizerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizer can record guest payments"})
+		return
+	}
+
+	// ゲストの未承認の参加者でないものはskipped、記録に失敗したものはfailedで返す
+	marked := []int{}
+	skipped := []int{}
+	failed := []int{}
+	for _, participantID := range uniqueInts(req.ParticipantIDs) {
+		ok, err := MarkGuestPaid(eventID, participantID)
+		switch {
+		case err != nil:
+			log.Printf("ゲスト支払い記録エラー: participant=%d: %v", participantID, err)
+			failed = append(failed, participantID)
+		case ok:
+			marked = append(marked, participantID)
+		default:
+			skipped = append(skipped, participantID)
+		}
+	}
+
+	if len(marked) == 0 && len(failed) > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to record guest payments",
+			"skipped": skipped,
+			"failed":  failed,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": fmt.Sprintf("%d人のゲストの支払いを記録しました", len(marked)),
+		"marked":  marked,
+		"skipped": skipped,
+		"failed":  failed,
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== 会計引き継ぎハンドラー ==========
+
+// maxHandoverReasonLen は引き継ぎ理由の最大文字数
+const maxHandoverReasonLen = 200
+
+// errHandoverEventNotFound は指定したイベントが引き継ぎ対象でない場合のエラー
+var errHandoverEventNotFound = errors.New("event is not an open event of the treasurer")
+
+// handleHandoverTreasurer は会計者の未完了イベントを別のメンバーに引き継ぐ
+// 会計者本人またはオーナーが実行できる。eventIdsを省略するとすべてのイベントと会費を引き継ぐ
+// POST /api/liff/circles/:id/handover
+func handleHandoverTreasurer(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		FromUserID string `json:"fromUserId"` // 省略時は自分
+		ToUserID   string `json:"toUserId" binding:"required"`
+		EventIDs   []int  `json:"eventIds"`
+		Reason     string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	fromUserID := req.FromUserID
+	if fromUserID == "" {
+		fromUserID = userID
+	}
+	if fromUserID == req.ToUserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot hand over to the same user"})
+		return
+	}
+
+	reason := sanitizeInput(req.Reason)
+	if utf8.RuneCountInString(reason) > maxHandoverReasonLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Reason must be %d characters or less", maxHandoverReasonLen)})
+		return
+	}
+
+	// 他人の引き継ぎはオーナーのみ（卒業などで本人が操作できない場合）
+	if fromUserID == userID {
+		if !requireCircleMember(c, userID, circleID) {
+			return
+		}
+	} else if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	toRole, err := GetCircleRole(req.ToUserID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check members"})
+		return
+	}
+	if !isCircleManager(toRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The new treasurer must be an owner or treasurer of this circle"})
+		return
+	}
+
+	result, err := HandoverCircleEvents(&TreasurerHandover{
+		CircleID:   circleID,
+		FromUserID: fromUserID,
+		ToUserID:   req.ToUserID,
+		EventIDs:   req.EventIDs,
+		ActorID:    userID,
+		Reason:     reason,
+	})
+	if errors.Is(err, errHandoverEventNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some events are not open events of this treasurer in this circle"})
+		return
+	}
+	if err != nil {
+		log.Printf("会計引き継ぎエラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hand over"})
+		return
+	}
+
+	go notifyHandover(fromUserID, req.ToUserID, result)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": fmt.Sprintf("%d件のイベントを引き継ぎました", len(result.EventIDs)),
+		"result":  result,
+	})
+}
+
+// notifyHandover は未承認の参加者に新しい支払先を、引き継ぎ先に引き継いだ内容を通知する
+func notifyHandover(fromUserID, toUserID string, result *HandoverResult) {
+	if len(result.EventIDs) == 0 && len(result.DuesScheduleIDs) == 0 {
+		return
+	}
+
+	fromName, toName := fromUserID, toUserID
+	if from, _ := GetUser(fromUserID); from != nil {
+		fromName = from.Name
+	}
+	if to, _ := GetUser(toUserID); to != nil {
+		toName = to.Name
+	}
+
+	text := fmt.Sprintf("【会計の引き継ぎ】\n%sさんから会計を引き継ぎました。\n\nイベント: %d件\n会費: %d件\n\n支払い報告の承認はあなたが行います。",
+		fromName, len(result.EventIDs), len(result.DuesScheduleIDs))
+	if len(result.RemovedShareEventIDs) > 0 {
+		text += fmt.Sprintf("\n\nあなたが参加者だった%d件のイベントは、あなたの未払い分を取り消しました。", len(result.RemovedShareEventIDs))
+	}
+	if err := PushMessage(toUserID, text); err != nil {
+		log.Printf("会計引き継ぎ通知エラー: %v", err)
+	}
+
+	if len(result.EventIDs) == 0 {
+		return
+	}
+
+	notices, err := GetHandoverNotices(result.EventIDs)
+	if err != nil {
+		log.Printf("引き継ぎ通知対象取得エラー: %v", err)
+		return
+	}
+
+	// 参加者ごとに1通にまとめる
+	byUser := make(map[string][]HandoverNotice)
+	var userIDs []string
+	for _, n := range notices {
+		if _, ok := byUser[n.UserID]; !ok {
+			userIDs = append(userIDs, n.UserID)
+		}
+		byUser[n.UserID] = append(byUser[n.UserID], n)
+	}
+
+	for _, participantID := range userIDs {
+		var lines []string
+		for _, n := range byUser[participantID] {
+			line := fmt.Sprintf("・%s（%d円）", n.EventName, n.Amount)
+			if n.Reported {
+				line += " 報告済み・確認待ち"
+			}
+			lines = append(lines, line)
+		}
+
+		text := fmt.Sprintf("【支払先の変更】\n以下のイベントの支払先が%sさんから%sさんに変わりました。\n\n%s\n\n未払いの分は%sさんに支払い、報告してください。",
+			fromName, toName, strings.Join(lines, "\n"), toName)
+		if err := PushMessage(participantID, text); err != nil {
+			log.Printf("支払先変更通知エラー: %v", err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ========== サークル招待ハンドラー ==========
+
+const (
+	// inviteQRCodeSize は招待QRコード画像の一辺のピクセル数
+	inviteQRCodeSize = 512
+
+	// maxInviteExpiryHours は招待コードの有効期限の上限（時間）
+	maxInviteExpiryHours = 24 * 90
+)
+
+// requireCircleManager はユーザーがサークルの管理者（オーナー・会計）か確認する
+// 管理者でない場合はレスポンスを書き込んでfalseを返す
+func requireCircleManager(c *gin.Context, userID string, circleID int) bool {
+	role, err := GetCircleRole(userID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permission"})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return false
+	}
+	if !isCircleManager(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and treasurers can manage invites"})
+		return false
+	}
+	return true
+}
+
+// handleCreateCircleInvite は招待コードを発行する（管理者のみ）
+// POST /api/liff/circles/:id/invites
+func handleCreateCircleInvite(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		ExpiresInHours int  `json:"expiresInHours"` // 0なら無期限
+		MaxUses        *int `json:"maxUses"`        // 未指定なら回数無制限
+	}
+
+	// ボディは省略可能（無期限・無制限）
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+
+	if req.ExpiresInHours < 0 || req.ExpiresInHours > maxInviteExpiryHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry"})
+		return
+	}
+	if req.MaxUses != nil && *req.MaxUses <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Max uses must be positive"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	invite, err := CreateCircleInvite(circleID, userID, time.Duration(req.ExpiresInHours)*time.Hour, req.MaxUses)
+	if err != nil {
+		log.Printf("招待コード発行エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"invite": invite,
+		"url":    inviteURL(invite.Code),
+		"qrUrl":  "/api/invites/" + invite.Code + "/qr",
+	})
+}
+
+// handleGetCircleInvites はサークルの招待コード一覧を取得する（管理者のみ）
+// GET /api/liff/circles/:id/invites
+func handleGetCircleInvites(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	invites, err := GetCircleInvites(circleID)
+	if err != nil {
+		log.Printf("招待コード取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"invites": invites,
+	})
+}
+
+// handleRevokeCircleInvite は招待コードを無効化する（管理者のみ）
+// DELETE /api/liff/circles/:id/invites/:inviteId
+func handleRevokeCircleInvite(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	inviteID, err := strconv.Atoi(c.Param("inviteId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	if err := RevokeCircleInvite(circleID, inviteID); err != nil {
+		if err.Error() == "invite not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+		log.Printf("招待コード無効化エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "招待コードを無効化しました",
+	})
+}
+
+// handleGetInvitePreview は招待コードの参加先サークルを確認する
+// GET /api/liff/invites/:code
+func handleGetInvitePreview(c *gin.Context) {
+	invite, err := GetCircleInviteByCode(normalizeInviteCode(c.Param("code")))
+	if err != nil {
+		log.Printf("招待コード取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invite"})
+		return
+	}
+	if invite == nil || !invite.Usable {
+		c.JSON(http.StatusNotFound, gin.H{"error": "招待コードが無効か、期限切れです"})
+		return
+	}
+
+	circle, err := GetCircleByID(invite.CircleID)
+	if err != nil || circle == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+	memberCount, _ := GetCircleMemberCount(circle.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"circle":      circle,
+		"memberCount": memberCount,
+	})
+}
+
+// handleRedeemCircleInvite は招待コードでサークルに参加する
+// POST /api/liff/invites/redeem
+func handleRedeemCircleInvite(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invite code is required"})
+		return
+	}
+
+	circle, err := redeemCircleInvite(userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidInvite):
+			c.JSON(http.StatusNotFound, gin.H{"error": "招待コードが無効か、期限切れです"})
+		case errors.Is(err, errAlreadyCircleMember):
+			c.JSON(http.StatusConflict, gin.H{"error": "既にこのサークルに参加しています"})
+		case errors.Is(err, errMembershipSuspended):
+			c.JSON(http.StatusForbidden, gin.H{"error": "このサークルでは休止中です。オーナーに連絡してください"})
+		default:
+			log.Printf("招待コード参加エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join circle"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "サークルに参加しました",
+		"circle":  circle,
+	})
+}
+
+// handleGetInviteQRCode は招待リンクのQRコード画像（PNG）を返す
+// 掲示板などに貼れるよう認証は不要
+// GET /api/invites/:code/qr
+func handleGetInviteQRCode(c *gin.Context) {
+	code := normalizeInviteCode(c.Param("code"))
+
+	invite, err := GetCircleInviteByCode(code)
+	if err != nil {
+		log.Printf("招待コード取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invite"})
+		return
+	}
+	if invite == nil || !invite.Usable {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	png, err := qrcode.Encode(inviteURL(invite.Code), qrcode.Medium, inviteQRCodeSize)
+	if err != nil {
+		log.Printf("QRコード生成エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== サークル参加申請ハンドラー ==========
+
+// handleGetCircleJoinRequests は申請中の参加申請一覧を取得する（管理者のみ）
+// GET /api/liff/circles/:id/join-requests
+func handleGetCircleJoinRequests(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	requests, err := GetPendingCircleJoinRequests(circleID)
+	if err != nil {
+		log.Printf("参加申請取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get join requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"requests": requests,
+	})
+}
+
+// handleApproveCircleJoinRequest は参加申請を承認する（管理者のみ）
+// POST /api/liff/circles/:id/join-requests/:requestId/approve
+func handleApproveCircleJoinRequest(c *gin.Context) {
+	handleDecideCircleJoinRequest(c, true)
+}
+
+// handleDenyCircleJoinRequest は参加申請を却下する（管理者のみ）
+// POST /api/liff/circles/:id/join-requests/:requestId/deny
+func handleDenyCircleJoinRequest(c *gin.Context) {
+	handleDecideCircleJoinRequest(c, false)
+}
+
+// handleDecideCircleJoinRequest は参加申請の承認・却下の共通処理
+func handleDecideCircleJoinRequest(c *gin.Context, approve bool) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	requestID, err := strconv.Atoi(c.Param("requestId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	// URLのサークルと申請のサークルが一致するか確認
+	existing, err := GetCircleJoinRequest(requestID)
+	if err != nil {
+		log.Printf("参加申請取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get join request"})
+		return
+	}
+	if existing == nil || existing.CircleID != circleID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found"})
+		return
+	}
+
+	request, done, err := decideJoinRequest(requestID, userID, approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, errJoinRequestNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found"})
+		case errors.Is(err, errNotCircleManager):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and treasurers can manage join requests"})
+		default:
+			log.Printf("参加申請処理エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update join request"})
+		}
+		return
+	}
+	if !done {
+		c.JSON(http.StatusConflict, gin.H{"error": "この参加申請は既に処理済みです"})
+		return
+	}
+
+	message := "参加申請を却下しました"
+	if approve {
+		message = "参加申請を承認しました"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": message,
+		"request": request,
+	})
+}
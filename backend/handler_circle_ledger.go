@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== サークル出納帳ハンドラー ==========
+
+const (
+	// maxLedgerDescriptionLen は記帳の摘要の最大文字数
+	maxLedgerDescriptionLen = 200
+
+	// maxLedgerReceiptSize は領収書画像の最大サイズ（バイト）
+	maxLedgerReceiptSize = 5 << 20
+)
+
+// requireCircleMember はユーザーがサークルのメンバーか確認する
+// メンバーでない場合はレスポンスを書き込んでfalseを返す
+func requireCircleMember(c *gin.Context, userID string, circleID int) bool {
+	role, err := GetCircleRole(userID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permission"})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return false
+	}
+	return true
+}
+
+// parseDateQuery はYYYY-MM-DD形式のクエリパラメータを読み取る
+// 未指定ならnil、形式が不正ならレスポンスを書き込んでfalseを返す
+func parseDateQuery(c *gin.Context, key string) (*time.Time, bool) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, true
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s (expected YYYY-MM-DD)", key)})
+		return nil, false
+	}
+	return &date, true
+}
+
+// handleGetCircleLedger はサークルの出納帳と残高を取得する（メンバーなら閲覧可）
+// GET /api/liff/circles/:id/ledger?from=YYYY-MM-DD&to=YYYY-MM-DD
+func handleGetCircleLedger(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	if !requireCircleMember(c, userID, circleID) {
+		return
+	}
+
+	entries, err := GetLedgerEntries(circleID, from, to)
+	if err != nil {
+		log.Printf("出納帳取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ledger"})
+		return
+	}
+
+	summary, err := GetLedgerSummary(circleID, from, to)
+	if err != nil {
+		log.Printf("出納帳集計エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"entries": entries,
+		"summary": summary,
+	})
+}
+
+// handleCreateLedgerEntry は出納帳に収入・支出・会計担当間の移動を記帳する（管理者のみ）
+// POST /api/liff/circles/:id/ledger
+func handleCreateLedgerEntry(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		Type        string  `json:"type" binding:"required"`
+		Amount      int     `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description"`
+		OccurredOn  string  `json:"occurredOn"` // YYYY-MM-DD（省略時は今日）
+		HolderID    string  `json:"holderId"`   // 省略時は自分
+		ToUserID    *string `json:"toUserId"`   // 移動の場合のみ
+		EventID     *int    `json:"eventId"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if req.Type != LedgerIncome && req.Type != LedgerExpense && req.Type != LedgerTransfer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	description := sanitizeInput(req.Description)
+	if utf8.RuneCountInString(description) > maxLedgerDescriptionLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Description must be %d characters or less", maxLedgerDescriptionLen)})
+		return
+	}
+
+	var occurredOn time.Time
+	if req.OccurredOn != "" {
+		occurredOn, err = time.Parse("2006-01-02", req.OccurredOn)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid occurredOn"})
+			return
+		}
+	} else {
+		occurredOn, err = CurrentDate()
+		if err != nil {
+			log.Printf("日付取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ledger entry"})
+			return
+		}
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	holderID := req.HolderID
+	if holderID == "" {
+		holderID = userID
+	}
+	if !requireLedgerHolder(c, circleID, holderID) {
+		return
+	}
+
+	var toUserID *string
+	if req.Type == LedgerTransfer {
+		if req.ToUserID == nil || *req.ToUserID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "toUserId is required for transfers"})
+			return
+		}
+		if *req.ToUserID == holderID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer to the same treasurer"})
+			return
+		}
+		if !requireLedgerHolder(c, circleID, *req.ToUserID) {
+			return
+		}
+		toUserID = req.ToUserID
+	}
+
+	if req.EventID != nil {
+		event, err := GetEvent(*req.EventID)
+		if err != nil {
+			log.Printf("イベント取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+			return
+		}
+		if event == nil || event.CircleID == nil || *event.CircleID != circleID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Event does not belong to this circle"})
+			return
+		}
+	}
+
+	entry, err := CreateLedgerEntry(&LedgerEntry{
+		CircleID:    circleID,
+		Type:        req.Type,
+		Amount:      req.Amount,
+		Description: description,
+		HolderID:    holderID,
+		ToUserID:    toUserID,
+		EventID:     req.EventID,
+		OccurredOn:  occurredOn,
+		CreatedBy:   userID,
+	})
+	if err != nil {
+		log.Printf("記帳エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ledger entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "記帳しました",
+		"entry":   entry,
+	})
+}
+
+// requireLedgerHolder は現金を扱うユーザーがサークルのオーナーまたは会計か確認する
+// 問題がある場合はレスポンスを書き込んでfalseを返す
+func requireLedgerHolder(c *gin.Context, circleID int, holderID string) bool {
+	role, err := GetCircleRole(holderID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check members"})
+		return false
+	}
+	if !isCircleManager(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Holder must be an owner or treasurer of this circle"})
+		return false
+	}
+	return true
+}
+
+// handleDeleteLedgerEntry は手入力の記帳を削除する（管理者のみ）
+// 支払い承認による収入は削除できない
+// DELETE /api/liff/circles/:id/ledger/:entryId
+func handleDeleteLedgerEntry(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	entry, err := GetLedgerEntry(circleID, entryID)
+	if err != nil {
+		log.Printf("記帳取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ledger entry"})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ledger entry not found"})
+		return
+	}
+	if entry.ParticipantID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Income from approved payments cannot be deleted"})
+		return
+	}
+
+	if _, err := DeleteLedgerEntry(circleID, entryID, userID); err != nil {
+		log.Printf("記帳削除エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ledger entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "記帳を削除しました",
+	})
+}
+
+// handleUploadLedgerReceipt は記帳に領収書画像を添付する（管理者のみ）
+// PUT /api/liff/circles/:id/ledger/:entryId/receipt （multipart: receipt）
+func handleUploadLedgerReceipt(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	if !requireCircleManager(c, userID, circleID) {
+		return
+	}
+
+	entry, err := GetLedgerEntry(circleID, entryID)
+	if err != nil {
+		log.Printf("記帳取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ledger entry"})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ledger entry not found"})
+		return
+	}
+
+	file, _, err := c.Request.FormFile("receipt")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receipt file is required"})
+		return
+	}
+	defer file.Close()
+
+	// 上限+1バイトまで読んでサイズ超過を判定
+	data, err := io.ReadAll(io.LimitReader(file, maxLedgerReceiptSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read receipt"})
+		return
+	}
+	if len(data) > maxLedgerReceiptSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Receipt must be 5MB or less"})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedImageTypes[contentType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receipt must be a PNG, JPEG, GIF or WebP image"})
+		return
+	}
+
+	updated, err := UpdateLedgerReceipt(circleID, entryID, data, contentType, userID)
+	if err != nil {
+		log.Printf("領収書保存エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ledger entry not found"})
+		return
+	}
+
+	entry.ReceiptURL = ledgerReceiptURL(circleID, entryID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "領収書を添付しました",
+		"entry":   entry,
+	})
+}
+
+// handleGetLedgerReceipt は記帳の領収書画像を返す（メンバーなら閲覧可）
+// GET /api/liff/circles/:id/ledger/:entryId/receipt
+func handleGetLedgerReceipt(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	if !requireCircleMember(c, userID, circleID) {
+		return
+	}
+
+	data, contentType, err := GetLedgerReceipt(circleID, entryID)
+	if err != nil {
+		log.Printf("領収書取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get receipt"})
+		return
+	}
+	if data == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, contentType, data)
+}
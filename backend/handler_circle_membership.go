@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== 在籍状態・表示名ハンドラー ==========
+
+const (
+	// maxMembershipReasonLen は在籍状態を変更する理由の最大文字数
+	maxMembershipReasonLen = 200
+
+	// maxMembershipBatchSize は一度に在籍状態を変更できる人数
+	maxMembershipBatchSize = 200
+
+	// maxCircleDisplayNameLen はサークル内の表示名の最大文字数
+	maxCircleDisplayNameLen = 50
+)
+
+// handleUpdateMembershipStatus はメンバーの在籍状態を一括で変更する（オーナーのみ）
+// 卒業時のOB・OGへの移行や、休止・復帰に使う
+// POST /api/liff/circles/:id/members/status
+func handleUpdateMembershipStatus(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		UserIDs []string `json:"userIds" binding:"required,min=1"`
+		Status  string   `json:"status" binding:"required"` // 'active', 'suspended', 'alumni'
+		Reason  string   `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if !isValidMembershipTransitionTarget(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active, suspended or alumni"})
+		return
+	}
+
+	userIDs := uniqueStrings(req.UserIDs)
+	if len(userIDs) > maxMembershipBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Up to %d members can be changed at once", maxMembershipBatchSize)})
+		return
+	}
+
+	reason := sanitizeInput(req.Reason)
+	if utf8.RuneCountInString(reason) > maxMembershipReasonLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Reason must be %d characters or less", maxMembershipReasonLen)})
+		return
+	}
+
+	if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	changes, err := TransitionCircleMembers(circleID, userIDs, req.Status, userID, reason)
+	if errors.Is(err, errNoCircleOwnerLeft) {
+		c.JSON(http.StatusConflict, gin.H{"error": "他のメンバーをオーナーにしてから変更してください"})
+		return
+	}
+	if err != nil {
+		log.Printf("在籍状態変更エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update members"})
+		return
+	}
+
+	// 変更できなかったメンバー（すでにその状態・退出済みなど）
+	changed := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		changed[change.UserID] = true
+	}
+	skipped := []string{}
+	for _, id := range userIDs {
+		if !changed[id] {
+			skipped = append(skipped, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": fmt.Sprintf("%d人の在籍状態を変更しました", len(changes)),
+		"changed": changes,
+		"skipped": skipped,
+	})
+}
+
+// handleUpdateCircleDisplayName はサークル内の表示名（本名など）を変更する
+// 本人またはオーナーが変更できる。空文字で未設定（LINEの名前）に戻す
+// PUT /api/liff/circles/:id/members/:userId/display-name
+func handleUpdateCircleDisplayName(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+	targetUserID := c.Param("userId")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	var req struct {
+		DisplayName string `json:"displayName"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	displayName := sanitizeInput(req.DisplayName)
+	if utf8.RuneCountInString(displayName) > maxCircleDisplayNameLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Display name must be %d characters or less", maxCircleDisplayNameLen)})
+		return
+	}
+
+	if targetUserID == userID {
+		if !requireCircleMember(c, userID, circleID) {
+			return
+		}
+	} else if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	if err := UpdateCircleDisplayName(targetUserID, circleID, displayName); err != nil {
+		if err.Error() == "user is not a member of this circle" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this circle"})
+			return
+		}
+		log.Printf("表示名変更エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update display name"})
+		return
+	}
+
+	name, _ := GetCircleMemberName(targetUserID, circleID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"message":     "表示名を変更しました",
+		"displayName": displayName,
+		"name":        name,
+	})
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== サークル情報の編集・削除ハンドラー ==========
+
+const (
+	// maxCircleDescriptionLen はサークル説明の最大文字数
+	maxCircleDescriptionLen = 500
+
+	// maxCircleIconSize はサークルアイコンの最大サイズ（バイト）
+	maxCircleIconSize = 1 << 20
+)
+
+// allowedImageTypes はアップロードを受け付ける画像形式（アイコン・領収書）
+var allowedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// requireCircleOwner はユーザーがサークルのオーナーか確認する
+// オーナーでない場合はレスポンスを書き込んでfalseを返す
+func requireCircleOwner(c *gin.Context, userID string, circleID int) bool {
+	role, err := GetCircleRole(userID, circleID)
+	if err != nil {
+		log.Printf("ロール取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permission"})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return false
+	}
+	if !canEditCircleSettings(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can edit this circle"})
+		return false
+	}
+	return true
+}
+
+// handleUpdateCircle はサークル名・説明を更新する（オーナーのみ）
+// PUT /api/liff/circles/:id
+func handleUpdateCircle(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	// 省略した項目は変更しない
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	circle, err := GetCircleByID(circleID)
+	if err != nil || circle == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+
+	name := circle.Name
+	if req.Name != nil {
+		name = sanitizeInput(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Circle name cannot be empty"})
+			return
+		}
+	}
+
+	description := circle.Description
+	if req.Description != nil {
+		description = sanitizeInput(*req.Description)
+		if utf8.RuneCountInString(description) > maxCircleDescriptionLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Description must be %d characters or less", maxCircleDescriptionLen)})
+			return
+		}
+	}
+
+	// 名前の重複チェック
+	if name != circle.Name {
+		existing, err := GetCircleByName(name)
+		if err != nil {
+			log.Printf("サークル確認エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check circle"})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Circle with this name already exists"})
+			return
+		}
+	}
+
+	if err := UpdateCircleProfile(circleID, name, description); err != nil {
+		log.Printf("サークル更新エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update circle"})
+		return
+	}
+
+	circle.Name = name
+	circle.Description = description
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "サークル情報を更新しました",
+		"circle":  circle,
+	})
+}
+
+// handleUploadCircleIcon はサークルアイコンを設定する（オーナーのみ）
+// PUT /api/liff/circles/:id/icon （multipart: icon）
+func handleUploadCircleIcon(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	file, _, err := c.Request.FormFile("icon")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Icon file is required"})
+		return
+	}
+	defer file.Close()
+
+	// 上限+1バイトまで読んでサイズ超過を判定
+	data, err := io.ReadAll(io.LimitReader(file, maxCircleIconSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read icon"})
+		return
+	}
+	if len(data) > maxCircleIconSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Icon must be 1MB or less"})
+		return
+	}
+
+	// クライアントの申告ではなく中身から画像形式を判定
+	contentType := http.DetectContentType(data)
+	if !allowedImageTypes[contentType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Icon must be a PNG, JPEG, GIF or WebP image"})
+		return
+	}
+
+	if err := UpdateCircleIcon(circleID, data, contentType); err != nil {
+		log.Printf("アイコン保存エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save icon"})
+		return
+	}
+
+	circle, _ := GetCircleByID(circleID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "アイコンを設定しました",
+		"circle":  circle,
+	})
+}
+
+// handleDeleteCircleIcon はサークルアイコンを削除する（オーナーのみ）
+// DELETE /api/liff/circles/:id/icon
+func handleDeleteCircleIcon(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	if err := DeleteCircleIcon(circleID); err != nil {
+		log.Printf("アイコン削除エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete icon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "アイコンを削除しました",
+	})
+}
+
+// handleGetCircleIcon はサークルアイコン画像を返す
+// imgタグから読み込めるよう認証は不要
+// GET /api/circles/:id/icon
+func handleGetCircleIcon(c *gin.Context) {
+	circleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	data, contentType, err := GetCircleIcon(circleID)
+	if err != nil {
+		log.Printf("アイコン取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get icon"})
+		return
+	}
+	if data == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Icon not found"})
+		return
+	}
+
+	// URLにバージョンが付くため長期キャッシュしてよい
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// handleDeleteCircle はサークルを削除する（オーナーのみ）
+// イベントとメンバーシップはアーカイブされ、履歴として残る
+// DELETE /api/liff/circles/:id
+func handleDeleteCircle(c *gin.Context) {
+	userID := GetUserID(c)
+	circleIDStr := c.Param("id")
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return
+	}
+
+	if !requireCircleOwner(c, userID, circleID) {
+		return
+	}
+
+	circle, err := GetCircleByID(circleID)
+	if err != nil || circle == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+
+	// 削除後はメンバーを取得できないため先に取得しておく
+	members, err := GetAllCircleMembers(circleID)
+	if err != nil {
+		log.Printf("メンバー取得エラー: %v", err)
+	}
+
+	if err := DeleteCircle(circleID, userID); err != nil {
+		log.Printf("サークル削除エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete circle"})
+		return
+	}
+
+	// メンバーに通知（非同期）
+	go func() {
+		text := fmt.Sprintf("「%s」はオーナーによって削除されました。", circle.Name)
+		for _, m := range members {
+			if m.UserID == userID {
+				continue
+			}
+			if err := PushMessage(m.UserID, text); err != nil {
+				log.Printf("サークル削除通知エラー: %v", err)
+			}
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "サークルを削除しました",
+	})
+}
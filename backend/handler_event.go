@@ -0,0 +1,588 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== イベント詳細・レシートハンドラー ==========
+
+const (
+	// maxEventReceiptNoteLen はレシートのメモの最大文字数
+	maxEventReceiptNoteLen = 500
+
+	// maxEventReceiptSize はレシート画像の最大サイズ（バイト）
+	maxEventReceiptSize = 5 << 20
+
+	// maxEventItems はイベントの内訳の最大行数
+	maxEventItems = 50
+
+	// maxEventItemNameLen は内訳の品目名の最大文字数
+	maxEventItemNameLen = 50
+)
+
+// eventDetailURL はイベント詳細のLIFFディープリンクを返す
+func eventDetailURL(eventID int) string {
+	return strings.TrimRight(os.Getenv("LIFF_URL"), "/") + "/events?eventId=" + strconv.Itoa(eventID)
+}
+
+// loadEventForViewer はURLのイベントを取得し、会計者か参加者のみ閲覧できるよう確認する
+// 失敗した場合はレスポンスを書き込んでfalseを返す
+func loadEventForViewer(c *gin.Context, userID string) (*Event, bool) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+
+	event, err := GetEvent(eventID)
+	if err != nil {
+		log.Printf("イベント取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return nil, false
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if event.OrganizerID == userID {
+		return event, true
+	}
+
+	isParticipant, err := IsEventParticipant(eventID, userID)
+	if err != nil {
+		log.Printf("参加者確認エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return nil, false
+	}
+	if !isParticipant {
+		// 関係のないイベントは存在自体を明かさない
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	return event, true
+}
+
+// loadEventForOrganizer はURLのイベントを取得し、会計者本人か確認する
+// 失敗した場合はレスポンスを書き込んでfalseを返す
+func loadEventForOrganizer(c *gin.Context, userID string) (*Event, bool) {
+	event, ok := loadEventForViewer(c, userID)
+	if !ok {
+		return nil, false
+	}
+	if event.OrganizerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizer can manage this event"})
+		return nil, false
+	}
+	return event, true
+}
+
+// 参加者の支払い状態
+const (
+	paymentStateUnpaid   = "unpaid"   // 未払い
+	paymentStateReported = "reported" // 報告済み・承認待ち
+	paymentStateApproved = "approved" // 承認済み
+)
+
+// participantPaymentState は参加者の支払い状態を返す
+func participantPaymentState(p *Participant) string {
+	switch {
+	case p.ApprovedAt != nil:
+		return paymentStateApproved
+	case p.Paid:
+		return paymentStateReported
+	default:
+		return paymentStateUnpaid
+	}
+}
+
+// parseDeadline はYYYY-MM-DD形式の支払い期限を読み取る（空文字はnil）
+func parseDeadline(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	deadline, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &deadline, nil
+}
+
+// normalizeEventItems は内訳を整えて検証する（空なら内訳なし）
+// 品目の合計は合計金額と一致すること。不正ならレスポンスを書き込んでfalseを返す
+func normalizeEventItems(c *gin.Context, items []EventItem, totalAmount int) ([]EventItem, bool) {
+	if len(items) == 0 {
+		return nil, true
+	}
+	if len(items) > maxEventItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Up to %d items can be added", maxEventItems)})
+		return nil, false
+	}
+
+	result := make([]EventItem, 0, len(items))
+	sum := 0
+	for _, item := range items {
+		name := sanitizeInput(item.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxEventItemNameLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item name must be 1 to %d characters", maxEventItemNameLen)})
+			return nil, false
+		}
+		if item.Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item amount must not be negative"})
+			return nil, false
+		}
+		sum += item.Amount
+		result = append(result, EventItem{Name: name, Amount: item.Amount})
+	}
+	if sum != totalAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Items total (%d) must equal the total amount (%d)", sum, totalAmount)})
+		return nil, false
+	}
+	return result, true
+}
+
+// eventParticipantView は閲覧者に応じた参加者の表示内容を返す
+// 会計者と本人は支払いの詳細まで、他の参加者は名前と精算済みかどうかのみ
+func eventParticipantView(p *Participant, viewerID string, isOrganizer bool) gin.H {
+	view := gin.H{
+		"name":    p.UserName,
+		"isGuest": p.GuestID != nil,
+		"isMe":    p.UserID == viewerID,
+		"settled": p.ApprovedAt != nil,
+	}
+	if !isOrganizer && p.UserID != viewerID {
+		return view
+	}
+
+	view["participantId"] = p.ID
+	view["userId"] = p.UserID
+	view["state"] = participantPaymentState(p)
+	view["reportedAt"] = p.ReportedAt
+	view["approvedAt"] = p.ApprovedAt
+	view["hasProof"] = p.HasProof
+	if p.HasProof {
+		view["proofUrl"] = paymentProofURL(p.ID, false)
+		view["proofThumbnailUrl"] = paymentProofURL(p.ID, true)
+	}
+	return view
+}
+
+// buildEventHistory はイベントの履歴を古い順に組み立てる
+// 会計者には全員分、参加者にはイベント全体の出来事と自分の分のみを返す
+func buildEventHistory(event *Event, organizerName string, participants []Participant, receipts []EventReceipt,
+	rejections []EventHistoryEntry, viewerID string, isOrganizer bool) []EventHistoryEntry {
+	names := make(map[string]string, len(participants))
+	for _, p := range participants {
+		names[p.UserID] = p.UserName
+	}
+
+	history := []EventHistoryEntry{{Type: "created", UserID: event.OrganizerID, UserName: organizerName, At: event.CreatedAt}}
+	for _, r := range receipts {
+		history = append(history, EventHistoryEntry{Type: "receipt_added", UserID: r.CreatedBy, UserName: organizerName, At: r.CreatedAt})
+	}
+
+	visible := func(userID string) bool {
+		return isOrganizer || userID == viewerID
+	}
+	for _, p := range participants {
+		if !visible(p.UserID) {
+			continue
+		}
+		if p.ReportedAt != nil {
+			history = append(history, EventHistoryEntry{Type: "reported", UserID: p.UserID, UserName: p.UserName, At: *p.ReportedAt})
+		}
+		if p.ApprovedAt != nil {
+			history = append(history, EventHistoryEntry{Type: "approved", UserID: p.UserID, UserName: p.UserName, At: *p.ApprovedAt})
+		}
+	}
+	for _, r := range rejections {
+		if !visible(r.UserID) {
+			continue
+		}
+		r.UserName = names[r.UserID]
+		history = append(history, r)
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].At.Before(history[j].At)
+	})
+	return history
+}
+
+// handleGetEventDetail はイベントの詳細を取得する（会計者・参加者のみ）
+// 内訳・参加者と支払い状態・会計者・支払い期限・レシート・履歴を返す
+// 参加者には他の参加者の支払いの詳細を見せない
+// GET /api/liff/events/:id
+func handleGetEventDetail(c *gin.Context) {
+	userID := GetUserID(c)
+
+	event, ok := loadEventForViewer(c, userID)
+	if !ok {
+		return
+	}
+	isOrganizer := event.OrganizerID == userID
+
+	items, err := GetEventItems(event.ID)
+	if err != nil {
+		log.Printf("内訳取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	participants, err := GetEventParticipants(event.ID)
+	if err != nil {
+		log.Printf("参加者取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	receipts, err := GetEventReceipts(event.ID)
+	if err != nil {
+		log.Printf("レシート取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	var rejections []EventHistoryEntry
+	if event.CircleID != nil {
+		rejections, err = GetEventRejections(*event.CircleID, event.ID)
+		if err != nil {
+			log.Printf("差し戻し履歴取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+			return
+		}
+	}
+
+	organizerName := event.OrganizerID
+	if event.CircleID != nil {
+		if name, _ := GetCircleMemberName(event.OrganizerID, *event.CircleID); name != "" {
+			organizerName = name
+		}
+	}
+	if organizerName == event.OrganizerID {
+		if organizer, _ := GetUser(event.OrganizerID); organizer != nil {
+			organizerName = organizer.Name
+		}
+	}
+
+	views := make([]gin.H, 0, len(participants))
+	counts := map[string]int{paymentStateUnpaid: 0, paymentStateReported: 0, paymentStateApproved: 0}
+	for i := range participants {
+		views = append(views, eventParticipantView(&participants[i], userID, isOrganizer))
+		counts[participantPaymentState(&participants[i])]++
+	}
+
+	var deadline *string
+	if event.Deadline != nil {
+		d := event.Deadline.Format("2006-01-02")
+		deadline = &d
+	}
+
+	summary := gin.H{
+		"participantCount": len(participants),
+		"settledCount":     counts[paymentStateApproved],
+	}
+	if isOrganizer {
+		summary["unpaidCount"] = counts[paymentStateUnpaid]
+		summary["reportedCount"] = counts[paymentStateReported]
+		summary["collectedAmount"] = counts[paymentStateApproved] * event.SplitAmount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"event": gin.H{
+			"id":            event.ID,
+			"name":          event.EventName,
+			"organizerId":   event.OrganizerID,
+			"organizerName": organizerName,
+			"circleId":      event.CircleID,
+			"totalAmount":   event.TotalAmount,
+			"splitAmount":   event.SplitAmount,
+			"status":        event.Status,
+			"deadline":      deadline,
+			"createdAt":     event.CreatedAt,
+			"isOrganizer":   isOrganizer,
+		},
+		"items":        items,
+		"participants": views,
+		"summary":      summary,
+		"receipts":     receipts,
+		"history":      buildEventHistory(event, organizerName, participants, receipts, rejections, userID, isOrganizer),
+	})
+}
+
+// handleUpdateEventDeadline はイベントの支払い期限を変更する（会計者のみ）
+// 空文字で期限を解除する
+// PUT /api/liff/events/:id/deadline
+func handleUpdateEventDeadline(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req struct {
+		Deadline string `json:"deadline"` // YYYY-MM-DD
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	deadline, err := parseDeadline(req.Deadline)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deadline (expected YYYY-MM-DD)"})
+		return
+	}
+
+	event, ok := loadEventForOrganizer(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := SetEventDeadline(event.ID, deadline); err != nil {
+		log.Printf("支払い期限変更エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deadline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"message":  "支払い期限を変更しました",
+		"deadline": req.Deadline,
+	})
+}
+
+// handleAddEventReceipt はイベントにレシート画像・メモを添付する（会計者のみ）
+// 画像とメモのどちらかは必須。notify=falseでなければ参加者にFlex Messageで知らせる
+// POST /api/liff/events/:id/receipts （multipart: receipt, note, notify）
+func handleAddEventReceipt(c *gin.Context) {
+	userID := GetUserID(c)
+
+	event, ok := loadEventForOrganizer(c, userID)
+	if !ok {
+		return
+	}
+
+	note := sanitizeInput(c.PostForm("note"))
+	if utf8.RuneCountInString(note) > maxEventReceiptNoteLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Note must be %d characters or less", maxEventReceiptNoteLen)})
+		return
+	}
+
+	receipt := &EventReceipt{EventID: event.ID, Note: note, CreatedBy: userID}
+
+	file, _, err := c.Request.FormFile("receipt")
+	switch {
+	case errors.Is(err, http.ErrMissingFile):
+		if note == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Receipt image or note is required"})
+			return
+		}
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt file"})
+		return
+	default:
+		defer file.Close()
+
+		// 上限+1バイトまで読んでサイズ超過を判定
+		data, err := io.ReadAll(io.LimitReader(file, maxEventReceiptSize+1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read receipt"})
+			return
+		}
+		if len(data) > maxEventReceiptSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Receipt must be 5MB or less"})
+			return
+		}
+
+		contentType := http.DetectContentType(data)
+		if !allowedImageTypes[contentType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Receipt must be a PNG, JPEG, GIF or WebP image"})
+			return
+		}
+
+		key, err := newBlobKey(fmt.Sprintf("event-receipts/%d", event.ID))
+		if err != nil {
+			log.Printf("レシートのキー生成エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
+			return
+		}
+		if err := blobStore.Put(key, data, contentType); err != nil {
+			log.Printf("レシート画像保存エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
+			return
+		}
+		receipt.BlobKey = key
+		receipt.ContentType = contentType
+	}
+
+	created, err := CreateEventReceipt(receipt)
+	if err != nil {
+		log.Printf("レシート登録エラー: %v", err)
+		if receipt.BlobKey != "" {
+			if err := blobStore.Delete(receipt.BlobKey); err != nil {
+				log.Printf("レシート画像削除エラー: %v", err)
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
+		return
+	}
+
+	if c.PostForm("notify") != "false" {
+		go notifyEventReceipt(event, created)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "レシートを添付しました",
+		"receipt": created,
+	})
+}
+
+// handleDeleteEventReceipt はイベントのレシートを削除する（会計者のみ）
+// DELETE /api/liff/events/:id/receipts/:receiptId
+func handleDeleteEventReceipt(c *gin.Context) {
+	userID := GetUserID(c)
+
+	receiptID, err := strconv.Atoi(c.Param("receiptId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	event, ok := loadEventForOrganizer(c, userID)
+	if !ok {
+		return
+	}
+
+	receipt, err := DeleteEventReceipt(event.ID, receiptID)
+	if err != nil {
+		log.Printf("レシート削除エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete receipt"})
+		return
+	}
+	if receipt == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+
+	if receipt.BlobKey != "" {
+		if err := blobStore.Delete(receipt.BlobKey); err != nil {
+			// 行は削除済みなので画像が残っても参照されない
+			log.Printf("レシート画像削除エラー: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "レシートを削除しました",
+	})
+}
+
+// handleGetEventReceiptImage はレシート画像を返す（会計者・参加者のみ）
+// GET /api/liff/events/:id/receipts/:receiptId/image
+func handleGetEventReceiptImage(c *gin.Context) {
+	userID := GetUserID(c)
+
+	receiptID, err := strconv.Atoi(c.Param("receiptId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	event, ok := loadEventForViewer(c, userID)
+	if !ok {
+		return
+	}
+
+	receipt, err := GetEventReceipt(event.ID, receiptID)
+	if err != nil {
+		log.Printf("レシート取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get receipt"})
+		return
+	}
+	if receipt == nil || receipt.BlobKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt image not found"})
+		return
+	}
+
+	data, err := blobStore.Get(receipt.BlobKey)
+	if errors.Is(err, errBlobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt image not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("レシート画像取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get receipt"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, receipt.ContentType, data)
+}
+
+// notifyEventReceipt はレシートが添付されたことを参加者にFlex Messageで知らせる
+// イベント詳細を開くボタンから内訳を確認できる
+func notifyEventReceipt(event *Event, receipt *EventReceipt) {
+	userIDs, err := GetEventParticipantUserIDs(event.ID)
+	if err != nil {
+		log.Printf("参加者取得エラー: %v", err)
+		return
+	}
+
+	// 会計者自身が参加者の場合は送らない
+	var targets []string
+	for _, id := range userIDs {
+		if id != event.OrganizerID {
+			targets = append(targets, id)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	body := []interface{}{
+		map[string]interface{}{"type": "text", "text": event.EventName, "weight": "bold", "size": "lg", "wrap": true},
+		map[string]interface{}{"type": "text", "text": fmt.Sprintf("合計 %s円 / 1人 %s円", formatAmount(event.TotalAmount), formatAmount(event.SplitAmount)), "size": "sm", "color": "#666666"},
+	}
+	if receipt.Note != "" {
+		body = append(body, map[string]interface{}{"type": "text", "text": truncateText(receipt.Note, 200), "size": "sm", "wrap": true, "margin": "md"})
+	}
+
+	content := FlexContent{
+		AltText: fmt.Sprintf("「%s」にレシートが添付されました", event.EventName),
+		Contents: map[string]interface{}{
+			"type": "bubble",
+			"header": map[string]interface{}{
+				"type":     "box",
+				"layout":   "vertical",
+				"contents": []interface{}{map[string]interface{}{"type": "text", "text": "🧾 レシートが添付されました", "weight": "bold"}},
+			},
+			"body": map[string]interface{}{
+				"type":     "box",
+				"layout":   "vertical",
+				"spacing":  "sm",
+				"contents": body,
+			},
+			"footer": map[string]interface{}{
+				"type":   "box",
+				"layout": "vertical",
+				"contents": []interface{}{map[string]interface{}{
+					"type":   "button",
+					"style":  "primary",
+					"action": ActionObject{Type: "uri", Label: "内訳を見る", URI: eventDetailURL(event.ID)},
+				}},
+			},
+		},
+	}
+
+	if err := SendMessage(MulticastDelivery{targets}, content); err != nil {
+		log.Printf("レシート通知エラー: %v", err)
+	}
+}
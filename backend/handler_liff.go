@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,8 +26,8 @@ func handleRegisterUser(c *gin.Context) {
 		return
 	}
 
-	circle := sanitizeInput(req.Circle)
-	if circle == "" {
+	circleName := sanitizeInput(req.Circle)
+	if circleName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Circle is required"})
 		return
 	}
@@ -39,7 +41,6 @@ func handleRegisterUser(c *gin.Context) {
 
 	if existingUser != nil {
 		existingUser.Name = displayName
-		existingUser.Circle = circle
 		existingUser.Step = 3
 		if err := UpdateUser(existingUser); err != nil {
 			log.Printf("ユーザー更新エラー: %v", err)
@@ -51,7 +52,6 @@ func handleRegisterUser(c *gin.Context) {
 		newUser := &User{
 			UserID: userID,
 			Name:   displayName,
-			Circle: circle,
 			Step:   3,
 		}
 		if err := SaveUser(newUser); err != nil {
@@ -62,11 +62,49 @@ func handleRegisterUser(c *gin.Context) {
 		log.Printf("新規ユーザー登録成功: %s (%s)", displayName, userID)
 	}
 
+	// Botで登録途中だった場合の入力待ち状態を破棄
+	clearConversation(userID)
+
+	// サークルに参加（存在しなければ作成、非公開サークルは参加申請）
+	circle, err := GetCircleByName(circleName)
+	if err != nil {
+		log.Printf("サークル検索エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join circle"})
+		return
+	}
+
+	pending := false
+	if circle == nil {
+		circle, err = CreateCircleAndJoin(circleName, userID)
+		if err != nil {
+			log.Printf("サークル作成エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create circle"})
+			return
+		}
+	} else {
+		pending, err = joinCircleOrRequest(userID, circle)
+		if errors.Is(err, errMembershipSuspended) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "このサークルでは休止中です。オーナーに連絡してください"})
+			return
+		}
+		if err != nil && !errors.Is(err, errAlreadyCircleMember) {
+			log.Printf("サークル参加エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join circle"})
+			return
+		}
+		if !pending {
+			if err := SetPrimaryCircle(userID, circle.ID); err != nil {
+				log.Printf("主サークル設定エラー: %v", err)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":      "ok",
 		"userId":      userID,
 		"displayName": displayName,
-		"circle":      circle,
+		"circle":      circle.Name,
+		"pending":     pending,
 	})
 }
 
@@ -121,24 +159,56 @@ func handleGetMyInfo(c *gin.Context) {
 		return
 	}
 
+	// 主サークル名（未設定なら空）
+	circleName := ""
+	primaryCircle, err := GetPrimaryCircle(userID)
+	if err != nil {
+		log.Printf("主サークル取得エラー: %v", err)
+	}
+	if primaryCircle != nil {
+		circleName = primaryCircle.Name
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":      "ok",
-		"userId":      user.UserID,
-		"name":        user.Name,
-		"displayName": displayName,
-		"circle":      user.Circle,
-		"registered":  true,
-		"step":        user.Step,
+		"status":          "ok",
+		"userId":          user.UserID,
+		"name":            user.Name,
+		"displayName":     displayName,
+		"circle":          circleName,
+		"primaryCircleId": user.PrimaryCircleID,
+		"registered":      true,
+		"step":            user.Step,
 	})
 }
 
 // ========== イベント管理API ==========
 
+// parseCircleIDQuery はクエリパラメータcircleIdを読み取る（省略時はnil）
+// 不正な値の場合はレスポンスを書き込んでfalseを返す
+func parseCircleIDQuery(c *gin.Context) (*int, bool) {
+	circleIDStr := c.Query("circleId")
+	if circleIDStr == "" {
+		return nil, true
+	}
+
+	circleID, err := strconv.Atoi(circleIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid circle ID"})
+		return nil, false
+	}
+	return &circleID, true
+}
+
 // handleGetEvents は自分が作成したイベント一覧を取得
 func handleGetEvents(c *gin.Context) {
 	userID := GetUserID(c)
 
-	events, err := GetEventsByOrganizer(userID)
+	circleID, ok := parseCircleIDQuery(c)
+	if !ok {
+		return
+	}
+
+	events, err := GetEventsByOrganizer(userID, circleID)
 	if err != nil {
 		log.Printf("イベント取得エラー: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
@@ -151,6 +221,8 @@ func handleGetEvents(c *gin.Context) {
 		response = append(response, map[string]interface{}{
 			"id":          e.ID,
 			"name":        e.Name,
+			"circleId":    e.CircleID,
+			"circleName":  e.CircleName,
 			"totalAmount": e.TotalAmount,
 			"splitAmount": e.SplitAmount,
 			"status":      e.Status,
@@ -169,9 +241,14 @@ func handleCreateEvent(c *gin.Context) {
 	userID := GetUserID(c)
 
 	var req struct {
-		EventName      string   `json:"eventName" binding:"required"`
-		TotalAmount    int      `json:"totalAmount" binding:"required,gt=0"`
-		ParticipantIDs []string `json:"participantIds" binding:"required,min=1"`
+		EventName      string       `json:"eventName" binding:"required"`
+		TotalAmount    int          `json:"totalAmount" binding:"required,gt=0"`
+		ParticipantIDs []string     `json:"participantIds"`
+		GuestIDs       []int        `json:"guestIds"` // 登録済みのゲスト
+		Guests         []guestInput `json:"guests"`   // 新しく登録するゲスト
+		CircleID       *int         `json:"circleId"` // 省略時は主サークル
+		Deadline       string       `json:"deadline"` // 支払い期限（YYYY-MM-DD、任意）
+		Items          []EventItem  `json:"items"`    // 内訳（任意、合計金額と一致すること）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -179,54 +256,125 @@ func handleCreateEvent(c *gin.Context) {
 		return
 	}
 
+	if len(req.ParticipantIDs)+len(req.GuestIDs)+len(req.Guests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one participant is required"})
+		return
+	}
+
+	deadline, err := parseDeadline(req.Deadline)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deadline (expected YYYY-MM-DD)"})
+		return
+	}
+
+	items, ok := normalizeEventItems(c, req.Items, req.TotalAmount)
+	if !ok {
+		return
+	}
+
+	newGuests := make([]guestInput, 0, len(req.Guests))
+	for _, g := range req.Guests {
+		in, ok := normalizeGuestInput(c, g)
+		if !ok {
+			return
+		}
+		newGuests = append(newGuests, in)
+	}
+
 	organizer, err := GetUser(userID)
 	if err != nil || organizer == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
 		return
 	}
 
-	splitAmount := req.TotalAmount / len(req.ParticipantIDs)
+	circleID := 0
+	switch {
+	case req.CircleID != nil:
+		circleID = *req.CircleID
+	case organizer.PrimaryCircleID != nil:
+		circleID = *organizer.PrimaryCircleID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Circle ID is required"})
+		return
+	}
 
-	eventID, err := CreateEvent(req.EventName, userID, organizer.Circle, req.TotalAmount, splitAmount)
+	isMember, err := IsCircleMember(userID, circleID)
 	if err != nil {
-		log.Printf("イベント作成エラー: %v", err)
+		log.Printf("メンバー確認エラー: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
 		return
 	}
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this circle"})
+		return
+	}
 
-	// 参加者を登録
-	for _, participantID := range req.ParticipantIDs {
-		participant, err := GetUser(participantID)
-		if err != nil || participant == nil {
-			log.Printf("参加者取得エラー: %v", participantID)
-			continue
-		}
-
-		if err := CreateParticipant(eventID, participantID, participant.Name); err != nil {
-			log.Printf("参加者登録エラー: %v", err)
-		}
+	// サークルの設定によってはオーナー・会計のみ作成できる
+	allowed, err := checkCircleEventPermission(userID, circleID)
+	if err != nil {
+		log.Printf("イベント作成権限確認エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to create events in this circle"})
+		return
 	}
 
-	// 参加者に通知を送信（非同期）
-	go func() {
-		for _, participantID := range req.ParticipantIDs {
-			notifyText := fmt.Sprintf("【割り勘のお知らせ】\n%sさんが割り勘イベントを作成しました。\n\nイベント: %s\nあなたの支払額: %d円\n支払先: %s\n\n支払いが完了したら「支払いました」と送信してください。",
-				organizer.Name, req.EventName, splitAmount, organizer.Name)
+	// 参加者は全員サークルの在籍メンバーであること
+	participantIDs := uniqueStrings(req.ParticipantIDs)
+	nonMembers, err := findNonMemberParticipants(circleID, participantIDs)
+	if err != nil {
+		log.Printf("参加者確認エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		return
+	}
+	if len(nonMembers) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Some participants are not members of this circle",
+			"nonMembers": nonMembers,
+		})
+		return
+	}
 
-			if err := PushMessage(participantID, notifyText); err != nil {
-				log.Printf("通知エラー (%s): %v", participantID, err)
-			} else {
-				log.Printf("通知成功: %s", participantID)
-			}
+	// ゲストはこのサークルの未統合のゲストであること
+	var guests []CircleGuest
+	for _, guestID := range uniqueInts(req.GuestIDs) {
+		guest, err := GetCircleGuest(circleID, guestID)
+		if err != nil {
+			log.Printf("ゲスト取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+			return
+		}
+		if guest == nil || guest.MergedUserID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Guest %d is not a guest of this circle", guestID)})
+			return
 		}
-	}()
+		guests = append(guests, *guest)
+	}
 
-	log.Printf("イベント作成成功: %s (ID: %d)", req.EventName, eventID)
+	// 新しいゲスト・支払い期限・内訳はイベントと同じトランザクションで登録する
+	eventID, _, err := createSplitEvent(organizer, &SplitEventInput{
+		EventName:      req.EventName,
+		CircleID:       circleID,
+		TotalAmount:    req.TotalAmount,
+		ParticipantIDs: participantIDs,
+		Guests:         guests,
+		NewGuests:      newGuests,
+		Deadline:       deadline,
+		Items:          items,
+	})
+	if err != nil {
+		log.Printf("イベント作成エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"eventId": eventID,
-		"message": "イベントを作成しました",
+		"status":   "ok",
+		"eventId":  eventID,
+		"circleId": circleID,
+		"message":  "イベントを作成しました",
 	})
 }
 
@@ -236,7 +384,12 @@ func handleCreateEvent(c *gin.Context) {
 func handleGetApprovals(c *gin.Context) {
 	userID := GetUserID(c)
 
-	approvals, err := GetPendingApprovals(userID)
+	circleID, ok := parseCircleIDQuery(c)
+	if !ok {
+		return
+	}
+
+	approvals, err := GetPendingApprovals(userID, circleID)
 	if err != nil {
 		log.Printf("承認一覧取得エラー: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get approvals"})
@@ -246,15 +399,22 @@ func handleGetApprovals(c *gin.Context) {
 	// レスポンス形式に変換
 	var response []map[string]interface{}
 	for _, a := range approvals {
-		response = append(response, map[string]interface{}{
+		item := map[string]interface{}{
 			"id":              a.ID,
 			"eventId":         a.EventID,
 			"participantId":   a.ParticipantID,
 			"participantName": a.ParticipantName,
 			"eventName":       a.EventName,
+			"circleId":        a.CircleID,
 			"amount":          a.Amount,
 			"reportedAt":      a.ReportedAt,
-		})
+			"hasProof":        a.HasProof,
+		}
+		if a.HasProof {
+			item["proofUrl"] = paymentProofURL(a.ID, false)
+			item["proofThumbnailUrl"] = paymentProofURL(a.ID, true)
+		}
+		response = append(response, item)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -264,6 +424,9 @@ func handleGetApprovals(c *gin.Context) {
 }
 
 // handleApprovePayments は支払いを承認
+// 承認待ちでない（未報告・承認済み・差し戻し済み）参加者や、会計者でないイベントの参加者はskippedIdsで返す
+// 承認に失敗した参加者はfailedIdsで返し、1件でも失敗した場合は500を返す
+// 失敗はなく1件も承認できなかった場合は409を返す
 func handleApprovePayments(c *gin.Context) {
 	userID := GetUserID(c)
 
@@ -276,62 +439,70 @@ func handleApprovePayments(c *gin.Context) {
 		return
 	}
 
-	for _, participantID := range req.ParticipantIDs {
-		// 権限確認
-		organizerID, err := GetParticipantOrganizerID(participantID)
-		if err != nil {
-			log.Printf("承認権限確認エラー: %v", err)
-			continue
-		}
-
-		if organizerID != userID {
+	approvedIDs := []int{}
+	skippedIDs := []int{}
+	failedIDs := []int{}
+	for _, participantID := range uniqueInts(req.ParticipantIDs) {
+		approved, err := approvePayment(participantID, userID)
+		switch {
+		case errors.Is(err, errNotOrganizer):
 			log.Printf("承認権限なし: %s", userID)
-			continue
-		}
-
-		// 承認処理
-		if err := ApproveParticipant(participantID); err != nil {
-			log.Printf("承認更新エラー: %v", err)
-			continue
+			skippedIDs = append(skippedIDs, participantID)
+		case err != nil:
+			log.Printf("承認エラー: %v", err)
+			failedIDs = append(failedIDs, participantID)
+		case !approved:
+			skippedIDs = append(skippedIDs, participantID)
+		default:
+			approvedIDs = append(approvedIDs, participantID)
 		}
+	}
 
-		// 承認通知を送信（非同期）
-		go func(pid int, organizerUserID string) {
-			info, err := GetApprovalNotifyInfo(pid)
-			if err != nil {
-				log.Printf("承認通知情報取得エラー: %v", err)
-				return
-			}
+	if len(failedIDs) > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":       "Failed to approve some payments",
+			"approvedIds": approvedIDs,
+			"skippedIds":  skippedIDs,
+			"failedIds":   failedIDs,
+		})
+		return
+	}
 
-			organizer, _ := GetUser(organizerUserID)
-			if organizer != nil {
-				notifyText := fmt.Sprintf("【支払い承認】\n%sさんが支払いを承認しました。\n\nイベント: %s\n金額: %d円\n\nありがとうございました！",
-					organizer.Name, info.EventName, info.SplitAmount)
-				PushMessage(info.ParticipantUserID, notifyText)
-				log.Printf("承認通知送信: %s", info.ParticipantName)
-			}
-		}(participantID, userID)
+	if len(approvedIDs) == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "No reported payments to approve",
+			"skippedIds": skippedIDs,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"message": "承認しました",
+		"status":      "ok",
+		"message":     "承認しました",
+		"approvedIds": approvedIDs,
+		"skippedIds":  skippedIDs,
+		"failedIds":   failedIDs,
 	})
 }
 
 // ========== サークルメンバー取得API ==========
 
-// handleGetCircleMembers は同じサークルのメンバー一覧を取得
+// handleGetCircleMembers は主サークルのメンバー一覧を取得
 func handleGetCircleMembers(c *gin.Context) {
 	userID := GetUserID(c)
 
-	user, err := GetUser(userID)
-	if err != nil || user == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+	circle, err := GetPrimaryCircle(userID)
+	if err != nil {
+		log.Printf("主サークル取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get members"})
+		return
+	}
+	if circle == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Primary circle is not set"})
 		return
 	}
 
-	members, err := GetUsersByCircle(user.Circle, userID)
+	members, err := GetCircleMembers(circle.ID, userID)
 	if err != nil {
 		log.Printf("メンバー取得エラー: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get members"})
@@ -344,7 +515,7 @@ func handleGetCircleMembers(c *gin.Context) {
 		response = append(response, map[string]interface{}{
 			"userId": m.UserID,
 			"name":   m.Name,
-			"circle": m.Circle,
+			"circle": circle.Name,
 		})
 	}
 
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== 参加者向けAPI（自分の支払い） ==========
+
+const (
+	// defaultPaymentHistoryLimit は支払い履歴を一度に返す件数の既定値
+	defaultPaymentHistoryLimit = 30
+
+	// maxPaymentHistoryLimit は支払い履歴を一度に返す件数の上限
+	maxPaymentHistoryLimit = 100
+
+	// defaultMonthlyTotalMonths は月別集計を返す月数の既定値（今月を含む）
+	defaultMonthlyTotalMonths = 12
+
+	// maxMonthlyTotalMonths は月別集計を返す月数の上限
+	maxMonthlyTotalMonths = 36
+)
+
+// paymentTotal は支払い状態ごとの件数と金額
+type paymentTotal struct {
+	Count  int `json:"count"`
+	Amount int `json:"amount"`
+}
+
+// handleGetMyPayments は自分の支払いを全サークル分、状態ごとに取得する
+// circleId・from・to（YYYY-MM-DD、イベント作成日）で絞り込める
+// GET /api/liff/me/payments
+func handleGetMyPayments(c *gin.Context) {
+	userID := GetUserID(c)
+
+	circleID, ok := parseCircleIDQuery(c)
+	if !ok {
+		return
+	}
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	payments, err := GetMyPayments(userID, MyPaymentFilter{CircleID: circleID, From: from, To: to})
+	if err != nil {
+		log.Printf("支払い一覧取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payments"})
+		return
+	}
+
+	groups := map[string][]MyPayment{
+		paymentStateUnpaid:   {},
+		paymentStateReported: {},
+		paymentStateApproved: {},
+	}
+	totals := map[string]*paymentTotal{
+		paymentStateUnpaid:   {},
+		paymentStateReported: {},
+		paymentStateApproved: {},
+	}
+	for _, p := range payments {
+		groups[p.State] = append(groups[p.State], p)
+		totals[p.State].Count++
+		totals[p.State].Amount += p.Amount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"unpaid":   groups[paymentStateUnpaid],
+		"reported": groups[paymentStateReported],
+		"approved": groups[paymentStateApproved],
+		"totals":   totals,
+	})
+}
+
+// handleReportMyPayment は未払いのイベントの支払いを報告し、会計者に通知する
+// 証拠画像を添付する場合は PUT /api/liff/events/:id/payment-proof を使う
+// POST /api/liff/me/payments/report
+func handleReportMyPayment(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var req struct {
+		EventID int `json:"eventId" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	user, err := GetUser(userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		return
+	}
+
+	participantID, err := ReportUnpaidPayment(req.EventID, userID)
+	if err == sql.ErrNoRows {
+		isParticipant, err := IsEventParticipant(req.EventID, userID)
+		if err != nil {
+			log.Printf("参加者確認エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report payment"})
+			return
+		}
+		if !isParticipant {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You are not a participant of this event"})
+			return
+		}
+		event, err := GetEvent(req.EventID)
+		if err != nil || event == nil {
+			log.Printf("イベント取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report payment"})
+			return
+		}
+		if event.Status != "selecting" && event.Status != "confirmed" {
+			c.JSON(http.StatusConflict, gin.H{"error": "This event is no longer accepting payments"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment has already been reported"})
+		return
+	}
+	if err != nil {
+		log.Printf("支払い報告エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report payment"})
+		return
+	}
+
+	event, err := GetEvent(req.EventID)
+	if err != nil || event == nil {
+		log.Printf("イベント取得エラー: %v", err)
+	} else {
+		go notifyOrganizerOfPaymentReport(user, event, participantID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "ok",
+		"message":       "支払いを報告しました。会計者の承認をお待ちください。",
+		"participantId": participantID,
+	})
+}
+
+// handleGetMyPaymentHistory は自分の支払い履歴を新しい順に取得する（報告日時・承認日時付き）
+// 続きはレスポンスのnextBeforeIdをbeforeに指定して取得する
+// GET /api/liff/me/payments/history?before=&limit=
+func handleGetMyPaymentHistory(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var err error
+	limit := defaultPaymentHistoryLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(limit, maxPaymentHistoryLimit)
+	}
+
+	beforeID := 0
+	if v := c.Query("before"); v != "" {
+		beforeID, err = strconv.Atoi(v)
+		if err != nil || beforeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+	}
+
+	payments, err := GetMyPaymentHistory(userID, beforeID, limit)
+	if err != nil {
+		log.Printf("支払い履歴取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payment history"})
+		return
+	}
+
+	var nextBeforeID *int
+	if len(payments) == limit {
+		nextBeforeID = &payments[len(payments)-1].ParticipantID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "ok",
+		"payments":     payments,
+		"nextBeforeId": nextBeforeID,
+	})
+}
+
+// handleGetMyMonthlyTotals は自分の月ごとの請求額・支払額・受取額を取得する
+// 支払いのない月は含まない
+// GET /api/liff/me/payments/monthly?months=
+func handleGetMyMonthlyTotals(c *gin.Context) {
+	userID := GetUserID(c)
+
+	months := defaultMonthlyTotalMonths
+	if v := c.Query("months"); v != "" {
+		var err error
+		months, err = strconv.Atoi(v)
+		if err != nil || months <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid months"})
+			return
+		}
+		months = min(months, maxMonthlyTotalMonths)
+	}
+
+	totals, err := GetMonthlyPaymentTotals(userID, months)
+	if err != nil {
+		log.Printf("月別集計取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get monthly totals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"months": totals,
+	})
+}
+
+// handleGetMyStatement は指定した月（省略時はDBのタイムゾーンでの今月）に承認された支払いの明細を、相手ごとに取得する
+// GET /api/liff/me/statements?month=YYYY-MM
+func handleGetMyStatement(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var month string
+	if v := c.Query("month"); v != "" {
+		var err error
+		month, err = parseStatementMonth(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month (expected YYYY-MM)"})
+			return
+		}
+	} else {
+		today, err := CurrentDate()
+		if err != nil {
+			log.Printf("日付取得エラー: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get statement"})
+			return
+		}
+		month = today.Format(statementMonthLayout)
+	}
+
+	statement, err := GetMonthlyStatement(userID, month)
+	if err != nil {
+		log.Printf("月次明細取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get statement"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"statement": statement,
+	})
+}
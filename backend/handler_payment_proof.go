@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== 支払いの証拠画像ハンドラー ==========
+
+// handleUploadPaymentProof は自分の支払い報告に証拠画像を添付する
+// 未報告の場合は報告も同時に行い、会計者に通知する
+// PUT /api/liff/events/:id/payment-proof （multipart: proof）
+func handleUploadPaymentProof(c *gin.Context) {
+	userID := GetUserID(c)
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	file, _, err := c.Request.FormFile("proof")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Proof image is required"})
+		return
+	}
+	defer file.Close()
+
+	// 上限+1バイトまで読んでサイズ超過を判定
+	data, err := io.ReadAll(io.LimitReader(file, maxPaymentProofSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read proof image"})
+		return
+	}
+	if len(data) > maxPaymentProofSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Proof image must be 5MB or less"})
+		return
+	}
+
+	proof, err := newPaymentProof(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Proof must be a PNG, JPEG, GIF or WebP image"})
+		return
+	}
+
+	participantID, newlyReported, err := ReportPaymentWithProof(eventID, userID, proof)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have no unapproved payment for this open event"})
+		return
+	}
+	if err != nil {
+		log.Printf("証拠画像保存エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save proof image"})
+		return
+	}
+
+	user, _ := GetUser(userID)
+	event, _ := GetEvent(eventID)
+	if user != nil && event != nil {
+		if newlyReported {
+			go notifyOrganizerOfPaymentReport(user, event, participantID)
+		} else {
+			go notifyOrganizerOfPaymentProof(user, &PaymentReportTarget{
+				ParticipantID: participantID,
+				EventID:       event.ID,
+				EventName:     event.EventName,
+				OrganizerID:   event.OrganizerID,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":            "ok",
+		"message":           "支払いの証拠画像を添付しました",
+		"participantId":     participantID,
+		"reported":          newlyReported,
+		"proofUrl":          paymentProofURL(participantID, false),
+		"proofThumbnailUrl": paymentProofURL(participantID, true),
+	})
+}
+
+// handleGetPaymentProof は支払い報告の証拠画像を返す（報告した本人と会計者のみ）
+// ?size=thumbnail でサムネイルを返す
+// GET /api/liff/approvals/:participantId/proof
+func handleGetPaymentProof(c *gin.Context) {
+	userID := GetUserID(c)
+
+	participantID, err := strconv.Atoi(c.Param("participantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	proof, err := GetPaymentProof(participantID, c.Query("size") == "thumbnail")
+	if err != nil {
+		log.Printf("証拠画像取得エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get proof image"})
+		return
+	}
+	if proof == nil || (proof.UserID != userID && proof.OrganizerID != userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proof image not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, proof.ContentType, proof.Data)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// ========== 会計引き継ぎリポジトリ ==========
+
+// TreasurerHandover は会計の引き継ぎ内容
+type TreasurerHandover struct {
+	CircleID   int
+	FromUserID string
+	ToUserID   string
+	EventIDs   []int // 空の場合は未完了のイベントすべてと会費スケジュール
+	ActorID    string
+	Reason     string
+}
+
+// HandoverResult は引き継ぎの結果
+type HandoverResult struct {
+	EventIDs             []int `json:"eventIds"`             // 引き継いだイベント
+	RemovedShareEventIDs []int `json:"removedShareEventIds"` // 引き継ぎ先の未承認の支払い（自分への支払いになる）を取り消したイベント
+	DuesScheduleIDs      []int `json:"duesScheduleIds"`      // 引き継いだ会費スケジュール
+}
+
+// HandoverCircleEvents は会計者の未完了イベントを別のメンバーに引き継ぎ、監査ログに記録する
+// 引き継ぎ先が参加者になっているイベントは、自分への支払いになる未承認の分を取り消して引き継ぐ
+// （承認済みの分は前の会計者に支払い済みの記録として残す）
+// 指定したイベントが対象外（別の会計者・完了済みなど）の場合はerrHandoverEventNotFoundを返す
+func HandoverCircleEvents(h *TreasurerHandover) (*HandoverResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	eventIDs := uniqueInts(h.EventIDs)
+	rows, err := tx.Query(`
+		SELECT e.id
+		FROM events e
+		WHERE e.circle_id = $1 AND e.organizer_id = $2
+		  AND e.status IN ('selecting', 'confirmed')
+		  AND (cardinality($4::INTEGER[]) = 0 OR e.id = ANY($4::INTEGER[]))
+		ORDER BY e.id
+		FOR UPDATE OF e
+	`, h.CircleID, h.FromUserID, pq.Array(eventIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get handover events: %w", err)
+	}
+
+	result := &HandoverResult{EventIDs: []int{}, RemovedShareEventIDs: []int{}, DuesScheduleIDs: []int{}}
+	for rows.Next() {
+		var eventID int
+		if err := rows.Scan(&eventID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.EventIDs = append(result.EventIDs, eventID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(eventIDs) > 0 && len(result.EventIDs) != len(eventIDs) {
+		return nil, errHandoverEventNotFound
+	}
+
+	// 引き継ぎ先自身の未承認の支払いは自分への支払いになるため取り消す
+	rows, err = tx.Query(`
+		DELETE FROM event_participants
+		WHERE event_id = ANY($1::INTEGER[]) AND user_id = $2 AND approved_at IS NULL
+		RETURNING event_id
+	`, pq.Array(result.EventIDs), h.ToUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove new treasurer's shares: %w", err)
+	}
+	for rows.Next() {
+		var eventID int
+		if err := rows.Scan(&eventID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.RemovedShareEventIDs = append(result.RemovedShareEventIDs, eventID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE events SET organizer_id = $2, updated_at = NOW()
+		WHERE id = ANY($1::INTEGER[])
+	`, pq.Array(result.EventIDs), h.ToUserID); err != nil {
+		return nil, fmt.Errorf("failed to transfer events: %w", err)
+	}
+
+	// すべて引き継ぐ場合は会費の支払先も引き継ぐ
+	if len(eventIDs) == 0 {
+		rows, err := tx.Query(`
+			UPDATE circle_dues_schedules SET organizer_id = $3, updated_at = NOW()
+			WHERE circle_id = $1 AND organizer_id = $2 AND active
+			RETURNING id
+		`, h.CircleID, h.FromUserID, h.ToUserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transfer dues schedules: %w", err)
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result.DuesScheduleIDs = append(result.DuesScheduleIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	err = recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: h.CircleID,
+		ActorID:  h.ActorID,
+		TargetID: &h.ToUserID,
+		Action:   AuditTreasurerHandover,
+		Reason:   h.Reason,
+		Details: map[string]interface{}{
+			"fromUserId":           h.FromUserID,
+			"toUserId":             h.ToUserID,
+			"eventIds":             result.EventIDs,
+			"removedShareEventIds": result.RemovedShareEventIDs,
+			"duesScheduleIds":      result.DuesScheduleIDs,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[会計引き継ぎ] circle=%d, from=%s, to=%s, events=%d, dues=%d",
+		h.CircleID, h.FromUserID, h.ToUserID, len(result.EventIDs), len(result.DuesScheduleIDs))
+	return result, nil
+}
+
+// HandoverNotice は支払先変更の通知対象（未承認の参加者）
+type HandoverNotice struct {
+	UserID    string
+	EventName string
+	Amount    int
+	Reported  bool // 支払い報告済み（承認待ち）
+}
+
+// GetHandoverNotices は引き継いだイベントの未承認の参加者（ゲストを除く）を取得する
+func GetHandoverNotices(eventIDs []int) ([]HandoverNotice, error) {
+	rows, err := db.Query(`
+		SELECT ep.user_id, e.event_name, e.split_amount, ep.paid
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		WHERE e.id = ANY($1::INTEGER[]) AND ep.approved_at IS NULL AND ep.guest_id IS NULL
+		ORDER BY ep.user_id, e.id
+	`, pq.Array(eventIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notices []HandoverNotice
+	for rows.Next() {
+		var n HandoverNotice
+		if err := rows.Scan(&n.UserID, &n.EventName, &n.Amount, &n.Reported); err != nil {
+			log.Printf("引き継ぎ通知スキャンエラー: %v", err)
+			continue
+		}
+		notices = append(notices, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notices, nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========== 出納帳リポジトリ ==========
+
+// ledgerIncomeSelect は支払い承認から収入行を作るSELECT句
+// （event_participants ep / events e を結合して使う）
+const ledgerIncomeSelect = `
+	e.circle_id, 'income', e.split_amount, e.event_name || '（' || ep.user_name || '）',
+	e.organizer_id, e.id, ep.id, COALESCE(ep.approved_at::date, CURRENT_DATE), e.organizer_id
+	FROM event_participants ep
+	JOIN events e ON ep.event_id = e.id`
+
+// ledgerBalanceDelta は記帳1行がサークル残高に与える増減（移動は0）
+const ledgerBalanceDelta = `
+	CASE l.entry_type WHEN 'income' THEN l.amount WHEN 'expense' THEN -l.amount ELSE 0 END`
+
+// ledgerEntryQuery は残高付きの出納帳を取得するクエリ
+// 残高は期間で絞り込む前にサークル全体で計算する。名前はサークル内の表示名を優先する
+const ledgerEntryQuery = `
+	SELECT x.id, x.circle_id, x.entry_type, x.amount, x.description,
+	       x.holder_id, COALESCE(NULLIF(hc.display_name, ''), h.name, ''),
+	       x.to_user_id, COALESCE(NULLIF(tc.display_name, ''), t.name, ''),
+	       x.event_id, x.participant_id, x.has_receipt, x.occurred_on, x.balance, x.created_by, x.created_at
+	FROM (
+		SELECT l.id, l.circle_id, l.entry_type, l.amount, l.description, l.holder_id, l.to_user_id,
+		       l.event_id, l.participant_id, l.receipt_data IS NOT NULL AS has_receipt,
+		       l.occurred_on, l.created_by, l.created_at,
+		       SUM(` + ledgerBalanceDelta + `) OVER (ORDER BY l.occurred_on, l.id) AS balance
+		FROM circle_ledger_entries l
+		WHERE l.circle_id = $1
+	) x
+	LEFT JOIN users h ON x.holder_id = h.user_id
+	LEFT JOIN user_circles hc ON x.holder_id = hc.user_id AND hc.circle_id = x.circle_id
+	LEFT JOIN users t ON x.to_user_id = t.user_id
+	LEFT JOIN user_circles tc ON x.to_user_id = tc.user_id AND tc.circle_id = x.circle_id`
+
+// ledgerReceiptURL は領収書画像のURLを返す
+func ledgerReceiptURL(circleID, entryID int) string {
+	return fmt.Sprintf("/api/liff/circles/%d/ledger/%d/receipt", circleID, entryID)
+}
+
+// scanLedgerEntry は出納帳の行を読み取る
+func scanLedgerEntry(row rowScanner) (*LedgerEntry, error) {
+	var entry LedgerEntry
+	var toUserID sql.NullString
+	var eventID, participantID sql.NullInt64
+	var hasReceipt bool
+
+	err := row.Scan(&entry.ID, &entry.CircleID, &entry.Type, &entry.Amount, &entry.Description,
+		&entry.HolderID, &entry.HolderName, &toUserID, &entry.ToUserName,
+		&eventID, &participantID, &hasReceipt, &entry.OccurredOn, &entry.Balance, &entry.CreatedBy, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if toUserID.Valid {
+		entry.ToUserID = &toUserID.String
+	}
+	if eventID.Valid {
+		id := int(eventID.Int64)
+		entry.EventID = &id
+	}
+	if participantID.Valid {
+		id := int(participantID.Int64)
+		entry.ParticipantID = &id
+	}
+	if hasReceipt {
+		entry.ReceiptURL = ledgerReceiptURL(entry.CircleID, entry.ID)
+	}
+	return &entry, nil
+}
+
+// recordPaymentIncome は承認された支払いをサークルの収入として記帳する
+// サークルに属さないイベントや記帳済みの支払いは何もしない
+func recordPaymentIncome(tx *sql.Tx, participantID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO circle_ledger_entries
+			(circle_id, entry_type, amount, description, holder_id, event_id, participant_id, occurred_on, created_by)
+		SELECT `+ledgerIncomeSelect+`
+		WHERE ep.id = $1 AND e.circle_id IS NOT NULL AND e.split_amount > 0
+		ON CONFLICT (participant_id) DO NOTHING
+	`, participantID)
+	if err != nil {
+		return fmt.Errorf("failed to record payment income: %w", err)
+	}
+	return nil
+}
+
+// CreateLedgerEntry は出納帳に手入力の行を追加し、監査ログに記録する
+func CreateLedgerEntry(entry *LedgerEntry) (*LedgerEntry, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO circle_ledger_entries
+			(circle_id, entry_type, amount, description, holder_id, to_user_id, event_id, occurred_on, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, entry.CircleID, entry.Type, entry.Amount, entry.Description, entry.HolderID,
+		entry.ToUserID, entry.EventID, entry.OccurredOn, entry.CreatedBy).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ledger entry: %w", err)
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: entry.CircleID,
+		ActorID:  entry.CreatedBy,
+		TargetID: &entry.HolderID,
+		Action:   AuditLedgerEntryCreated,
+		Details: map[string]interface{}{
+			"entryId":     id,
+			"type":        entry.Type,
+			"amount":      entry.Amount,
+			"description": entry.Description,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return GetLedgerEntry(entry.CircleID, id)
+}
+
+// GetLedgerEntry は出納帳の1行を取得する
+func GetLedgerEntry(circleID, entryID int) (*LedgerEntry, error) {
+	entry, err := scanLedgerEntry(db.QueryRow(ledgerEntryQuery+`
+		WHERE x.id = $2
+	`, circleID, entryID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetLedgerEntries はサークルの出納帳を新しい順に取得する
+// from・toがnilの場合は期間で絞り込まない
+func GetLedgerEntries(circleID int, from, to *time.Time) ([]LedgerEntry, error) {
+	rows, err := db.Query(ledgerEntryQuery+`
+		WHERE ($2::date IS NULL OR x.occurred_on >= $2::date)
+		  AND ($3::date IS NULL OR x.occurred_on <= $3::date)
+		ORDER BY x.occurred_on DESC, x.id DESC
+	`, circleID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			log.Printf("出納帳スキャンエラー: %v", err)
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetLedgerSummary は出納帳の集計を取得する
+// 収入・支出は期間内の合計、残高は期間末時点、手元残高は現在の値
+func GetLedgerSummary(circleID int, from, to *time.Time) (*LedgerSummary, error) {
+	var summary LedgerSummary
+	err := db.QueryRow(`
+		SELECT
+			COALESCE(SUM(l.amount) FILTER (WHERE l.entry_type = 'income'
+				AND ($2::date IS NULL OR l.occurred_on >= $2::date)), 0),
+			COALESCE(SUM(l.amount) FILTER (WHERE l.entry_type = 'expense'
+				AND ($2::date IS NULL OR l.occurred_on >= $2::date)), 0),
+			COALESCE(SUM(`+ledgerBalanceDelta+`), 0)
+		FROM circle_ledger_entries l
+		WHERE l.circle_id = $1 AND ($3::date IS NULL OR l.occurred_on <= $3::date)
+	`, circleID, from, to).Scan(&summary.Income, &summary.Expense, &summary.Balance)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT h.user_id, COALESCE(NULLIF(uc.display_name, ''), u.name, ''), SUM(h.delta)
+		FROM (
+			SELECT l.holder_id AS user_id,
+			       CASE l.entry_type WHEN 'income' THEN l.amount ELSE -l.amount END AS delta
+			FROM circle_ledger_entries l
+			WHERE l.circle_id = $1
+			UNION ALL
+			SELECT l.to_user_id, l.amount
+			FROM circle_ledger_entries l
+			WHERE l.circle_id = $1 AND l.entry_type = 'transfer'
+		) h
+		LEFT JOIN users u ON h.user_id = u.user_id
+		LEFT JOIN user_circles uc ON h.user_id = uc.user_id AND uc.circle_id = $1
+		GROUP BY h.user_id, u.name, uc.display_name
+		HAVING SUM(h.delta) != 0
+		ORDER BY SUM(h.delta) DESC
+	`, circleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary.Holdings = []LedgerHolding{}
+	for rows.Next() {
+		var h LedgerHolding
+		if err := rows.Scan(&h.UserID, &h.UserName, &h.Amount); err != nil {
+			log.Printf("手元残高スキャンエラー: %v", err)
+			continue
+		}
+		summary.Holdings = append(summary.Holdings, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// DeleteLedgerEntry は手入力の記帳を削除し、削除した内容を監査ログに記録する
+// 支払い承認による収入は削除できない。削除したかどうかを返す
+func DeleteLedgerEntry(circleID, entryID int, actorID string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var entryType, description, holderID string
+	var amount int
+	err = tx.QueryRow(`
+		DELETE FROM circle_ledger_entries
+		WHERE id = $1 AND circle_id = $2 AND participant_id IS NULL
+		RETURNING entry_type, amount, description, holder_id
+	`, entryID, circleID).Scan(&entryType, &amount, &description, &holderID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  actorID,
+		TargetID: &holderID,
+		Action:   AuditLedgerEntryDeleted,
+		Details: map[string]interface{}{
+			"entryId":     entryID,
+			"type":        entryType,
+			"amount":      amount,
+			"description": description,
+		},
+	}); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// UpdateLedgerReceipt は記帳に領収書画像を添付し（既存の画像は置き換える）、監査ログに記録する
+// 記帳が見つからない場合はfalseを返す
+func UpdateLedgerReceipt(circleID, entryID int, data []byte, contentType, actorID string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var holderID string
+	var replaced bool
+	err = tx.QueryRow(`
+		UPDATE circle_ledger_entries le
+		SET receipt_data = $3, receipt_content_type = $4
+		FROM (
+			SELECT id, receipt_data IS NOT NULL AS had_receipt FROM circle_ledger_entries
+			WHERE id = $1 AND circle_id = $2
+			FOR UPDATE
+		) old
+		WHERE le.id = old.id
+		RETURNING le.holder_id, old.had_receipt
+	`, entryID, circleID, data, contentType).Scan(&holderID, &replaced)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update ledger receipt: %w", err)
+	}
+
+	if err := recordCircleAudit(tx, &CircleAuditLog{
+		CircleID: circleID,
+		ActorID:  actorID,
+		TargetID: &holderID,
+		Action:   AuditLedgerReceiptUpdated,
+		Details: map[string]interface{}{
+			"entryId":     entryID,
+			"replaced":    replaced,
+			"contentType": contentType,
+		},
+	}); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// GetLedgerReceipt は記帳の領収書画像を取得する
+func GetLedgerReceipt(circleID, entryID int) (data []byte, contentType string, err error) {
+	var ct sql.NullString
+	err = db.QueryRow(`
+		SELECT receipt_data, receipt_content_type FROM circle_ledger_entries
+		WHERE id = $1 AND circle_id = $2 AND receipt_data IS NOT NULL
+	`, entryID, circleID).Scan(&data, &ct)
+
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, ct.String, nil
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 )
 
 // ========== Strategy Pattern: メッセージ送信システム ==========
@@ -52,11 +56,46 @@ func (c QuickReplyContent) Build() map[string]interface{} {
 	}
 }
 
+// ButtonsTemplateContent はボタン付きテンプレートメッセージ
+type ButtonsTemplateContent struct {
+	AltText string
+	Text    string // 最大160文字
+	Actions []ActionObject
+}
+
+func (c ButtonsTemplateContent) Build() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "template",
+		"altText": c.AltText,
+		"template": map[string]interface{}{
+			"type":    "buttons",
+			"text":    c.Text,
+			"actions": c.Actions,
+		},
+	}
+}
+
+// FlexContent はFlex Message（Contentsはbubbleなどのコンテナ）
+type FlexContent struct {
+	AltText  string
+	Contents map[string]interface{}
+}
+
+func (c FlexContent) Build() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "flex",
+		"altText":  c.AltText,
+		"contents": c.Contents,
+	}
+}
+
 // ========== DeliveryStrategy 実装 ==========
 
 // ReplyDelivery はReply API用の送信方式
+// UserIDを指定すると、返信トークンが失効していた場合にPushで送り直す
 type ReplyDelivery struct {
 	ReplyToken string
+	UserID     string
 }
 
 func (d ReplyDelivery) Endpoint() string {
@@ -117,8 +156,52 @@ func (d BroadcastDelivery) WrapPayload(messages []map[string]interface{}) map[st
 
 // ========== 統一送信関数 ==========
 
+// LineAPIError はLINE APIがエラーを返した場合のエラー
+type LineAPIError struct {
+	StatusCode int
+	Message    string
+	Body       string
+}
+
+func (e *LineAPIError) Error() string {
+	return fmt.Sprintf("LINE API error: %s", e.Body)
+}
+
+// isInvalidReplyTokenError は返信トークンの失効・使用済みによるエラーか判定する
+func isInvalidReplyTokenError(err error) bool {
+	var apiErr *LineAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(apiErr.Message), "invalid reply token")
+}
+
 // SendMessage は送信方式とメッセージ内容を組み合わせて送信する
+// Replyで返信トークンが無効だった場合は、送信元ユーザーへPushで送り直す
 func SendMessage(delivery DeliveryStrategy, contents ...MessageContent) error {
+	err := sendMessage(delivery, contents...)
+	if err == nil {
+		return nil
+	}
+
+	reply, ok := delivery.(ReplyDelivery)
+	if !ok || reply.UserID == "" || !isInvalidReplyTokenError(err) {
+		return err
+	}
+
+	metrics.ReplyTokenFallbacks.Add(1)
+	log.Printf("返信トークンが無効なためPushで再送します: UserID=%s", reply.UserID)
+
+	if err := sendMessage(PushDelivery{reply.UserID}, contents...); err != nil {
+		metrics.ReplyTokenFallbackFailures.Add(1)
+		return fmt.Errorf("push fallback failed: %w", err)
+	}
+	return nil
+}
+
+// sendMessage はLINE APIにメッセージを1回送信する
+func sendMessage(delivery DeliveryStrategy, contents ...MessageContent) error {
 	// メッセージ内容をビルド
 	messages := make([]map[string]interface{}, len(contents))
 	for i, content := range contents {
@@ -154,22 +237,102 @@ func SendMessage(delivery DeliveryStrategy, contents ...MessageContent) error {
 	// レスポンスを検証
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("LINE API error: %s", string(body))
+		apiErr := &LineAPIError{StatusCode: resp.StatusCode, Body: string(body)}
+
+		var errBody struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &errBody) == nil {
+			apiErr.Message = errBody.Message
+		}
+		return apiErr
 	}
 
 	return nil
 }
 
+// ========== コンテンツ取得 ==========
+
+// defaultLineDataAPIBaseURL はLINEのコンテンツ取得APIのベースURL
+const defaultLineDataAPIBaseURL = "https://api-data.line.me"
+
+// errContentTooLarge は取得したコンテンツが上限サイズを超えた場合のエラー
+var errContentTooLarge = errors.New("content is too large")
+
+// lineDataAPIBaseURL はコンテンツ取得APIのベースURLを返す
+// LINE_DATA_API_BASE_URLで変更できる（検証用のモックサーバーなど）
+func lineDataAPIBaseURL() string {
+	if baseURL := os.Getenv("LINE_DATA_API_BASE_URL"); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	return defaultLineDataAPIBaseURL
+}
+
+// GetMessageContent はユーザーが送信した画像などのコンテンツを取得する
+// maxSizeを超える場合はerrContentTooLargeを返す
+func GetMessageContent(messageID string, maxSize int64) ([]byte, error) {
+	url := fmt.Sprintf("%s/v2/bot/message/%s/content", lineDataAPIBaseURL(), messageID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("LINE_CHANNEL_ACCESS_TOKEN"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &LineAPIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	// 上限+1バイトまで読んでサイズ超過を判定
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errContentTooLarge
+	}
+	return data, nil
+}
+
+// ========== 返信先の記録 ==========
+
+// replyTargets は処理中のWebhookイベントの返信トークンと送信元ユーザーの対応
+// 返信トークンが失効していた場合のPush送信先として使う
+var replyTargets sync.Map
+
+// rememberReplyTarget は返信トークンの送信元ユーザーを記録する
+func rememberReplyTarget(replyToken, userID string) {
+	replyTargets.Store(replyToken, userID)
+}
+
+// forgetReplyTarget は返信トークンの記録を削除する
+func forgetReplyTarget(replyToken string) {
+	replyTargets.Delete(replyToken)
+}
+
+// replyDelivery は返信トークンに対応するReply送信方式を作成する
+func replyDelivery(replyToken string) ReplyDelivery {
+	userID, _ := replyTargets.Load(replyToken)
+	target, _ := userID.(string)
+	return ReplyDelivery{ReplyToken: replyToken, UserID: target}
+}
+
 // ========== 便利関数 ==========
 
 // ReplyMessage はシンプルなテキストメッセージで返信
 func ReplyMessage(replyToken, text string) error {
-	return SendMessage(ReplyDelivery{replyToken}, TextContent{text})
+	return SendMessage(replyDelivery(replyToken), TextContent{text})
 }
 
 // ReplyMessageWithQuickReply はQuick Replyボタン付きメッセージで返信
 func ReplyMessageWithQuickReply(replyToken, text string, buttons []QuickReplyButton) error {
-	return SendMessage(ReplyDelivery{replyToken}, QuickReplyContent{text, buttons})
+	return SendMessage(replyDelivery(replyToken), QuickReplyContent{text, buttons})
 }
 
 // PushMessage はユーザーにメッセージをプッシュ送信
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -76,9 +82,29 @@ func main() {
 		log.Fatal("Failed to create tables: ", err)
 	}
 
+	// ファイル保存先（イベントのレシート画像など）
+	if err := initBlobStore(); err != nil {
+		log.Fatal("Failed to initialize blob store: ", err)
+	}
+
 	// 催促システムの起動
 	startReminderScheduler()
 
+	// 定期クリーンアップの起動
+	startCleanupScheduler()
+
+	// 会費の定期生成の起動
+	startDuesScheduler()
+
+	// 月次明細の送信の起動
+	startStatementScheduler()
+
+	// Webhook処理ワーカーの起動
+	webhookDispatcher = NewWebhookDispatcher(
+		getEnvInt("WEBHOOK_WORKERS", defaultWebhookWorkers),
+		getEnvInt("WEBHOOK_QUEUE_SIZE", defaultWebhookQueueSize),
+	)
+
 	// Ginルーターをセットアップ
 	router := setupRouter()
 
@@ -93,7 +119,34 @@ func main() {
 	log.Printf("LIFF endpoints: /api/liff/*")
 	log.Printf("Admin endpoints: /api/admin/* (API key required)")
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
 	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server: ", err)
+		}
+	}()
+
+	// 終了シグナルを待つ
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 新規リクエストの受付を止めてから、キューに残ったWebhookイベントを処理し切る
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+	if err := webhookDispatcher.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Webhook worker shutdown error: %v", err)
+	}
+
+	log.Println("Server stopped")
 }
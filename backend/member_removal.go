@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ========== メンバーの退会処理 ==========
+
+// isValidDebtPolicy は未払い分の扱いとして正しいか判定する
+func isValidDebtPolicy(policy string) bool {
+	switch policy {
+	case DebtPolicyKeep, DebtPolicyCancel, DebtPolicyTransfer:
+		return true
+	}
+	return false
+}
+
+// formatOpenDebts は未払い分を1行ずつの一覧にする
+func formatOpenDebts(debts []OpenDebt) string {
+	lines := make([]string, 0, len(debts))
+	for _, d := range debts {
+		line := fmt.Sprintf("・%s（%d円）", d.EventName, d.Amount)
+		if d.Reported {
+			line += " 報告済み・確認待ち"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// notifyMemberRemoval は退会させたメンバー本人、未払い分の付け替え先・会計者、
+// 指定があればオーナーに通知する
+func notifyMemberRemoval(r *MemberRemoval, result *MemberRemovalResult, notifyOwners bool) {
+	circle, err := GetCircleByID(r.CircleID)
+	if err != nil || circle == nil {
+		log.Printf("サークル取得エラー: %v", err)
+		return
+	}
+
+	targetName := r.TargetUserID
+	if target, _ := GetUser(r.TargetUserID); target != nil {
+		targetName = target.Name
+	}
+	transferName := r.TransferToUserID
+	if r.DebtPolicy == DebtPolicyTransfer {
+		if name, _ := GetCircleMemberName(r.TransferToUserID, r.CircleID); name != "" {
+			transferName = name
+		}
+	}
+
+	// 本人への通知
+	text := fmt.Sprintf("【サークルからの退会】\n「%s」のメンバーから外れました。", circle.Name)
+	if r.Reason != "" {
+		text += fmt.Sprintf("\n\n理由: %s", r.Reason)
+	}
+	if len(result.Kept) > 0 {
+		text += fmt.Sprintf("\n\n以下の支払いは引き続き残っています。\n%s", formatOpenDebts(result.Kept))
+	}
+	if len(result.Cancelled) > 0 {
+		text += fmt.Sprintf("\n\n以下の支払いは取り消されました。\n%s", formatOpenDebts(result.Cancelled))
+	}
+	if len(result.Transferred) > 0 {
+		text += fmt.Sprintf("\n\n以下の支払いは%sさんに引き継がれました。\n%s", transferName, formatOpenDebts(result.Transferred))
+	}
+	if err := PushMessage(r.TargetUserID, text); err != nil {
+		log.Printf("退会通知エラー: %v", err)
+	}
+
+	// 付け替え先への通知
+	if len(result.Transferred) > 0 {
+		text := fmt.Sprintf("【支払いの引き継ぎ】\n「%s」を退会した%sさんの支払いを引き継ぎました。\n\n%s\n\n支払いが完了したら「支払いました」と送信してください。",
+			circle.Name, targetName, formatOpenDebts(result.Transferred))
+		if err := PushMessage(r.TransferToUserID, text); err != nil {
+			log.Printf("引き継ぎ通知エラー: %v", err)
+		}
+	}
+
+	// 取り消し・付け替えがあったイベントの会計者への通知（操作した本人を除く）
+	changedByOrganizer := make(map[string][]string)
+	var organizerIDs []string
+	addChange := func(d OpenDebt, change string) {
+		if d.OrganizerID == r.ActorID {
+			return
+		}
+		if _, ok := changedByOrganizer[d.OrganizerID]; !ok {
+			organizerIDs = append(organizerIDs, d.OrganizerID)
+		}
+		changedByOrganizer[d.OrganizerID] = append(changedByOrganizer[d.OrganizerID],
+			fmt.Sprintf("・%s（%d円）%s", d.EventName, d.Amount, change))
+	}
+	for _, d := range result.Cancelled {
+		addChange(d, "取り消し")
+	}
+	for _, d := range result.Transferred {
+		addChange(d, fmt.Sprintf("→ %sさん", transferName))
+	}
+	for _, organizerID := range organizerIDs {
+		text := fmt.Sprintf("【支払いの変更】\n%sさんが「%s」を退会したため、あなたが会計のイベントの支払いが変更されました。\n\n%s",
+			targetName, circle.Name, strings.Join(changedByOrganizer[organizerID], "\n"))
+		if err := PushMessage(organizerID, text); err != nil {
+			log.Printf("会計者への通知エラー: %v", err)
+		}
+	}
+
+	if !notifyOwners {
+		return
+	}
+
+	managerIDs, err := GetCircleManagerIDs(r.CircleID)
+	if err != nil {
+		log.Printf("管理者取得エラー: %v", err)
+		return
+	}
+	actorName := r.ActorID
+	if actor, _ := GetUser(r.ActorID); actor != nil {
+		actorName = actor.Name
+	}
+	text = fmt.Sprintf("【メンバーの退会】\n%sさんが%sさんを「%s」から退会させました。\n\n未払い: 残す%d件 / 取り消し%d件 / 付け替え%d件",
+		actorName, targetName, circle.Name, len(result.Kept), len(result.Cancelled), len(result.Transferred))
+	if r.Reason != "" {
+		text += fmt.Sprintf("\n理由: %s", r.Reason)
+	}
+	for _, managerID := range managerIDs {
+		if managerID == r.ActorID {
+			continue
+		}
+		if role, _ := GetCircleRole(managerID, r.CircleID); role != CircleRoleOwner {
+			continue
+		}
+		if err := PushMessage(managerID, text); err != nil {
+			log.Printf("オーナーへの通知エラー: %v", err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "sync/atomic"
+
+// ========== メトリクス ==========
+
+// Metrics はサーバーの動作状況を示すカウンター群（管理画面用）
+// 複数goroutineから更新されるため、すべてatomicで扱う
+type Metrics struct {
+	WebhookEventsEnqueued  atomic.Int64 // ワーカーキューに投入したイベント数
+	WebhookEventsRejected  atomic.Int64 // キュー満杯・停止中で受け付けられなかったイベント数
+	WebhookEventsProcessed atomic.Int64 // 処理が完了したイベント数
+	WebhookEventsPanicked  atomic.Int64 // 処理中にpanicしたイベント数
+
+	ReplyTokenFallbacks        atomic.Int64 // 返信トークン無効によりPushで再送した回数
+	ReplyTokenFallbackFailures atomic.Int64 // Pushでの再送にも失敗した回数
+}
+
+// metrics はプロセス全体で共有するメトリクス
+var metrics Metrics
+
+// Snapshot は現在のカウンター値を取得する
+func (m *Metrics) Snapshot() map[string]int64 {
+	return map[string]int64{
+		"webhookEventsEnqueued":  m.WebhookEventsEnqueued.Load(),
+		"webhookEventsRejected":  m.WebhookEventsRejected.Load(),
+		"webhookEventsProcessed": m.WebhookEventsProcessed.Load(),
+		"webhookEventsPanicked":  m.WebhookEventsPanicked.Load(),
+
+		"replyTokenFallbacks":        m.ReplyTokenFallbacks.Load(),
+		"replyTokenFallbackFailures": m.ReplyTokenFallbackFailures.Load(),
+	}
+}
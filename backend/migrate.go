@@ -0,0 +1,192 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// ========== バージョン管理されたマイグレーション ==========
+
+// schemaMigrationLockID は複数インスタンスの同時実行を防ぐアドバイザリロックのキー
+const schemaMigrationLockID = 7_285_301
+
+// schemaMigration は一度だけ適用されるスキーマ変更
+// 適用済みのバージョンはschema_migrationsに記録される
+type schemaMigration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// schemaMigrations は適用順に並べたマイグレーション一覧（バージョンは変更しないこと）
+var schemaMigrations = []schemaMigration{
+	{1, "drop legacy circle name columns", migrateDropLegacyCircleColumns},
+	{2, "backfill ledger income from approved payments", migrateBackfillLedgerIncome},
+}
+
+// runSchemaMigrations は未適用のマイグレーションをトランザクションごとに適用する
+func runSchemaMigrations() error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range schemaMigrations {
+		if err := applySchemaMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// applySchemaMigration は1件のマイグレーションを適用する（適用済みなら何もしない）
+func applySchemaMigration(m schemaMigration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ロック取得後に適用済みか確認する（他インスタンスが先に適用した場合に備える）
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, schemaMigrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	var applied bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)
+	`, m.version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	log.Printf("Applying migration %d: %s", m.version, m.name)
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+	`, m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// columnExists はテーブルにカラムがあるか確認する
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+		)
+	`, table, column).Scan(&exists)
+	return exists, err
+}
+
+// migrateDropLegacyCircleColumns はサークル名で紐付けていた旧カラムをサークルIDに移行して削除する
+// users.circle → user_circles / users.primary_circle_id、events.circle → events.circle_id
+func migrateDropLegacyCircleColumns(tx *sql.Tx) error {
+	hasUserCircle, err := columnExists(tx, "users", "circle")
+	if err != nil {
+		return err
+	}
+
+	if hasUserCircle {
+		steps := []struct {
+			name  string
+			query string
+		}{
+			// circlesにないサークル名を作成
+			{"circles", `
+				INSERT INTO circles (name, created_by)
+				SELECT u.circle, MIN(u.user_id)
+				FROM users u
+				WHERE u.circle IS NOT NULL AND u.circle != '' AND u.step = 3
+				  AND NOT EXISTS (SELECT 1 FROM circles c WHERE c.name = u.circle AND c.deleted_at IS NULL)
+				GROUP BY u.circle`},
+			// メンバーシップを作成
+			{"user_circles", `
+				INSERT INTO user_circles (user_id, circle_id, status)
+				SELECT u.user_id, c.id, 'active'
+				FROM users u
+				JOIN circles c ON u.circle = c.name AND c.deleted_at IS NULL
+				WHERE u.step = 3
+				ON CONFLICT (user_id, circle_id) DO NOTHING`},
+			// 主サークルを設定
+			{"primary_circle_id", `
+				UPDATE users u
+				SET primary_circle_id = c.id
+				FROM circles c
+				WHERE u.circle = c.name AND c.deleted_at IS NULL AND u.primary_circle_id IS NULL`},
+			{"drop users.circle", `ALTER TABLE users DROP COLUMN circle`},
+		}
+		for _, st := range steps {
+			if _, err := tx.Exec(st.query); err != nil {
+				return fmt.Errorf("failed to migrate %s: %w", st.name, err)
+			}
+		}
+	}
+
+	hasEventCircle, err := columnExists(tx, "events", "circle")
+	if err != nil {
+		return err
+	}
+
+	if hasEventCircle {
+		steps := []struct {
+			name  string
+			query string
+		}{
+			// サークルIDのないイベントのサークル名を作成
+			{"event circles", `
+				INSERT INTO circles (name, created_by)
+				SELECT e.circle, MIN(e.organizer_id)
+				FROM events e
+				WHERE e.circle_id IS NULL AND e.circle != ''
+				  AND NOT EXISTS (SELECT 1 FROM circles c WHERE c.name = e.circle AND c.deleted_at IS NULL)
+				GROUP BY e.circle`},
+			{"events.circle_id", `
+				UPDATE events e
+				SET circle_id = c.id
+				FROM circles c
+				WHERE e.circle = c.name AND c.deleted_at IS NULL AND e.circle_id IS NULL`},
+			{"drop idx_events_circle", `DROP INDEX IF EXISTS idx_events_circle`},
+			{"drop events.circle", `ALTER TABLE events DROP COLUMN circle`},
+		}
+		for _, st := range steps {
+			if _, err := tx.Exec(st.query); err != nil {
+				return fmt.Errorf("failed to migrate %s: %w", st.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrateBackfillLedgerIncome は出納帳導入前に承認済みの支払いを収入として記録する
+func migrateBackfillLedgerIncome(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		INSERT INTO circle_ledger_entries
+			(circle_id, entry_type, amount, description, holder_id, event_id, participant_id, occurred_on, created_by)
+		SELECT ` + ledgerIncomeSelect + `
+		WHERE ep.approved_at IS NOT NULL AND e.circle_id IS NOT NULL AND e.split_amount > 0
+		ON CONFLICT (participant_id) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill ledger income: %w", err)
+	}
+	return nil
+}
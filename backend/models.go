@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // ========== ドメインモデル ==========
 
@@ -8,38 +11,247 @@ import "time"
 type User struct {
 	UserID          string
 	Name            string
-	Circle          string // レガシー: 後方互換性のため残す
-	PrimaryCircleID *int   // 主サークルID
-	Step            int    // 0:未登録 1:名前待ち 2:サークル選択待ち 3:完了
-	SplitEventStep  int    // 0:なし 1:イベント名待ち 2:金額待ち 3:参加者選択待ち
-	TempEventID     int    // 作成中のイベントID
-	ApprovalStep    int    // 0:なし 1:承認番号待ち
-	ApprovalEventID int    // 承認中のイベントID
+	PrimaryCircleID *int // 主サークルID
+	Step            int  // 0:未登録 1:名前待ち 2:サークル選択待ち 3:完了（入力待ちの詳細はConversationで管理）
+}
+
+// Conversation はBotとの会話状態（どの入力を待っているか）を管理する構造体
+type Conversation struct {
+	UserID    string
+	State     string          // 会話状態名（state*定数）
+	Payload   json.RawMessage // 状態ごとの作業データ（JSON）
+	ExpiresAt time.Time
+	Expired   bool // 有効期限切れかどうか（DB時刻で判定）
 }
 
 // Circle はサークル情報を管理する構造体
 type Circle struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CreatedBy string    `json:"createdBy"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	CreatedBy   string    `json:"createdBy"`
+	Description string    `json:"description"`
+	IconURL     string    `json:"iconUrl,omitempty"` // アイコン未設定なら空
+	CreatedAt   time.Time `json:"createdAt"`
+	MyRole      string    `json:"myRole,omitempty"` // 所属サークル一覧でのみ設定
 }
 
+// サークル内のロール
+const (
+	CircleRoleOwner     = "owner"     // オーナー: すべての操作が可能
+	CircleRoleTreasurer = "treasurer" // 会計: メンバー管理とイベント作成が可能
+	CircleRoleMember    = "member"    // 一般メンバー
+)
+
+// イベント作成の許可範囲（サークル設定）
+const (
+	EventCreationAll      = "all"      // メンバー全員が作成できる
+	EventCreationManagers = "managers" // オーナーと会計のみ作成できる
+)
+
+// サークルのメンバーシップの状態
+const (
+	MembershipActive    = "active"    // 在籍中
+	MembershipSuspended = "suspended" // 休止中（本人からは再参加できない）
+	MembershipAlumni    = "alumni"    // OB・OG（履歴には残るが、メンバー選択や会費の対象外）
+	MembershipLeft      = "left"      // 自分で退出
+	MembershipRemoved   = "removed"   // 管理者が退会させた
+	MembershipArchived  = "archived"  // サークル削除によるアーカイブ
+)
+
+// 退会させたメンバーの未払い分の扱い
+const (
+	DebtPolicyKeep     = "keep"     // そのまま残す（引き続き支払ってもらう）
+	DebtPolicyCancel   = "cancel"   // 取り消す（会計者が負担する）
+	DebtPolicyTransfer = "transfer" // 別のメンバーに付け替える
+)
+
 // UserCircle はユーザーとサークルの関係を管理する構造体
 type UserCircle struct {
 	ID       int        `json:"id"`
 	UserID   string     `json:"userId"`
 	CircleID int        `json:"circleId"`
-	Status   string     `json:"status"` // 'active', 'left', 'removed'
+	Status   string     `json:"status"` // 'active', 'suspended', 'alumni', 'left', 'removed', 'archived'
+	Role     string     `json:"role"`   // 'owner', 'treasurer', 'member'
 	JoinedAt time.Time  `json:"joinedAt"`
 	LeftAt   *time.Time `json:"leftAt,omitempty"`
 }
 
+// サークルの公開範囲
+const (
+	CircleVisibilityPublic   = "public"   // 検索に表示され、誰でも参加できる
+	CircleVisibilityUnlisted = "unlisted" // 検索には表示されないが、名前や招待コードで参加できる
+	CircleVisibilityPrivate  = "private"  // 参加には管理者の承認が必要（招待コードを除く）
+)
+
+// CircleSettings はサークルの設定
+type CircleSettings struct {
+	EventCreation string `json:"eventCreation"` // 'all', 'managers'
+	Visibility    string `json:"visibility"`    // 'public', 'unlisted', 'private'
+}
+
+// 会費の徴収周期
+const (
+	DuesPeriodMonthly  = "monthly"  // 毎月
+	DuesPeriodSemester = "semester" // 半年ごと
+)
+
+// DuesSchedule はサークルの会費の定期徴収スケジュール
+// 期間ごとに支払い対象のイベントを自動生成する
+type DuesSchedule struct {
+	ID            int       `json:"id"`
+	CircleID      int       `json:"circleId"`
+	Name          string    `json:"name"`
+	Amount        int       `json:"amount"`
+	Period        string    `json:"period"` // 'monthly', 'semester'
+	StartDate     time.Time `json:"startDate"`
+	NextDueDate   time.Time `json:"nextDueDate"`
+	OrganizerID   string    `json:"organizerId"` // 支払先（承認する人）
+	Active        bool      `json:"active"`
+	ExemptUserIDs []string  `json:"exemptUserIds"`
+	CreatedBy     string    `json:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// 出納帳の記帳種別
+const (
+	LedgerIncome   = "income"   // 収入（支払い承認・手入力）
+	LedgerExpense  = "expense"  // 支出
+	LedgerTransfer = "transfer" // 会計担当間の現金の移動（残高は変わらない）
+)
+
+// LedgerEntry はサークル会計の出納帳の1行
+type LedgerEntry struct {
+	ID            int       `json:"id"`
+	CircleID      int       `json:"circleId"`
+	Type          string    `json:"type"` // 'income', 'expense', 'transfer'
+	Amount        int       `json:"amount"`
+	Description   string    `json:"description"`
+	HolderID      string    `json:"holderId"` // 現金を受け取った・支払った会計担当（移動では送り手）
+	HolderName    string    `json:"holderName"`
+	ToUserID      *string   `json:"toUserId,omitempty"` // 移動の受け手
+	ToUserName    string    `json:"toUserName,omitempty"`
+	EventID       *int      `json:"eventId,omitempty"`
+	ParticipantID *int      `json:"participantId,omitempty"` // 支払い承認による収入の場合
+	ReceiptURL    string    `json:"receiptUrl,omitempty"`
+	OccurredOn    time.Time `json:"occurredOn"`
+	Balance       int       `json:"balance"` // この行までの残高
+	CreatedBy     string    `json:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// LedgerHolding は会計担当ごとの手元残高
+type LedgerHolding struct {
+	UserID   string `json:"userId"`
+	UserName string `json:"userName"`
+	Amount   int    `json:"amount"`
+}
+
+// LedgerSummary は出納帳の集計
+type LedgerSummary struct {
+	Income   int             `json:"income"`
+	Expense  int             `json:"expense"`
+	Balance  int             `json:"balance"`
+	Holdings []LedgerHolding `json:"holdings"`
+}
+
+// 監査ログの操作種別
+const (
+	// メンバーシップ
+	AuditMemberJoined        = "member_joined"         // 参加・再参加
+	AuditMemberLeft          = "member_left"           // 自分で退出
+	AuditMemberRemoved       = "member_removed"        // 管理者が退会させた
+	AuditMemberStatusChanged = "member_status_changed" // 在籍状態の変更（OB・OG、休止など）
+	AuditRoleChanged         = "role_changed"          // ロールの変更
+	AuditPrimaryCircleSet    = "primary_circle_set"    // 主サークルに設定
+	AuditJoinRequestApproved = "join_request_approved" // 参加申請の承認
+	AuditJoinRequestDenied   = "join_request_denied"   // 参加申請の却下
+	AuditCircleDeleted       = "circle_deleted"        // サークルの削除
+
+	// お金
+	AuditPaymentApproved      = "payment_approved"       // 支払い報告の承認
+	AuditPaymentRejected      = "payment_rejected"       // 支払い報告の差し戻し
+	AuditLedgerEntryCreated   = "ledger_entry_created"   // 出納帳の記帳
+	AuditLedgerEntryDeleted   = "ledger_entry_deleted"   // 出納帳の記帳の削除
+	AuditLedgerReceiptUpdated = "ledger_receipt_updated" // 出納帳の領収書の添付・置き換え
+	AuditDuesCreated          = "dues_created"           // 会費スケジュールの作成
+	AuditDuesUpdated          = "dues_updated"           // 会費スケジュールの変更
+	AuditDuesStopped          = "dues_stopped"           // 会費スケジュールの停止
+	AuditTreasurerHandover    = "treasurer_handover"     // 会計の引き継ぎ
+	AuditGuestPaymentMarked   = "guest_payment_marked"   // ゲストの支払いを会計者が記録
+	AuditGuestMerged          = "guest_merged"           // ゲストを登録ユーザーに統合
+)
+
+// auditSystemActor は自動処理（スケジューラーなど）による操作の実行者
+const auditSystemActor = "system"
+
+// CircleAuditLog はサークルの監査ログの1行
+type CircleAuditLog struct {
+	ID         int                    `json:"id"`
+	CircleID   int                    `json:"circleId"`
+	ActorID    string                 `json:"actorId"`
+	ActorName  string                 `json:"actorName"`
+	TargetID   *string                `json:"targetId,omitempty"`
+	TargetName string                 `json:"targetName,omitempty"`
+	Action     string                 `json:"action"`
+	Reason     string                 `json:"reason"`
+	Details    map[string]interface{} `json:"details"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// 参加申請のステータス
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestDenied   = "denied"
+)
+
+// CircleJoinRequest は非公開サークルへの参加申請
+type CircleJoinRequest struct {
+	ID        int        `json:"id"`
+	CircleID  int        `json:"circleId"`
+	UserID    string     `json:"userId"`
+	UserName  string     `json:"userName"`
+	Status    string     `json:"status"` // 'pending', 'approved', 'denied'
+	DecidedBy *string    `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// CircleInvite はサークルの招待コード
+type CircleInvite struct {
+	ID        int        `json:"id"`
+	CircleID  int        `json:"circleId"`
+	Code      string     `json:"code"`
+	CreatedBy string     `json:"createdBy"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // nilなら無期限
+	MaxUses   *int       `json:"maxUses,omitempty"`   // nilなら回数無制限
+	UseCount  int        `json:"useCount"`
+	Revoked   bool       `json:"revoked"`
+	Usable    bool       `json:"usable"` // 現在使えるかどうか（DB時刻で判定）
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
 // CircleMember はサークルメンバー情報（API用）
 type CircleMember struct {
-	UserID   string    `json:"userId"`
-	Name     string    `json:"name"`
-	JoinedAt time.Time `json:"joinedAt"`
+	UserID      string    `json:"userId"`
+	Name        string    `json:"name"`        // サークル内の表示名（未設定ならLINEの名前）
+	DisplayName string    `json:"displayName"` // サークル内の表示名（未設定なら空）
+	Role        string    `json:"role"`
+	Status      string    `json:"status"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+// CircleGuest はLINEアカウントを持たないゲスト（サークルごと）
+// 支払いは会計者が手動で記録する
+type CircleGuest struct {
+	ID           int        `json:"id"`
+	CircleID     int        `json:"circleId"`
+	Name         string     `json:"name"`
+	Contact      string     `json:"contact"` // 連絡先（任意）
+	CreatedBy    string     `json:"createdBy"`
+	MergedUserID *string    `json:"mergedUserId,omitempty"` // 統合先のユーザー
+	MergedAt     *time.Time `json:"mergedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
 }
 
 // Event は割り勘イベント情報を管理する構造体
@@ -47,15 +259,41 @@ type Event struct {
 	ID          int
 	EventName   string
 	OrganizerID string
-	Circle      string // レガシー: 後方互換性のため残す
-	CircleID    *int   // サークルID
+	CircleID    *int // サークルID
 	TotalAmount int
 	SplitAmount int
-	Status      string // 'selecting' / 'confirmed' / 'completed'
+	Status      string     // 'selecting' / 'confirmed' / 'completed' / 'archived'
+	Deadline    *time.Time // 支払い期限（未設定ならnil）
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
+// EventItem はイベントの内訳の1行
+type EventItem struct {
+	Name   string `json:"name"`
+	Amount int    `json:"amount"`
+}
+
+// EventHistoryEntry はイベントの履歴の1件
+type EventHistoryEntry struct {
+	Type     string    `json:"type"` // 'created' / 'reported' / 'approved' / 'rejected' / 'receipt_added'
+	UserID   string    `json:"userId,omitempty"`
+	UserName string    `json:"userName,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// EventReceipt はイベントに添付されたレシート画像・メモ
+type EventReceipt struct {
+	ID          int       `json:"id"`
+	EventID     int       `json:"eventId"`
+	Note        string    `json:"note"`
+	ImageURL    string    `json:"imageUrl,omitempty"` // 画像がなければ空
+	BlobKey     string    `json:"-"`
+	ContentType string    `json:"-"`
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
 // Participant はイベント参加者情報を管理する構造体
 type Participant struct {
 	ID         int
@@ -65,9 +303,64 @@ type Participant struct {
 	Paid       bool
 	ReportedAt *time.Time
 	ApprovedAt *time.Time
+	GuestID    *int // ゲストの場合のみ
+	HasProof   bool // 証拠画像が添付されている
 	CreatedAt  time.Time
 }
 
+// MyPayment は参加者から見た自分の支払い1件（LIFF用）
+type MyPayment struct {
+	ParticipantID int        `json:"participantId"`
+	EventID       int        `json:"eventId"`
+	EventName     string     `json:"eventName"`
+	CircleID      *int       `json:"circleId"`
+	CircleName    string     `json:"circleName"`
+	OrganizerID   string     `json:"organizerId"`
+	OrganizerName string     `json:"organizerName"`
+	Amount        int        `json:"amount"`
+	State         string     `json:"state"` // 'unpaid' / 'reported' / 'approved'
+	Deadline      *time.Time `json:"deadline,omitempty"`
+	ReportedAt    *time.Time `json:"reportedAt,omitempty"`
+	ApprovedAt    *time.Time `json:"approvedAt,omitempty"`
+	HasProof      bool       `json:"hasProof"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// MonthlyPaymentTotal はユーザーの月ごとの支払い集計
+// 請求額はイベント作成月、支払額・受取額は承認月で集計する
+type MonthlyPaymentTotal struct {
+	Month    string `json:"month"` // YYYY-MM
+	Billed   int    `json:"billed"`
+	Paid     int    `json:"paid"`
+	Received int    `json:"received"`
+}
+
+// StatementEntry は月次明細の支払い1件
+type StatementEntry struct {
+	EventID    int       `json:"eventId"`
+	EventName  string    `json:"eventName"`
+	CircleName string    `json:"circleName"`
+	Amount     int       `json:"amount"`
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// StatementCounterparty は月次明細の相手（支払先または支払元）ごとの集計
+type StatementCounterparty struct {
+	UserID  string           `json:"userId"`
+	Name    string           `json:"name"`
+	Total   int              `json:"total"`
+	Entries []StatementEntry `json:"entries"`
+}
+
+// MonthlyStatement はユーザーの1か月分の支払い明細（承認された支払いのみ）
+type MonthlyStatement struct {
+	Month         string                  `json:"month"`    // YYYY-MM
+	Paid          []StatementCounterparty `json:"paid"`     // 自分が支払った相手
+	Received      []StatementCounterparty `json:"received"` // 自分が受け取った相手
+	PaidTotal     int                     `json:"paidTotal"`
+	ReceivedTotal int                     `json:"receivedTotal"`
+}
+
 // UnpaidParticipant は未払い参加者情報（催促用）
 type UnpaidParticipant struct {
 	UserID      string
@@ -94,16 +387,37 @@ type WebhookRequest struct {
 
 // WebhookEvent はWebhookイベント
 type WebhookEvent struct {
-	Type       string  `json:"type"`
-	ReplyToken string  `json:"replyToken"`
-	Message    Message `json:"message"`
-	Source     Source  `json:"source"`
+	Type            string          `json:"type"`
+	WebhookEventID  string          `json:"webhookEventId"`
+	DeliveryContext DeliveryContext `json:"deliveryContext"`
+	ReplyToken      string          `json:"replyToken"`
+	Message         Message         `json:"message"`
+	Postback        Postback        `json:"postback"`
+	Source          Source          `json:"source"`
+}
+
+// DeliveryContext はWebhookの配信情報
+type DeliveryContext struct {
+	IsRedelivery bool `json:"isRedelivery"`
 }
 
 // Message はメッセージ内容
 type Message struct {
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Text            string          `json:"text"`
+	ContentProvider ContentProvider `json:"contentProvider"` // 画像などのみ
+}
+
+// ContentProvider は画像などのコンテンツの提供元
+// 'line'ならコンテンツ取得APIで取得できる。'external'は外部URL
+type ContentProvider struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+}
+
+// Postback はポストバックアクションの内容
+type Postback struct {
+	Data string `json:"data"`
 }
 
 // Source はメッセージ送信元
@@ -121,10 +435,12 @@ type QuickReplyButton struct {
 
 // ActionObject はボタンアクション
 type ActionObject struct {
-	Type  string `json:"type"`
-	Label string `json:"label"`
-	Text  string `json:"text,omitempty"`
-	URI   string `json:"uri,omitempty"`
+	Type        string `json:"type"`
+	Label       string `json:"label"`
+	Text        string `json:"text,omitempty"`
+	URI         string `json:"uri,omitempty"`
+	Data        string `json:"data,omitempty"`        // postback用
+	DisplayText string `json:"displayText,omitempty"` // postback用（タップ時にトークに表示）
 }
 
 // ========== リッチメニュー構造体 ==========
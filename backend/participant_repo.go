@@ -1,22 +1,16 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"time"
 )
 
 // ========== 参加者リポジトリ ==========
 
-// CreateParticipant はイベント参加者を追加する
-func CreateParticipant(eventID int, userID, userName string) error {
-	_, err := db.Exec(`
-		INSERT INTO event_participants (event_id, user_id, user_name, paid)
-		VALUES ($1, $2, $3, false)
-	`, eventID, userID, userName)
-	return err
-}
-
 // GetUnpaidParticipants は未払い参加者を取得する（催促用）
+// ゲストにはLINEで送れないため含めない
 func GetUnpaidParticipants() ([]UnpaidParticipant, error) {
 	rows, err := db.Query(`
 		SELECT
@@ -30,6 +24,7 @@ func GetUnpaidParticipants() ([]UnpaidParticipant, error) {
 		INNER JOIN events e ON ep.event_id = e.id
 		WHERE ep.paid = FALSE
 		  AND ep.approved_at IS NULL
+		  AND ep.guest_id IS NULL
 		  AND e.status IN ('confirmed', 'selecting')
 		ORDER BY ep.created_at ASC
 	`)
@@ -62,19 +57,24 @@ type PendingApproval struct {
 	ParticipantID   string
 	ParticipantName string
 	EventName       string
+	CircleID        *int
 	Amount          int
 	ReportedAt      *string
+	HasProof        bool // 証拠画像が添付されている
 }
 
 // GetPendingApprovals は指定ユーザー（会計者）の承認待ち一覧を取得する
-func GetPendingApprovals(organizerID string) ([]PendingApproval, error) {
+// circleIDを指定した場合はそのサークルのイベントのみ
+func GetPendingApprovals(organizerID string, circleID *int) ([]PendingApproval, error) {
 	rows, err := db.Query(`
-		SELECT ep.id, ep.event_id, ep.user_id, ep.user_name, e.event_name, e.split_amount, ep.reported_at
+		SELECT ep.id, ep.event_id, ep.user_id, ep.user_name, e.event_name, e.circle_id, e.split_amount, ep.reported_at,
+		       ep.proof_data IS NOT NULL
 		FROM event_participants ep
 		JOIN events e ON ep.event_id = e.id
 		WHERE e.organizer_id = $1 AND ep.paid = true AND ep.approved_at IS NULL
+		  AND ($2::INTEGER IS NULL OR e.circle_id = $2)
 		ORDER BY ep.reported_at DESC
-	`, organizerID)
+	`, organizerID, circleID)
 	if err != nil {
 		return nil, err
 	}
@@ -83,10 +83,15 @@ func GetPendingApprovals(organizerID string) ([]PendingApproval, error) {
 	var approvals []PendingApproval
 	for rows.Next() {
 		var a PendingApproval
-		if err := rows.Scan(&a.ID, &a.EventID, &a.ParticipantID, &a.ParticipantName, &a.EventName, &a.Amount, &a.ReportedAt); err != nil {
+		var eventCircleID sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.EventID, &a.ParticipantID, &a.ParticipantName, &a.EventName, &eventCircleID, &a.Amount, &a.ReportedAt, &a.HasProof); err != nil {
 			log.Printf("承認スキャンエラー: %v", err)
 			continue
 		}
+		if eventCircleID.Valid {
+			id := int(eventCircleID.Int64)
+			a.CircleID = &id
+		}
 		approvals = append(approvals, a)
 	}
 
@@ -109,14 +114,80 @@ func GetParticipantOrganizerID(participantID int) (string, error) {
 	return organizerID, err
 }
 
-// ApproveParticipant は参加者の支払いを承認する
-func ApproveParticipant(participantID int) error {
-	_, err := db.Exec(`
+// ApproveParticipant は参加者の支払いを承認し、サークルのイベントなら出納帳への記帳と監査ログの記録をする
+// 支払い報告済みかつ未承認の場合のみ更新し、更新したかどうかを返す
+func ApproveParticipant(participantID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
 		UPDATE event_participants
 		SET approved_at = NOW()
-		WHERE id = $1
+		WHERE id = $1 AND paid = true AND approved_at IS NULL
+	`, participantID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := recordPaymentIncome(tx, participantID); err != nil {
+		return false, err
+	}
+	if err := recordParticipantAudit(tx, participantID, AuditPaymentApproved); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// RejectPaymentReport は支払い報告を差し戻す（未払いに戻し、証拠画像も外す）
+// 支払い報告済みかつ未承認の場合のみ更新し、更新したかどうかを返す
+func RejectPaymentReport(participantID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE event_participants
+		SET paid = false, reported_at = NULL,
+		    proof_data = NULL, proof_content_type = NULL, proof_thumbnail = NULL, proof_uploaded_at = NULL
+		WHERE id = $1 AND paid = true AND approved_at IS NULL
 	`, participantID)
-	return err
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := recordParticipantAudit(tx, participantID, AuditPaymentRejected); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
 }
 
 // ApprovalNotifyInfo は承認通知用の情報
@@ -142,12 +213,228 @@ func GetApprovalNotifyInfo(participantID int) (*ApprovalNotifyInfo, error) {
 	return &info, nil
 }
 
-// ReportPayment は支払い報告を記録する
-func ReportPayment(eventID int, userID string) error {
-	_, err := db.Exec(`
+// ReportPayment は支払い報告を記録し、参加者レコードのIDを返す
+func ReportPayment(eventID int, userID string) (int, error) {
+	var participantID int
+	err := db.QueryRow(`
 		UPDATE event_participants
 		SET paid = true, reported_at = NOW()
 		WHERE event_id = $1 AND user_id = $2
-	`, eventID, userID)
-	return err
+		RETURNING id
+	`, eventID, userID).Scan(&participantID)
+	return participantID, err
+}
+
+// PaymentReportTarget は証拠画像を添付する支払い報告
+type PaymentReportTarget struct {
+	ParticipantID int
+	EventID       int
+	EventName     string
+	OrganizerID   string
+}
+
+// GetLatestPendingReport はユーザーの承認待ちの支払い報告のうち最後に報告したものを取得する
+func GetLatestPendingReport(userID string) (*PaymentReportTarget, error) {
+	var t PaymentReportTarget
+	err := db.QueryRow(`
+		SELECT ep.id, e.id, e.event_name, e.organizer_id
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		WHERE ep.user_id = $1 AND ep.paid = true AND ep.approved_at IS NULL
+		ORDER BY ep.reported_at DESC NULLS LAST, ep.id DESC
+		LIMIT 1
+	`, userID).Scan(&t.ParticipantID, &t.EventID, &t.EventName, &t.OrganizerID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SavePaymentProof は承認待ちの支払い報告に証拠画像を添付する（既存の画像は置き換える）
+// 承認待ちでなかった（承認済み・差し戻し済みなど）場合はfalseを返す
+func SavePaymentProof(participantID int, proof *PaymentProof) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE event_participants
+		SET proof_data = $2, proof_content_type = $3, proof_thumbnail = $4, proof_uploaded_at = NOW()
+		WHERE id = $1 AND paid = true AND approved_at IS NULL
+	`, participantID, proof.Data, proof.ContentType, proof.Thumbnail)
+	if err != nil {
+		return false, fmt.Errorf("failed to save payment proof: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// ReportPaymentWithProof は証拠画像を添付して支払いを報告し、参加者レコードのIDを返す
+// 報告済みの場合は画像のみ置き換える。newlyReportedは今回初めて報告したかどうか
+// 参加者でないか承認済み、またはイベントが受付中（選択中・確定）でない場合はsql.ErrNoRowsを返す
+func ReportPaymentWithProof(eventID int, userID string, proof *PaymentProof) (participantID int, newlyReported bool, err error) {
+	err = db.QueryRow(`
+		WITH old AS (
+			SELECT ep.id, ep.paid FROM event_participants ep
+			JOIN events e ON ep.event_id = e.id
+			WHERE ep.event_id = $1 AND ep.user_id = $2 AND ep.approved_at IS NULL
+			  AND e.status IN ('selecting', 'confirmed')
+			FOR UPDATE OF ep
+		)
+		UPDATE event_participants ep
+		SET paid = true,
+		    reported_at = CASE WHEN old.paid THEN ep.reported_at ELSE NOW() END,
+		    proof_data = $3, proof_content_type = $4, proof_thumbnail = $5, proof_uploaded_at = NOW()
+		FROM old
+		WHERE ep.id = old.id
+		RETURNING ep.id, NOT old.paid
+	`, eventID, userID, proof.Data, proof.ContentType, proof.Thumbnail).Scan(&participantID, &newlyReported)
+	return participantID, newlyReported, err
+}
+
+// StoredPaymentProof は保存済みの証拠画像と閲覧権限の確認に使う情報
+type StoredPaymentProof struct {
+	UserID      string
+	OrganizerID string
+	Data        []byte
+	ContentType string
+}
+
+// GetPaymentProof は支払い報告の証拠画像を取得する
+// thumbnailがtrueならサムネイル（生成できなかった画像は元の画像）を返す。画像がなければnil
+func GetPaymentProof(participantID int, thumbnail bool) (*StoredPaymentProof, error) {
+	var p StoredPaymentProof
+	var contentType sql.NullString
+	err := db.QueryRow(`
+		SELECT ep.user_id, e.organizer_id,
+		       CASE WHEN $2 AND ep.proof_thumbnail IS NOT NULL THEN ep.proof_thumbnail ELSE ep.proof_data END,
+		       CASE WHEN $2 AND ep.proof_thumbnail IS NOT NULL THEN 'image/jpeg' ELSE ep.proof_content_type END
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		WHERE ep.id = $1 AND ep.proof_data IS NOT NULL
+	`, participantID, thumbnail).Scan(&p.UserID, &p.OrganizerID, &p.Data, &contentType)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.ContentType = contentType.String
+	return &p, nil
+}
+
+// MyPaymentFilter は自分の支払い一覧の絞り込み条件（nilの項目は絞り込まない）
+type MyPaymentFilter struct {
+	CircleID *int
+	From     *time.Time // イベント作成日
+	To       *time.Time
+}
+
+// myPaymentColumns は自分の支払い（MyPayment）を取得するSELECT句とJOIN
+// 会計者名はイベントのサークル内の表示名を優先する
+const myPaymentColumns = `
+	SELECT ep.id, e.id, e.event_name, e.circle_id, COALESCE(c.name, ''),
+	       e.organizer_id, COALESCE(NULLIF(oc.display_name, ''), o.name, ''),
+	       e.split_amount,
+	       CASE WHEN ep.approved_at IS NOT NULL THEN 'approved' WHEN ep.paid THEN 'reported' ELSE 'unpaid' END,
+	       e.payment_deadline, ep.reported_at, ep.approved_at, ep.proof_data IS NOT NULL, e.created_at
+	FROM event_participants ep
+	JOIN events e ON ep.event_id = e.id
+	LEFT JOIN circles c ON e.circle_id = c.id
+	LEFT JOIN users o ON e.organizer_id = o.user_id
+	LEFT JOIN user_circles oc ON e.organizer_id = oc.user_id AND oc.circle_id = e.circle_id`
+
+// scanMyPayment はmyPaymentColumnsの1行を読み込む
+func scanMyPayment(row rowScanner) (*MyPayment, error) {
+	var p MyPayment
+	var circleID sql.NullInt64
+	var deadline, reportedAt, approvedAt sql.NullTime
+	if err := row.Scan(&p.ParticipantID, &p.EventID, &p.EventName, &circleID, &p.CircleName,
+		&p.OrganizerID, &p.OrganizerName, &p.Amount, &p.State,
+		&deadline, &reportedAt, &approvedAt, &p.HasProof, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	if circleID.Valid {
+		id := int(circleID.Int64)
+		p.CircleID = &id
+	}
+	if deadline.Valid {
+		p.Deadline = &deadline.Time
+	}
+	if reportedAt.Valid {
+		p.ReportedAt = &reportedAt.Time
+	}
+	if approvedAt.Valid {
+		p.ApprovedAt = &approvedAt.Time
+	}
+	return &p, nil
+}
+
+// queryMyPayments はmyPaymentColumnsに続く条件でクエリを実行し、結果を読み込む
+func queryMyPayments(conditions string, args ...interface{}) ([]MyPayment, error) {
+	rows, err := db.Query(myPaymentColumns+conditions, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []MyPayment{}
+	for rows.Next() {
+		p, err := scanMyPayment(rows)
+		if err != nil {
+			log.Printf("支払いスキャンエラー: %v", err)
+			continue
+		}
+		payments = append(payments, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// GetMyPayments はユーザーの参加しているイベントの支払いを全サークル分、新しい順に取得する
+// 未払いの分は受付中（選択中・確定）のイベントのみ。アーカイブ・完了したイベントの未払いは支払えないため含めない
+func GetMyPayments(userID string, f MyPaymentFilter) ([]MyPayment, error) {
+	return queryMyPayments(`
+		WHERE ep.user_id = $1
+		  AND (ep.paid OR ep.approved_at IS NOT NULL OR e.status IN ('selecting', 'confirmed'))
+		  AND ($2::INTEGER IS NULL OR e.circle_id = $2)
+		  AND ($3::date IS NULL OR e.created_at >= $3::date)
+		  AND ($4::date IS NULL OR e.created_at < $4::date + 1)
+		ORDER BY e.created_at DESC, e.id DESC
+	`, userID, f.CircleID, f.From, f.To)
+}
+
+// GetMyPaymentHistory はユーザーの支払い履歴を新しい順にlimit件取得する
+// beforeIDを指定するとそれより前（参加者レコードのIDが小さいもの）を取得する
+func GetMyPaymentHistory(userID string, beforeID, limit int) ([]MyPayment, error) {
+	return queryMyPayments(`
+		WHERE ep.user_id = $1
+		  AND ($2 = 0 OR ep.id < $2)
+		ORDER BY ep.id DESC
+		LIMIT $3
+	`, userID, beforeID, limit)
+}
+
+// ReportUnpaidPayment は受付中（選択中・確定）のイベントで未払いの場合のみ支払いを報告し、参加者レコードのIDを返す
+// 未払いの参加者レコードがない（参加者でない・報告済み・承認済み・イベントが受付中でない）場合はsql.ErrNoRowsを返す
+func ReportUnpaidPayment(eventID int, userID string) (int, error) {
+	var participantID int
+	err := db.QueryRow(`
+		UPDATE event_participants ep
+		SET paid = true, reported_at = NOW()
+		FROM events e
+		WHERE ep.event_id = e.id AND e.status IN ('selecting', 'confirmed')
+		  AND ep.event_id = $1 AND ep.user_id = $2 AND ep.paid = false AND ep.approved_at IS NULL
+		RETURNING ep.id
+	`, eventID, userID).Scan(&participantID)
+	return participantID, err
 }
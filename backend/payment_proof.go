@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // image.Decodeで読めるように登録
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+)
+
+// ========== 支払いの証拠画像 ==========
+
+const (
+	// maxPaymentProofSize は証拠画像の最大サイズ（バイト）
+	maxPaymentProofSize = 5 << 20
+
+	// paymentProofThumbnailSize はサムネイルの長辺のピクセル数
+	paymentProofThumbnailSize = 240
+
+	// maxThumbnailSourcePixels はサムネイルを作る元画像の最大ピクセル数
+	// 小さいファイルでも巨大なサイズを宣言した画像はデコード時に大量のメモリを使うため、これを超える場合は作らない
+	maxThumbnailSourcePixels = 4096 * 4096
+)
+
+// errUnsupportedImage は受け付けない形式の画像の場合のエラー
+var errUnsupportedImage = errors.New("unsupported image type")
+
+// PaymentProof は支払い報告に添付する証拠画像
+type PaymentProof struct {
+	Data        []byte
+	ContentType string
+	Thumbnail   []byte // JPEG。生成できない形式（WebPなど）ならnil
+}
+
+// newPaymentProof は画像データから証拠画像を作る（サムネイルも生成する）
+func newPaymentProof(data []byte) (*PaymentProof, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedImageTypes[contentType] {
+		return nil, errUnsupportedImage
+	}
+	return &PaymentProof{
+		Data:        data,
+		ContentType: contentType,
+		Thumbnail:   makeThumbnail(data),
+	}, nil
+}
+
+// makeThumbnail は長辺がpaymentProofThumbnailSizeに収まるJPEGのサムネイルを作る
+// デコードできない画像や、ピクセル数がmaxThumbnailSourcePixelsを超える画像の場合はnilを返す
+func makeThumbnail(data []byte) []byte {
+	// デコード前にヘッダーだけ読んでサイズを確認する
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxThumbnailSourcePixels/config.Height {
+		log.Printf("サムネイル生成をスキップ: 画像が大きすぎます (%dx%d)", config.Width, config.Height)
+		return nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil
+	}
+
+	tw, th := w, h
+	if longest := max(w, h); longest > paymentProofThumbnailSize {
+		tw = max(1, w*paymentProofThumbnailSize/longest)
+		th = max(1, h*paymentProofThumbnailSize/longest)
+	}
+
+	// 最近傍法で縮小する（確認用のサムネイルなので画質は問わない）
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy := bounds.Min.Y + y*h/th
+		for x := 0; x < tw; x++ {
+			dst.Set(x, y, src.At(bounds.Min.X+x*w/tw, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 75}); err != nil {
+		log.Printf("サムネイル生成エラー: %v", err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// paymentProofURL は証拠画像のURLを返す
+func paymentProofURL(participantID int, thumbnail bool) string {
+	url := fmt.Sprintf("/api/liff/approvals/%d/proof", participantID)
+	if thumbnail {
+		url += "?size=thumbnail"
+	}
+	return url
+}
+
+// notifyOrganizerOfPaymentProof は支払い報告に画像が添付されたことを会計者に通知する
+func notifyOrganizerOfPaymentProof(reporter *User, target *PaymentReportTarget) {
+	text := fmt.Sprintf("📎 支払いの証拠画像\n\n%sさんが「%s」の支払い報告に画像を添付しました。\n承認画面から確認してください。",
+		reporter.Name, target.EventName)
+	if err := PushMessage(target.OrganizerID, text); err != nil {
+		log.Printf("証拠画像通知エラー: %v", err)
+	}
+}
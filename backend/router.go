@@ -33,10 +33,23 @@ func setupRouter() *gin.Engine {
 			liff.POST("/register", handleRegisterUser)
 			liff.POST("/message", handleLIFFMessage)
 			liff.GET("/me", handleGetMyInfo)
+			liff.GET("/me/payments", handleGetMyPayments)
+			liff.POST("/me/payments/report", handleReportMyPayment)
+			liff.GET("/me/payments/history", handleGetMyPaymentHistory)
+			liff.GET("/me/payments/monthly", handleGetMyMonthlyTotals)
+			liff.GET("/me/statements", handleGetMyStatement)
 			liff.GET("/events", handleGetEvents)
 			liff.POST("/events", handleCreateEvent)
+			liff.POST("/events/:id/guest-payments", handleMarkGuestPayments)
+			liff.PUT("/events/:id/payment-proof", handleUploadPaymentProof)
+			liff.GET("/events/:id", handleGetEventDetail)
+			liff.PUT("/events/:id/deadline", handleUpdateEventDeadline)
+			liff.POST("/events/:id/receipts", handleAddEventReceipt)
+			liff.DELETE("/events/:id/receipts/:receiptId", handleDeleteEventReceipt)
+			liff.GET("/events/:id/receipts/:receiptId/image", handleGetEventReceiptImage)
 			liff.GET("/approvals", handleGetApprovals)
 			liff.POST("/approvals", handleApprovePayments)
+			liff.GET("/approvals/:participantId/proof", handleGetPaymentProof)
 			liff.GET("/circle/members", handleGetCircleMembers) // レガシー互換
 
 			// サークル管理（新API）
@@ -44,12 +57,63 @@ func setupRouter() *gin.Engine {
 			liff.POST("/circles", handleCreateCircle)
 			liff.POST("/circles/join", handleJoinCircle)
 			liff.GET("/circles/search", handleSearchCircles)
+			liff.PUT("/circles/:id", handleUpdateCircle)
+			liff.DELETE("/circles/:id", handleDeleteCircle)
+			liff.PUT("/circles/:id/icon", handleUploadCircleIcon)
+			liff.DELETE("/circles/:id/icon", handleDeleteCircleIcon)
 			liff.GET("/circles/:id/members", handleGetCircleMembersByID)
 			liff.POST("/circles/:id/leave", handleLeaveCircle)
 			liff.POST("/circles/:id/remove", handleRemoveFromCircle)
+			liff.POST("/circles/:id/members/status", handleUpdateMembershipStatus)
+			liff.PUT("/circles/:id/members/:userId/display-name", handleUpdateCircleDisplayName)
 			liff.POST("/circles/:id/primary", handleSetPrimaryCircle)
+			liff.POST("/circles/:id/roles", handleUpdateCircleRole)
+			liff.GET("/circles/:id/settings", handleGetCircleSettings)
+			liff.PUT("/circles/:id/settings", handleUpdateCircleSettings)
+
+			// サークル招待
+			liff.GET("/circles/:id/invites", handleGetCircleInvites)
+			liff.POST("/circles/:id/invites", handleCreateCircleInvite)
+			liff.DELETE("/circles/:id/invites/:inviteId", handleRevokeCircleInvite)
+			liff.GET("/invites/:code", handleGetInvitePreview)
+			liff.POST("/invites/redeem", handleRedeemCircleInvite)
+
+			// 会費
+			liff.GET("/circles/:id/dues", handleGetCircleDues)
+			liff.POST("/circles/:id/dues", handleCreateCircleDues)
+			liff.PUT("/circles/:id/dues/:duesId", handleUpdateCircleDues)
+			liff.DELETE("/circles/:id/dues/:duesId", handleStopCircleDues)
+
+			// 出納帳
+			liff.GET("/circles/:id/ledger", handleGetCircleLedger)
+			liff.POST("/circles/:id/ledger", handleCreateLedgerEntry)
+			liff.DELETE("/circles/:id/ledger/:entryId", handleDeleteLedgerEntry)
+			liff.GET("/circles/:id/ledger/:entryId/receipt", handleGetLedgerReceipt)
+			liff.PUT("/circles/:id/ledger/:entryId/receipt", handleUploadLedgerReceipt)
+
+			// 会計の引き継ぎ
+			liff.POST("/circles/:id/handover", handleHandoverTreasurer)
+
+			// ゲスト
+			liff.GET("/circles/:id/guests", handleGetCircleGuests)
+			liff.POST("/circles/:id/guests", handleCreateCircleGuest)
+			liff.POST("/circles/:id/guests/:guestId/merge", handleMergeCircleGuest)
+
+			// 監査ログ
+			liff.GET("/circles/:id/audit-logs", handleGetCircleAuditLogs)
+
+			// 非公開サークルの参加申請
+			liff.GET("/circles/:id/join-requests", handleGetCircleJoinRequests)
+			liff.POST("/circles/:id/join-requests/:requestId/approve", handleApproveCircleJoinRequest)
+			liff.POST("/circles/:id/join-requests/:requestId/deny", handleDenyCircleJoinRequest)
 		}
 
+		// 招待QRコード - 掲示板に貼れるよう認証不要
+		api.GET("/invites/:code/qr", handleGetInviteQRCode)
+
+		// サークルアイコン - imgタグから読み込むため認証不要
+		api.GET("/circles/:id/icon", handleGetCircleIcon)
+
 		// Admin endpoints - APIキー認証が必要
 		admin := api.Group("/admin")
 		admin.Use(AdminAuthMiddleware())
@@ -58,6 +122,7 @@ func setupRouter() *gin.Engine {
 			admin.GET("/messages", handleAllMessages)
 			admin.POST("/send", handleSend)
 			admin.POST("/test/send-reminders", handleTestReminder)
+			admin.GET("/metrics", handleGetMetrics)
 
 			// リッチメニュー管理
 			admin.GET("/richmenu", handleRichMenuList)
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// ========== 割り勘イベント作成（LIFF・Bot共通） ==========
+
+// findNonMemberParticipants はサークルの在籍メンバーでない参加者IDを返す
+func findNonMemberParticipants(circleID int, participantIDs []string) ([]string, error) {
+	var nonMembers []string
+	for _, participantID := range participantIDs {
+		isMember, err := IsCircleMember(participantID, circleID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check membership: %w", err)
+		}
+		if !isMember {
+			nonMembers = append(nonMembers, participantID)
+		}
+	}
+	return nonMembers, nil
+}
+
+// uniqueStrings は順序を保ったまま重複を取り除く
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+// createSplitEvent は割り勘イベントを作成し、参加者を登録して通知する
+// ゲストも人数に含めて割り、支払いは会計者が記録するため通知しない
+// 戻り値はイベントIDと1人あたりの金額
+func createSplitEvent(organizer *User, in *SplitEventInput) (int, int, error) {
+	headcount := len(in.ParticipantIDs) + len(in.Guests) + len(in.NewGuests)
+	if headcount == 0 {
+		return 0, 0, fmt.Errorf("no participants")
+	}
+
+	splitAmount := in.TotalAmount / headcount
+
+	in.OrganizerID = organizer.UserID
+	eventID, participantIDs, err := CreateSplitEvent(in, splitAmount)
+	if err != nil {
+		return 0, 0, err
+	}
+	eventName := in.EventName
+
+	// 参加者に通知を送信（非同期）
+	go func() {
+		for _, participantID := range participantIDs {
+			notifyText := fmt.Sprintf("【割り勘のお知らせ】\n%sさんが割り勘イベントを作成しました。\n\nイベント: %s\nあなたの支払額: %d円\n支払先: %s\n\n支払いが完了したら「支払いました」と送信してください。",
+				organizer.Name, eventName, splitAmount, organizer.Name)
+
+			if err := PushMessage(participantID, notifyText); err != nil {
+				log.Printf("通知エラー (%s): %v", participantID, err)
+			} else {
+				log.Printf("通知成功: %s", participantID)
+			}
+		}
+	}()
+
+	log.Printf("イベント作成成功: %s (ID: %d)", eventName, eventID)
+	return eventID, splitAmount, nil
+}
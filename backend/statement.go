@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ========== 月次明細の送信 ==========
+
+const (
+	// statementMonthLayout は明細の対象月の形式（YYYY-MM）
+	statementMonthLayout = "2006-01"
+
+	// statementInterval は月次明細の送信確認間隔
+	statementInterval = 1 * time.Hour
+
+	// statementSendHour は毎月1日に明細を送り始める時刻
+	statementSendHour = 9
+
+	// statementSendWindow は明細を送る期間（停止していた場合もこの期間内なら送る）
+	statementSendWindow = 7 * 24 * time.Hour
+
+	// maxStatementCounterparties はBotの明細に表示する相手の最大数（それ以上はLIFFで確認する）
+	maxStatementCounterparties = 10
+)
+
+// parseStatementMonth はYYYY-MM形式の対象月を検証し、正規化した文字列を返す
+// 月の範囲はDBのタイムゾーンで求めるため、ここでは形式の確認のみ行う
+func parseStatementMonth(value string) (string, error) {
+	t, err := time.Parse(statementMonthLayout, value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(statementMonthLayout), nil
+}
+
+// formatStatementMonth はYYYY-MM形式の対象月を「YYYY年M月」にする
+func formatStatementMonth(month string) string {
+	t, err := time.Parse(statementMonthLayout, month)
+	if err != nil {
+		return month
+	}
+	return fmt.Sprintf("%d年%d月", t.Year(), t.Month())
+}
+
+// statementURL は月次明細のLIFFディープリンクを返す
+func statementURL(month string) string {
+	return strings.TrimRight(os.Getenv("LIFF_URL"), "/") + "/statements?month=" + url.QueryEscape(month)
+}
+
+// paymentHistoryURL は支払い履歴のLIFFのURLを返す
+func paymentHistoryURL() string {
+	return strings.TrimRight(os.Getenv("LIFF_URL"), "/") + "/history"
+}
+
+// formatMonthlyStatement は月次明細をBotのメッセージにする
+func formatMonthlyStatement(s *MonthlyStatement) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧾 %sの支払い明細\n", formatStatementMonth(s.Month))
+
+	sections := []struct {
+		title   string
+		suffix  string
+		total   int
+		parties []StatementCounterparty
+	}{
+		{"支払った分", "さんへ", s.PaidTotal, s.Paid},
+		{"受け取った分", "さんから", s.ReceivedTotal, s.Received},
+	}
+	for _, sec := range sections {
+		if len(sec.parties) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n【%s】合計 %s円\n", sec.title, formatAmount(sec.total))
+		for i, p := range sec.parties {
+			if i == maxStatementCounterparties {
+				fmt.Fprintf(&b, "・ほか%d人\n", len(sec.parties)-i)
+				break
+			}
+			fmt.Fprintf(&b, "・%s%s: %s円（%d件）\n", p.Name, sec.suffix, formatAmount(p.Total), len(p.Entries))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n内訳: %s", statementURL(s.Month))
+	return b.String()
+}
+
+var (
+	// statementSending は明細を送信中かどうか（送信に時間がかかっても次の確認と重ならないようにする）
+	statementSending atomic.Bool
+
+	// statementSentMonth は全員への送信を終えた対象月（送信期間中に送信先を毎時取得し直さない）
+	// statementSendingで送信が重ならないため、送信中のgoroutineからのみ読み書きする
+	statementSentMonth string
+)
+
+// sendMonthlyStatements は前月の明細の送信をスケジューラーとは別のgoroutineで始める
+// 前回の送信が終わっていない場合は何もしない
+func sendMonthlyStatements() {
+	if !statementSending.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer statementSending.Store(false)
+		sendPendingStatements()
+	}()
+}
+
+// sendPendingStatements は前月に支払い・受け取りがあったユーザーに前月の明細を送る
+// 毎月1日のstatementSendHour時から、statementSendWindowの間だけ送る（いずれもDBのタイムゾーン）
+func sendPendingStatements() {
+	month, elapsed, err := GetStatementSendMonth()
+	if err != nil {
+		log.Printf("[月次明細] 対象月の取得エラー: %v", err)
+		return
+	}
+	sendFrom := statementSendHour * time.Hour
+	if elapsed < sendFrom || elapsed > sendFrom+statementSendWindow || month == statementSentMonth {
+		return
+	}
+
+	userIDs, err := GetStatementRecipients(month)
+	if err != nil {
+		log.Printf("[月次明細] 送信先取得エラー: %v", err)
+		return
+	}
+
+	if len(userIDs) > 0 {
+		log.Printf("[月次明細] %s分の明細を%d人に送信します", month, len(userIDs))
+	}
+
+	failed := false
+	for _, userID := range userIDs {
+		claimed, err := ClaimStatementSend(userID, month)
+		if err != nil {
+			log.Printf("[月次明細] 送信記録エラー (UserID: %s): %v", userID, err)
+			failed = true
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		statement, err := GetMonthlyStatement(userID, month)
+		if err == nil {
+			err = PushMessage(userID, formatMonthlyStatement(statement))
+		}
+		if err != nil {
+			log.Printf("[月次明細] 送信失敗 (UserID: %s): %v", userID, err)
+			if err := ReleaseStatementSend(userID, month); err != nil {
+				log.Printf("[月次明細] 送信記録の削除エラー: %v", err)
+			}
+			failed = true
+			continue
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// 失敗した分は次の確認で再送する
+	if !failed {
+		statementSentMonth = month
+	}
+}
+
+// startStatementScheduler は月次明細の送信を起動する
+func startStatementScheduler() {
+	go func() {
+		log.Println("[月次明細] スケジューラーを起動しました")
+
+		ticker := time.NewTicker(statementInterval)
+		defer ticker.Stop()
+
+		sendMonthlyStatements()
+		for range ticker.C {
+			sendMonthlyStatements()
+		}
+	}()
+}
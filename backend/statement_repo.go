@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ========== 月次明細 ==========
+
+// 自分自身への支払い（会計者が参加者に含まれる場合）は支払い・受け取りのどちらにも数えない
+
+// 月の範囲はDBのタイムゾーンで求める（日時の列はDBのタイムゾーンでの時刻を保存しているため）
+
+// GetMonthlyPaymentTotals は今月を含む直近months か月の月ごとの請求額・支払額・受取額を新しい月から順に取得する
+func GetMonthlyPaymentTotals(userID string, months int) ([]MonthlyPaymentTotal, error) {
+	rows, err := db.Query(`
+		WITH bounds AS (
+			SELECT date_trunc('month', LOCALTIMESTAMP) - ($2 - 1) * INTERVAL '1 month' AS since
+		)
+		SELECT to_char(month, 'YYYY-MM'), SUM(billed), SUM(paid), SUM(received)
+		FROM (
+			SELECT date_trunc('month', e.created_at) AS month, e.split_amount AS billed, 0 AS paid, 0 AS received
+			FROM event_participants ep
+			JOIN events e ON ep.event_id = e.id
+			WHERE ep.user_id = $1 AND e.organizer_id <> ep.user_id AND e.created_at >= (SELECT since FROM bounds)
+			UNION ALL
+			SELECT date_trunc('month', ep.approved_at), 0, e.split_amount, 0
+			FROM event_participants ep
+			JOIN events e ON ep.event_id = e.id
+			WHERE ep.user_id = $1 AND e.organizer_id <> ep.user_id AND ep.approved_at >= (SELECT since FROM bounds)
+			UNION ALL
+			SELECT date_trunc('month', ep.approved_at), 0, 0, e.split_amount
+			FROM event_participants ep
+			JOIN events e ON ep.event_id = e.id
+			WHERE e.organizer_id = $1 AND ep.user_id <> $1 AND ep.approved_at >= (SELECT since FROM bounds)
+		) t
+		GROUP BY month
+		ORDER BY month DESC
+	`, userID, months)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []MonthlyPaymentTotal{}
+	for rows.Next() {
+		var t MonthlyPaymentTotal
+		if err := rows.Scan(&t.Month, &t.Billed, &t.Paid, &t.Received); err != nil {
+			log.Printf("月別集計スキャンエラー: %v", err)
+			continue
+		}
+		totals = append(totals, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// GetMonthlyStatement は対象月（YYYY-MM）に承認された支払いを、相手ごとにまとめて取得する
+// 支払先の名前はイベントのサークル内の表示名を優先する
+func GetMonthlyStatement(userID, month string) (*MonthlyStatement, error) {
+	rows, err := db.Query(`
+		SELECT 'paid', e.organizer_id, COALESCE(NULLIF(oc.display_name, ''), o.name, ''),
+		       e.id, e.event_name, COALESCE(c.name, ''), e.split_amount, ep.approved_at
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		LEFT JOIN circles c ON e.circle_id = c.id
+		LEFT JOIN users o ON e.organizer_id = o.user_id
+		LEFT JOIN user_circles oc ON e.organizer_id = oc.user_id AND oc.circle_id = e.circle_id
+		WHERE ep.user_id = $1 AND e.organizer_id <> ep.user_id
+		  AND ep.approved_at >= to_date($2, 'YYYY-MM') AND ep.approved_at < to_date($2, 'YYYY-MM') + INTERVAL '1 month'
+		UNION ALL
+		SELECT 'received', ep.user_id, COALESCE(NULLIF(pc.display_name, ''), ep.user_name),
+		       e.id, e.event_name, COALESCE(c.name, ''), e.split_amount, ep.approved_at
+		FROM event_participants ep
+		JOIN events e ON ep.event_id = e.id
+		LEFT JOIN circles c ON e.circle_id = c.id
+		LEFT JOIN user_circles pc ON ep.user_id = pc.user_id AND pc.circle_id = e.circle_id
+		WHERE e.organizer_id = $1 AND ep.user_id <> $1
+		  AND ep.approved_at >= to_date($2, 'YYYY-MM') AND ep.approved_at < to_date($2, 'YYYY-MM') + INTERVAL '1 month'
+		ORDER BY 8, 4
+	`, userID, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statement := &MonthlyStatement{
+		Month:    month,
+		Paid:     []StatementCounterparty{},
+		Received: []StatementCounterparty{},
+	}
+	// 相手ごとのインデックス（最初に支払いがあった順に並べる）
+	paidIndex := map[string]int{}
+	receivedIndex := map[string]int{}
+
+	for rows.Next() {
+		var direction, counterpartyID, counterpartyName string
+		var entry StatementEntry
+		if err := rows.Scan(&direction, &counterpartyID, &counterpartyName,
+			&entry.EventID, &entry.EventName, &entry.CircleName, &entry.Amount, &entry.ApprovedAt); err != nil {
+			log.Printf("月次明細スキャンエラー: %v", err)
+			continue
+		}
+
+		parties, index := &statement.Paid, paidIndex
+		if direction == "received" {
+			parties, index = &statement.Received, receivedIndex
+			statement.ReceivedTotal += entry.Amount
+		} else {
+			statement.PaidTotal += entry.Amount
+		}
+
+		i, ok := index[counterpartyID]
+		if !ok {
+			i = len(*parties)
+			index[counterpartyID] = i
+			*parties = append(*parties, StatementCounterparty{UserID: counterpartyID, Name: counterpartyName})
+		}
+		(*parties)[i].Total += entry.Amount
+		(*parties)[i].Entries = append((*parties)[i].Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return statement, nil
+}
+
+// GetStatementRecipients は対象月（YYYY-MM）に支払い・受け取りがあり、まだ明細を送っていないユーザーを取得する
+// ゲストはLINEに送れないため含めない
+func GetStatementRecipients(month string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT t.user_id
+		FROM (
+			SELECT ep.user_id, ep.guest_id
+			FROM event_participants ep
+			JOIN events e ON ep.event_id = e.id
+			WHERE ep.approved_at >= to_date($1, 'YYYY-MM') AND ep.approved_at < to_date($1, 'YYYY-MM') + INTERVAL '1 month'
+			  AND e.organizer_id <> ep.user_id
+			UNION ALL
+			SELECT e.organizer_id, NULL
+			FROM event_participants ep
+			JOIN events e ON ep.event_id = e.id
+			WHERE ep.approved_at >= to_date($1, 'YYYY-MM') AND ep.approved_at < to_date($1, 'YYYY-MM') + INTERVAL '1 month'
+			  AND e.organizer_id <> ep.user_id
+		) t
+		WHERE t.guest_id IS NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM monthly_statement_sends s WHERE s.user_id = t.user_id AND s.month = $1
+		  )
+	`, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			log.Printf("明細送信先スキャンエラー: %v", err)
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// GetStatementSendMonth はDBのタイムゾーンでの前月（YYYY-MM）と、今月1日0時からの経過時間を返す
+func GetStatementSendMonth() (string, time.Duration, error) {
+	var month string
+	var elapsedSeconds float64
+	err := db.QueryRow(`
+		SELECT to_char(date_trunc('month', LOCALTIMESTAMP) - INTERVAL '1 month', 'YYYY-MM'),
+		       EXTRACT(EPOCH FROM LOCALTIMESTAMP - date_trunc('month', LOCALTIMESTAMP))
+	`).Scan(&month, &elapsedSeconds)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get statement month: %w", err)
+	}
+	return month, time.Duration(elapsedSeconds * float64(time.Second)), nil
+}
+
+// ClaimStatementSend は明細の送信記録を作成する
+// 既に送信済み（他のインスタンスが送信中を含む）の場合はfalseを返す
+func ClaimStatementSend(userID, month string) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO monthly_statement_sends (user_id, month)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, month) DO NOTHING
+	`, userID, month)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim statement send: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// ReleaseStatementSend は送信に失敗した明細の送信記録を削除する（次回に再送される）
+func ReleaseStatementSend(userID, month string) error {
+	_, err := db.Exec(`
+		DELETE FROM monthly_statement_sends WHERE user_id = $1 AND month = $2
+	`, userID, month)
+	if err != nil {
+		return fmt.Errorf("failed to release statement send: %w", err)
+	}
+	return nil
+}
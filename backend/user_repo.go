@@ -12,11 +12,9 @@ func GetUser(userID string) (*User, error) {
 	var user User
 	var primaryCircleID sql.NullInt64
 	err := db.QueryRow(`
-		SELECT user_id, name, COALESCE(circle, ''), primary_circle_id, step, split_event_step,
-		       COALESCE(temp_event_id, 0), approval_step, COALESCE(approval_event_id, 0)
+		SELECT user_id, name, primary_circle_id, step
 		FROM users WHERE user_id = $1
-	`, userID).Scan(&user.UserID, &user.Name, &user.Circle, &primaryCircleID, &user.Step,
-		&user.SplitEventStep, &user.TempEventID, &user.ApprovalStep, &user.ApprovalEventID)
+	`, userID).Scan(&user.UserID, &user.Name, &primaryCircleID, &user.Step)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -36,9 +34,9 @@ func GetUser(userID string) (*User, error) {
 // SaveUser はユーザーを保存する
 func SaveUser(user *User) error {
 	_, err := db.Exec(`
-		INSERT INTO users (user_id, name, circle, primary_circle_id, step, split_event_step, temp_event_id, approval_step, approval_event_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, user.UserID, user.Name, user.Circle, user.PrimaryCircleID, user.Step, user.SplitEventStep, user.TempEventID, user.ApprovalStep, user.ApprovalEventID)
+		INSERT INTO users (user_id, name, primary_circle_id, step)
+		VALUES ($1, $2, $3, $4)
+	`, user.UserID, user.Name, user.PrimaryCircleID, user.Step)
 	return err
 }
 
@@ -46,18 +44,16 @@ func SaveUser(user *User) error {
 func UpdateUser(user *User) error {
 	_, err := db.Exec(`
 		UPDATE users
-		SET name = $1, circle = $2, primary_circle_id = $3, step = $4, split_event_step = $5,
-		    temp_event_id = $6, approval_step = $7, approval_event_id = $8, updated_at = NOW()
-		WHERE user_id = $9
-	`, user.Name, user.Circle, user.PrimaryCircleID, user.Step, user.SplitEventStep, user.TempEventID,
-		user.ApprovalStep, user.ApprovalEventID, user.UserID)
+		SET name = $1, primary_circle_id = $2, step = $3, updated_at = NOW()
+		WHERE user_id = $4
+	`, user.Name, user.PrimaryCircleID, user.Step, user.UserID)
 	return err
 }
 
 // GetAllUsers は全ユーザーを取得する
 func GetAllUsers() ([]User, error) {
 	rows, err := db.Query(`
-		SELECT user_id, name, circle, step
+		SELECT user_id, name, primary_circle_id, step
 		FROM users
 		ORDER BY updated_at DESC
 	`)
@@ -69,39 +65,14 @@ func GetAllUsers() ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var user User
-		if err := rows.Scan(&user.UserID, &user.Name, &user.Circle, &user.Step); err != nil {
+		var primaryCircleID sql.NullInt64
+		if err := rows.Scan(&user.UserID, &user.Name, &primaryCircleID, &user.Step); err != nil {
 			log.Printf("ユーザースキャンエラー: %v", err)
 			continue
 		}
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return users, nil
-}
-
-// GetUsersByCircle は同じサークルのユーザーを取得する
-func GetUsersByCircle(circle string, excludeUserID string) ([]User, error) {
-	rows, err := db.Query(`
-		SELECT user_id, name, circle
-		FROM users
-		WHERE circle = $1 AND step = 3 AND user_id != $2
-		ORDER BY name
-	`, circle, excludeUserID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.UserID, &user.Name, &user.Circle); err != nil {
-			log.Printf("ユーザースキャンエラー: %v", err)
-			continue
+		if primaryCircleID.Valid {
+			id := int(primaryCircleID.Int64)
+			user.PrimaryCircleID = &id
 		}
 		users = append(users, user)
 	}
@@ -3,7 +3,11 @@ package main
 import (
 	"fmt"
 	"html"
+	"log"
+	"os"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // ========== ユーティリティ関数 ==========
@@ -19,3 +23,41 @@ func sanitizeInput(input string) string {
 func formatAmount(amount int) string {
 	return fmt.Sprintf("%d", amount)
 }
+
+// truncateText は文字列を指定文字数に収める（超える場合は末尾を…にする）
+func truncateText(text string, maxLen int) string {
+	if utf8.RuneCountInString(text) <= maxLen {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxLen-1]) + "…"
+}
+
+// getEnvInt は整数の環境変数を取得する（未設定・不正値の場合はデフォルト値）
+func getEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: %s is not a valid integer (%q), using %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// uniqueInts は順序を保ったまま重複を取り除く（空の場合も空スライスを返す）
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	result := []int{}
+	for _, v := range values {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
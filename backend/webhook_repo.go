@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ========== Webhookイベントリポジトリ ==========
+
+// ClaimWebhookEvent はWebhookイベントを処理中として記録する
+// 処理済み、または他のワーカーが処理中（staleAfterを過ぎていないもの）の場合はfalseを返す
+// 処理中のままstaleAfterを過ぎたもの（処理中にプロセスが停止したなど）は取り直せる
+func ClaimWebhookEvent(webhookEventID string, isRedelivery bool, staleAfter time.Duration) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO webhook_events (webhook_event_id, is_redelivery, completed)
+		VALUES ($1, $2, FALSE)
+		ON CONFLICT (webhook_event_id) DO UPDATE
+		SET is_redelivery = EXCLUDED.is_redelivery, processed_at = CURRENT_TIMESTAMP
+		WHERE NOT webhook_events.completed
+		  AND webhook_events.processed_at < NOW() - ($3 * INTERVAL '1 second')
+	`, webhookEventID, isRedelivery, int64(staleAfter.Seconds()))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// CompleteWebhookEvent は処理中のWebhookイベントを処理済みにする
+func CompleteWebhookEvent(webhookEventID string) error {
+	_, err := db.Exec(`
+		UPDATE webhook_events SET completed = TRUE, processed_at = CURRENT_TIMESTAMP
+		WHERE webhook_event_id = $1
+	`, webhookEventID)
+	if err != nil {
+		return fmt.Errorf("failed to complete webhook event: %w", err)
+	}
+	return nil
+}
+
+// ReleaseWebhookEvent は処理に失敗したWebhookイベントの記録を削除する（再送時に処理し直せる）
+func ReleaseWebhookEvent(webhookEventID string) error {
+	_, err := db.Exec(`
+		DELETE FROM webhook_events WHERE webhook_event_id = $1 AND NOT completed
+	`, webhookEventID)
+	if err != nil {
+		return fmt.Errorf("failed to release webhook event: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredWebhookEvents は保持期間を過ぎた処理済みイベントIDを削除する
+func DeleteExpiredWebhookEvents(ttl time.Duration) (int64, error) {
+	result, err := db.Exec(`
+		DELETE FROM webhook_events
+		WHERE processed_at < NOW() - ($1 * INTERVAL '1 second')
+	`, int64(ttl.Seconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired webhook events: %w", err)
+	}
+	return result.RowsAffected()
+}
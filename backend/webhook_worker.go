@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+)
+
+// ========== Webhook非同期処理 ==========
+
+const (
+	defaultWebhookWorkers   = 4
+	defaultWebhookQueueSize = 100
+)
+
+// webhookDispatcher はWebhookイベントを処理するワーカープール
+var webhookDispatcher *WebhookDispatcher
+
+// WebhookDispatcher はWebhookイベントをワーカーに振り分けて非同期に処理する
+// 同じユーザーのイベントは常に同じワーカーに渡すため、ユーザー単位の順序が保たれる
+type WebhookDispatcher struct {
+	queues []chan WebhookEvent
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewWebhookDispatcher はワーカープールを作成して起動する
+// queueSizeはワーカー1つあたりのキュー長
+func NewWebhookDispatcher(workers, queueSize int) *WebhookDispatcher {
+	if workers <= 0 {
+		workers = defaultWebhookWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultWebhookQueueSize
+	}
+
+	d := &WebhookDispatcher{
+		queues: make([]chan WebhookEvent, workers),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan WebhookEvent, queueSize)
+		d.wg.Add(1)
+		go d.runWorker(d.queues[i])
+	}
+
+	log.Printf("[Webhookワーカー] 起動しました (ワーカー数: %d, キュー長: %d)", workers, queueSize)
+	return d
+}
+
+// Enqueue はイベントをキューに投入する
+// キューが満杯、または停止処理中の場合はブロックせずにfalseを返す
+func (d *WebhookDispatcher) Enqueue(event WebhookEvent) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		metrics.WebhookEventsRejected.Add(1)
+		return false
+	}
+
+	select {
+	case d.queueFor(event.Source.UserID) <- event:
+		metrics.WebhookEventsEnqueued.Add(1)
+		return true
+	default:
+		metrics.WebhookEventsRejected.Add(1)
+		return false
+	}
+}
+
+// QueueDepth はキューに溜まっている未処理イベント数を取得する
+func (d *WebhookDispatcher) QueueDepth() int {
+	depth := 0
+	for _, q := range d.queues {
+		depth += len(q)
+	}
+	return depth
+}
+
+// QueueCapacity はキュー全体の容量を取得する
+func (d *WebhookDispatcher) QueueCapacity() int {
+	capacity := 0
+	for _, q := range d.queues {
+		capacity += cap(q)
+	}
+	return capacity
+}
+
+// Shutdown は新規受付を止め、キューに残ったイベントを処理し終えるまで待つ
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, q := range d.queues {
+			close(q)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("[Webhookワーカー] すべてのイベントを処理して停止しました")
+		return nil
+	case <-ctx.Done():
+		log.Printf("[Webhookワーカー] 停止待ちがタイムアウトしました (未処理: %d件)", d.QueueDepth())
+		return ctx.Err()
+	}
+}
+
+// queueFor はユーザーIDから担当ワーカーのキューを決める
+func (d *WebhookDispatcher) queueFor(userID string) chan WebhookEvent {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return d.queues[h.Sum32()%uint32(len(d.queues))]
+}
+
+// runWorker はキューからイベントを取り出して順番に処理する
+func (d *WebhookDispatcher) runWorker(queue chan WebhookEvent) {
+	defer d.wg.Done()
+	for event := range queue {
+		d.process(event)
+	}
+}
+
+// process は1件のイベントを処理する（panicしてもワーカーは止めない）
+func (d *WebhookDispatcher) process(event WebhookEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.WebhookEventsPanicked.Add(1)
+			log.Printf("[Webhookワーカー] 処理中にpanic: %v (webhookEventId=%s)", r, event.WebhookEventID)
+		}
+	}()
+
+	processWebhookEvent(event)
+	metrics.WebhookEventsProcessed.Add(1)
+}
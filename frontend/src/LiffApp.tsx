@@ -4,7 +4,10 @@ import type { ErrorInfo, ReactNode } from 'react';
 import CreateEvent from './pages/CreateEvent';
 import ApprovePage from './pages/ApprovePage';
 import EventsPage from './pages/EventsPage';
+import EventDetailPage from './pages/EventDetailPage';
 import CirclesPage from './pages/CirclesPage';
+import HistoryPage from './pages/HistoryPage';
+import StatementsPage from './pages/StatementsPage';
 
 // エラー境界コンポーネント
 interface ErrorBoundaryState {
@@ -53,9 +56,12 @@ export default function LiffApp() {
         <Routes>
           <Route path="/" element={<Navigate to="/events" replace />} />
           <Route path="/events" element={<EventsPage />} />
+          <Route path="/events/:eventId" element={<EventDetailPage />} />
           <Route path="/create" element={<CreateEvent />} />
           <Route path="/approve" element={<ApprovePage />} />
           <Route path="/circles" element={<CirclesPage />} />
+          <Route path="/history" element={<HistoryPage />} />
+          <Route path="/statements" element={<StatementsPage />} />
         </Routes>
       </BrowserRouter>
     </ErrorBoundary>
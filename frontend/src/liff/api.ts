@@ -40,6 +40,23 @@ async function apiCall<T>(endpoint: string, options: ApiOptions): Promise<T> {
   }
 }
 
+// 認証が必要な画像を取得し、imgタグで表示できるオブジェクトURLを返す（不要になったらrevokeObjectURLで解放する）
+async function fetchImageUrl(endpoint: string, accessToken: string): Promise<string> {
+  const response = await fetch(`${API_BASE}${endpoint}`, {
+    headers: {
+      'Authorization': `Bearer ${accessToken}`,
+      'ngrok-skip-browser-warning': 'true',
+    },
+  });
+
+  if (!response.ok) {
+    const errorText = await response.text();
+    throw new Error(`API Error: ${response.status} - ${errorText}`);
+  }
+
+  return URL.createObjectURL(await response.blob());
+}
+
 // ========== ユーザー関連 ==========
 
 export interface User {
@@ -70,6 +87,86 @@ export async function getCircleMembers(accessToken: string) {
   );
 }
 
+// ========== 支払い履歴・明細 ==========
+
+export interface MyPayment {
+  participantId: number;
+  eventId: number;
+  eventName: string;
+  circleId: number | null;
+  circleName: string;
+  organizerId: string;
+  organizerName: string;
+  amount: number;
+  state: 'unpaid' | 'reported' | 'approved';
+  deadline?: string;
+  reportedAt?: string;
+  approvedAt?: string;
+  hasProof: boolean;
+  createdAt: string;
+}
+
+// 自分の支払い履歴を新しい順に取得（続きはnextBeforeIdをbeforeIdに指定する）
+export async function getMyPaymentHistory(accessToken: string, beforeId?: number, limit?: number): Promise<{
+  status: string;
+  payments: MyPayment[];
+  nextBeforeId: number | null;
+}> {
+  const params = new URLSearchParams();
+  if (beforeId) params.set('before', String(beforeId));
+  if (limit) params.set('limit', String(limit));
+  const query = params.toString();
+  return apiCall(`/api/liff/me/payments/history${query ? `?${query}` : ''}`, { accessToken });
+}
+
+export interface MonthlyPaymentTotal {
+  month: string; // YYYY-MM
+  billed: number;
+  paid: number;
+  received: number;
+}
+
+// 自分の月ごとの請求額・支払額・受取額を新しい月から取得（支払いのない月は含まない）
+export async function getMyMonthlyTotals(accessToken: string, months?: number): Promise<{
+  status: string;
+  months: MonthlyPaymentTotal[];
+}> {
+  const params = months ? `?months=${months}` : '';
+  return apiCall(`/api/liff/me/payments/monthly${params}`, { accessToken });
+}
+
+export interface StatementEntry {
+  eventId: number;
+  eventName: string;
+  circleName: string;
+  amount: number;
+  approvedAt: string;
+}
+
+export interface StatementCounterparty {
+  userId: string;
+  name: string;
+  total: number;
+  entries: StatementEntry[];
+}
+
+export interface MonthlyStatement {
+  month: string; // YYYY-MM
+  paid: StatementCounterparty[];
+  received: StatementCounterparty[];
+  paidTotal: number;
+  receivedTotal: number;
+}
+
+// 指定した月（省略時は今月）の明細を相手ごとに取得
+export async function getMyStatement(accessToken: string, month?: string): Promise<{
+  status: string;
+  statement: MonthlyStatement;
+}> {
+  const params = month ? `?month=${encodeURIComponent(month)}` : '';
+  return apiCall(`/api/liff/me/statements${params}`, { accessToken });
+}
+
 // ========== イベント関連 ==========
 
 export interface Event {
@@ -89,6 +186,7 @@ export interface CreateEventRequest {
   eventName: string;
   totalAmount: number;
   participantIds: string[];
+  circleId?: number;
 }
 
 export async function createEvent(accessToken: string, data: CreateEventRequest) {
@@ -99,6 +197,82 @@ export async function createEvent(accessToken: string, data: CreateEventRequest)
   });
 }
 
+export interface EventItem {
+  name: string;
+  amount: number;
+}
+
+// 参加者の表示内容（会計者と本人のみparticipantId以降の詳細が返る）
+export interface EventParticipant {
+  name: string;
+  isGuest: boolean;
+  isMe: boolean;
+  settled: boolean;
+  participantId?: number;
+  userId?: string;
+  state?: 'unpaid' | 'reported' | 'approved';
+  reportedAt?: string | null;
+  approvedAt?: string | null;
+  hasProof?: boolean;
+}
+
+export interface EventReceipt {
+  id: number;
+  eventId: number;
+  note: string;
+  imageUrl?: string;
+  createdBy: string;
+  createdAt: string;
+}
+
+export interface EventHistoryEntry {
+  type: 'created' | 'reported' | 'approved' | 'rejected' | 'receipt_added';
+  userId?: string;
+  userName?: string;
+  at: string;
+}
+
+export interface EventDetail {
+  id: number;
+  name: string;
+  organizerId: string;
+  organizerName: string;
+  circleId: number | null;
+  totalAmount: number;
+  splitAmount: number;
+  status: string;
+  deadline: string | null;
+  createdAt: string;
+  isOrganizer: boolean;
+}
+
+// 集計（会計者のみunpaidCount以降が返る）
+export interface EventSummary {
+  participantCount: number;
+  settledCount: number;
+  unpaidCount?: number;
+  reportedCount?: number;
+  collectedAmount?: number;
+}
+
+// イベントの詳細（内訳・参加者・レシート・履歴）を取得（会計者・参加者のみ）
+export async function getEventDetail(accessToken: string, eventId: number): Promise<{
+  status: string;
+  event: EventDetail;
+  items: EventItem[];
+  participants: EventParticipant[];
+  summary: EventSummary;
+  receipts: EventReceipt[];
+  history: EventHistoryEntry[];
+}> {
+  return apiCall(`/api/liff/events/${eventId}`, { accessToken });
+}
+
+// レシート画像を取得（receipt.imageUrlを渡す）
+export async function getEventReceiptImage(accessToken: string, imageUrl: string): Promise<string> {
+  return fetchImageUrl(imageUrl, accessToken);
+}
+
 // ========== 承認関連 ==========
 
 export interface Approval {
@@ -115,7 +289,14 @@ export async function getPendingApprovals(accessToken: string): Promise<{ status
   return apiCall('/api/liff/approvals', { accessToken });
 }
 
-export async function approvePayments(accessToken: string, participantIds: number[]) {
+// 支払いを承認（承認待ちでないものはskippedIdsで返る。1件でも失敗した場合は500、1件も承認できない場合は409）
+export async function approvePayments(accessToken: string, participantIds: number[]): Promise<{
+  status: string;
+  message: string;
+  approvedIds: number[];
+  skippedIds: number[];
+  failedIds: number[];
+}> {
   return apiCall('/api/liff/approvals', {
     method: 'POST',
     body: { participantIds },
@@ -129,6 +310,8 @@ export interface Circle {
   id: number;
   name: string;
   createdBy: string;
+  description: string;
+  iconUrl?: string;
   createdAt: string;
 }
 
@@ -224,3 +407,16 @@ export async function setPrimaryCircle(accessToken: string, circleId: number): P
     accessToken,
   });
 }
+
+// 招待コードでサークルに参加
+export async function redeemInvite(accessToken: string, code: string): Promise<{
+  status: string;
+  message: string;
+  circle: Circle;
+}> {
+  return apiCall('/api/liff/invites/redeem', {
+    method: 'POST',
+    body: { code },
+    accessToken,
+  });
+}
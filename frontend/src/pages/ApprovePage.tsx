@@ -81,6 +81,10 @@ export default function ApprovePage() {
     } catch (error) {
       console.error('承認エラー:', error);
       setError(error instanceof Error ? error.message : '承認に失敗しました');
+
+      // 一部だけ承認できた場合もあるため、一覧を取得し直す
+      await loadApprovals();
+      setSelectedApprovals(new Set());
     } finally {
       setIsSubmitting(false);
     }
@@ -7,6 +7,7 @@ import {
   searchCircles,
   leaveCircle,
   setPrimaryCircle,
+  redeemInvite,
   Circle
 } from '../liff/api';
 
@@ -27,10 +28,30 @@ export default function CirclesPage() {
 
   useEffect(() => {
     if (isLoggedIn && accessToken) {
-      loadCircles();
+      handleInviteLink().then(loadCircles);
     }
   }, [isLoggedIn, accessToken]);
 
+  // 招待リンク（?invite=コード）から開かれた場合は参加処理を行う
+  const handleInviteLink = async () => {
+    if (!accessToken) return;
+    const params = new URLSearchParams(window.location.search);
+    const code = params.get('invite');
+    if (!code) return;
+
+    // 再読み込みで二重に参加しないようURLから除去
+    params.delete('invite');
+    const query = params.toString();
+    window.history.replaceState(null, '', window.location.pathname + (query ? `?${query}` : ''));
+
+    try {
+      const response = await redeemInvite(accessToken, code);
+      alert(`「${response.circle.name}」に参加しました`);
+    } catch (err: any) {
+      alert(err.message || '招待コードでの参加に失敗しました');
+    }
+  };
+
   const loadCircles = async () => {
     if (!accessToken) return;
     setLoading(true);
@@ -75,7 +96,10 @@ export default function CirclesPage() {
   const handleJoinCircle = async (circleId: number) => {
     if (!accessToken) return;
     try {
-      await joinCircle(accessToken, undefined, circleId);
+      const response = await joinCircle(accessToken, undefined, circleId);
+      if (response.status === 'pending') {
+        alert(response.message);
+      }
       setShowJoinModal(false);
       setSearchQuery('');
       setSearchResults([]);
@@ -1,11 +1,13 @@
 import { useState, useEffect } from 'react';
 import { useLiff } from '../liff/useLiff';
-import { createEvent, getCircleMembers } from '../liff/api';
+import { createEvent, getMyCircles, getCircleMembersByCircleId, Circle } from '../liff/api';
 
 export default function CreateEvent() {
   const { isLoggedIn, isLoading, error: liffError, accessToken, displayName, closeWindow } = useLiff();
   const [eventName, setEventName] = useState('');
   const [totalAmount, setTotalAmount] = useState('');
+  const [circles, setCircles] = useState<Circle[]>([]);
+  const [circleId, setCircleId] = useState<number | null>(null);
   const [members, setMembers] = useState<Array<{ userId: string; name: string }>>([]);
   const [selectedMembers, setSelectedMembers] = useState<Set<string>>(new Set());
   const [isSubmitting, setIsSubmitting] = useState(false);
@@ -13,16 +15,38 @@ export default function CreateEvent() {
 
   useEffect(() => {
     if (isLoggedIn && accessToken) {
-      loadMembers();
+      loadCircles();
     }
   }, [isLoggedIn, accessToken]);
 
-  const loadMembers = async () => {
+  useEffect(() => {
+    if (circleId !== null) {
+      loadMembers(circleId);
+    }
+  }, [circleId]);
+
+  const loadCircles = async () => {
+    if (!accessToken) return;
+
+    try {
+      const response = await getMyCircles(accessToken);
+      const myCircles = response?.circles || [];
+      setCircles(myCircles);
+      // 主サークルを初期選択
+      setCircleId(response?.primaryCircleId ?? myCircles[0]?.id ?? null);
+    } catch (error) {
+      console.error('サークル取得エラー:', error);
+      setError('サークル一覧の取得に失敗しました');
+    }
+  };
+
+  const loadMembers = async (targetCircleId: number) => {
     if (!accessToken) return;
 
     try {
-      const response = await getCircleMembers(accessToken);
+      const response = await getCircleMembersByCircleId(accessToken, targetCircleId, true);
       setMembers(response?.members || []);
+      setSelectedMembers(new Set());
     } catch (error) {
       console.error('メンバー取得エラー:', error);
       setError('メンバー一覧の取得に失敗しました');
@@ -58,6 +82,11 @@ export default function CreateEvent() {
       return;
     }
 
+    if (circleId === null) {
+      setError('サークルを選択してください');
+      return;
+    }
+
     if (selectedMembers.size === 0) {
       setError('参加者を選択してください');
       return;
@@ -71,6 +100,7 @@ export default function CreateEvent() {
         eventName: eventName.trim(),
         totalAmount: amount,
         participantIds: Array.from(selectedMembers),
+        circleId,
       });
 
       alert('イベントを作成しました！\n参加者に通知を送信しました。');
@@ -134,6 +164,22 @@ export default function CreateEvent() {
       </div>
 
       <form onSubmit={handleSubmit} style={styles.form}>
+        <div style={styles.formGroup}>
+          <label style={styles.label}>サークル</label>
+          <select
+            value={circleId ?? ''}
+            onChange={(e) => setCircleId(Number(e.target.value))}
+            style={styles.input}
+            required
+          >
+            {circles.map((circle) => (
+              <option key={circle.id} value={circle.id}>
+                {circle.name}
+              </option>
+            ))}
+          </select>
+        </div>
+
         <div style={styles.formGroup}>
           <label style={styles.label}>イベント名</label>
           <input
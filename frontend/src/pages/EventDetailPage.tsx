@@ -0,0 +1,393 @@
+import { useState, useEffect } from 'react';
+import { useNavigate, useParams } from 'react-router-dom';
+import { useLiff } from '../liff/useLiff';
+import {
+  getEventDetail,
+  getEventReceiptImage,
+  type EventDetail,
+  type EventItem,
+  type EventParticipant,
+  type EventSummary,
+  type EventReceipt,
+  type EventHistoryEntry,
+} from '../liff/api';
+
+const historyLabels: { [key in EventHistoryEntry['type']]: string } = {
+  created: 'イベントを作成',
+  receipt_added: 'レシートを添付',
+  reported: '支払いを報告',
+  approved: '支払いを承認',
+  rejected: '支払いを差し戻し',
+};
+
+// レシート画像（認証付きで取得してから表示する）
+function ReceiptImage({ accessToken, imageUrl }: { accessToken: string; imageUrl: string }) {
+  const [src, setSrc] = useState<string | null>(null);
+  const [failed, setFailed] = useState(false);
+
+  useEffect(() => {
+    let objectUrl: string | null = null;
+    let cancelled = false;
+    getEventReceiptImage(accessToken, imageUrl)
+      .then((url) => {
+        if (cancelled) {
+          URL.revokeObjectURL(url);
+          return;
+        }
+        objectUrl = url;
+        setSrc(url);
+      })
+      .catch((err) => {
+        console.error('レシート画像取得エラー:', err);
+        if (!cancelled) setFailed(true);
+      });
+    return () => {
+      cancelled = true;
+      if (objectUrl) URL.revokeObjectURL(objectUrl);
+    };
+  }, [accessToken, imageUrl]);
+
+  if (failed) return <div style={styles.receiptError}>画像を読み込めませんでした</div>;
+  if (!src) return <div style={styles.receiptLoading}>画像を読み込み中...</div>;
+  return (
+    <a href={src} target="_blank" rel="noreferrer">
+      <img src={src} alt="レシート" style={styles.receiptImage} />
+    </a>
+  );
+}
+
+export default function EventDetailPage() {
+  const navigate = useNavigate();
+  const { eventId } = useParams();
+  const { isLoggedIn, isLoading, accessToken } = useLiff();
+  const [event, setEvent] = useState<EventDetail | null>(null);
+  const [items, setItems] = useState<EventItem[]>([]);
+  const [participants, setParticipants] = useState<EventParticipant[]>([]);
+  const [summary, setSummary] = useState<EventSummary | null>(null);
+  const [receipts, setReceipts] = useState<EventReceipt[]>([]);
+  const [history, setHistory] = useState<EventHistoryEntry[]>([]);
+  const [isLoadingData, setIsLoadingData] = useState(true);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    if (isLoggedIn && accessToken) {
+      loadEvent();
+    }
+  }, [isLoggedIn, accessToken, eventId]);
+
+  const loadEvent = async () => {
+    if (!accessToken) return;
+
+    const id = Number(eventId);
+    if (!Number.isInteger(id) || id <= 0) {
+      setError('イベントが見つかりません');
+      setIsLoadingData(false);
+      return;
+    }
+
+    setIsLoadingData(true);
+    setError('');
+    try {
+      const response = await getEventDetail(accessToken, id);
+      setEvent(response.event);
+      setItems(response.items || []);
+      setParticipants(response.participants || []);
+      setSummary(response.summary);
+      setReceipts(response.receipts || []);
+      setHistory(response.history || []);
+    } catch (err) {
+      console.error('イベント詳細取得エラー:', err);
+      setError('イベントの取得に失敗しました');
+    } finally {
+      setIsLoadingData(false);
+    }
+  };
+
+  if (isLoading || isLoadingData) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.loading}>読み込み中...</div>
+      </div>
+    );
+  }
+
+  if (!isLoggedIn) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.error}>ログインが必要です</div>
+      </div>
+    );
+  }
+
+  if (!event) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.error}>{error || 'イベントが見つかりません'}</div>
+        <button onClick={() => navigate('/events')} style={styles.backButton}>
+          イベント一覧へ
+        </button>
+      </div>
+    );
+  }
+
+  const participantState = (p: EventParticipant) => {
+    if (p.settled) return { label: '精算済み', style: styles.stateApproved };
+    if (!p.state) return { label: '未精算', style: styles.stateUnpaid };
+    if (p.state === 'reported') return { label: '承認待ち', style: styles.stateReported };
+    return { label: '未払い', style: styles.stateUnpaid };
+  };
+
+  return (
+    <div style={styles.container}>
+      <button onClick={() => navigate('/events')} style={styles.backLink}>
+        ← イベント一覧
+      </button>
+
+      <h1 style={styles.title}>{event.name}</h1>
+
+      <div style={styles.section}>
+        <div style={styles.detailRow}>
+          <span style={styles.detailLabel}>会計:</span>
+          <span style={styles.detailValue}>{event.organizerName}</span>
+        </div>
+        <div style={styles.detailRow}>
+          <span style={styles.detailLabel}>合計金額:</span>
+          <span style={styles.detailValue}>{event.totalAmount.toLocaleString()}円</span>
+        </div>
+        <div style={styles.detailRow}>
+          <span style={styles.detailLabel}>1人あたり:</span>
+          <span style={styles.detailValue}>{event.splitAmount.toLocaleString()}円</span>
+        </div>
+        {event.deadline && (
+          <div style={styles.detailRow}>
+            <span style={styles.detailLabel}>支払い期限:</span>
+            <span style={styles.detailValue}>{event.deadline}</span>
+          </div>
+        )}
+        {summary && (
+          <div style={styles.detailRow}>
+            <span style={styles.detailLabel}>精算:</span>
+            <span style={styles.detailValue}>
+              {summary.settledCount} / {summary.participantCount}人
+              {summary.collectedAmount !== undefined && `（${summary.collectedAmount.toLocaleString()}円回収済み）`}
+            </span>
+          </div>
+        )}
+      </div>
+
+      {items.length > 0 && (
+        <div style={styles.section}>
+          <h2 style={styles.sectionTitle}>内訳</h2>
+          {items.map((item, i) => (
+            <div key={i} style={styles.detailRow}>
+              <span style={styles.detailLabel}>{item.name}</span>
+              <span style={styles.detailValue}>{item.amount.toLocaleString()}円</span>
+            </div>
+          ))}
+        </div>
+      )}
+
+      <div style={styles.section}>
+        <h2 style={styles.sectionTitle}>レシート</h2>
+        {receipts.length === 0 ? (
+          <p style={styles.emptyText}>レシートは添付されていません</p>
+        ) : (
+          receipts.map((receipt) => (
+            <div key={receipt.id} style={styles.receipt}>
+              {receipt.imageUrl && accessToken && (
+                <ReceiptImage accessToken={accessToken} imageUrl={receipt.imageUrl} />
+              )}
+              {receipt.note && <p style={styles.receiptNote}>{receipt.note}</p>}
+              <span style={styles.timestamp}>
+                {new Date(receipt.createdAt).toLocaleString('ja-JP')}
+              </span>
+            </div>
+          ))
+        )}
+      </div>
+
+      <div style={styles.section}>
+        <h2 style={styles.sectionTitle}>参加者</h2>
+        {participants.map((p, i) => {
+          const state = participantState(p);
+          return (
+            <div key={p.participantId ?? i} style={styles.participantRow}>
+              <span style={styles.participantName}>
+                {p.name}
+                {p.isGuest && <span style={styles.tag}>ゲスト</span>}
+                {p.isMe && <span style={styles.tag}>自分</span>}
+              </span>
+              <span style={{ ...styles.stateBadge, ...state.style }}>{state.label}</span>
+            </div>
+          );
+        })}
+      </div>
+
+      {history.length > 0 && (
+        <div style={styles.section}>
+          <h2 style={styles.sectionTitle}>履歴</h2>
+          {history.map((entry, i) => (
+            <div key={i} style={styles.historyRow}>
+              <span style={styles.timestamp}>{new Date(entry.at).toLocaleString('ja-JP')}</span>
+              <span>
+                {entry.userName ? `${entry.userName}: ` : ''}
+                {historyLabels[entry.type] || entry.type}
+              </span>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
+
+const styles: { [key: string]: React.CSSProperties } = {
+  container: {
+    maxWidth: '600px',
+    margin: '0 auto',
+    padding: '20px',
+    fontFamily: 'sans-serif',
+  },
+  loading: {
+    textAlign: 'center',
+    padding: '40px',
+    fontSize: '16px',
+    color: '#666',
+  },
+  error: {
+    textAlign: 'center',
+    padding: '40px',
+    fontSize: '16px',
+    color: '#e74c3c',
+  },
+  backButton: {
+    display: 'block',
+    margin: '0 auto',
+    padding: '14px 28px',
+    fontSize: '16px',
+    fontWeight: 'bold',
+    color: '#fff',
+    backgroundColor: '#06c755',
+    border: 'none',
+    borderRadius: '8px',
+    cursor: 'pointer',
+  },
+  backLink: {
+    padding: '0',
+    marginBottom: '12px',
+    fontSize: '14px',
+    color: '#00b0ff',
+    backgroundColor: 'transparent',
+    border: 'none',
+    cursor: 'pointer',
+  },
+  title: {
+    fontSize: '24px',
+    fontWeight: 'bold',
+    marginBottom: '20px',
+    textAlign: 'center',
+  },
+  section: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '8px',
+    padding: '16px',
+    marginBottom: '16px',
+    border: '1px solid #ddd',
+    borderRadius: '8px',
+    backgroundColor: '#fff',
+  },
+  sectionTitle: {
+    fontSize: '16px',
+    fontWeight: 'bold',
+    margin: '0 0 4px',
+    color: '#333',
+  },
+  detailRow: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    fontSize: '14px',
+  },
+  detailLabel: {
+    color: '#666',
+  },
+  detailValue: {
+    fontWeight: '500',
+    color: '#333',
+  },
+  emptyText: {
+    fontSize: '14px',
+    color: '#999',
+    margin: 0,
+  },
+  receipt: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '6px',
+    paddingBottom: '8px',
+    borderBottom: '1px solid #eee',
+  },
+  receiptImage: {
+    maxWidth: '100%',
+    borderRadius: '8px',
+  },
+  receiptLoading: {
+    fontSize: '13px',
+    color: '#999',
+  },
+  receiptError: {
+    fontSize: '13px',
+    color: '#c62828',
+  },
+  receiptNote: {
+    fontSize: '14px',
+    color: '#333',
+    margin: 0,
+    whiteSpace: 'pre-wrap',
+  },
+  timestamp: {
+    fontSize: '12px',
+    color: '#999',
+  },
+  participantRow: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    alignItems: 'center',
+    fontSize: '14px',
+  },
+  participantName: {
+    color: '#333',
+  },
+  tag: {
+    marginLeft: '6px',
+    padding: '2px 6px',
+    fontSize: '11px',
+    color: '#666',
+    backgroundColor: '#f0f0f0',
+    borderRadius: '4px',
+  },
+  stateBadge: {
+    padding: '4px 12px',
+    fontSize: '12px',
+    fontWeight: 'bold',
+    borderRadius: '12px',
+  },
+  stateApproved: {
+    backgroundColor: '#e8f5e9',
+    color: '#388e3c',
+  },
+  stateReported: {
+    backgroundColor: '#e3f2fd',
+    color: '#1976d2',
+  },
+  stateUnpaid: {
+    backgroundColor: '#fff3e0',
+    color: '#f57c00',
+  },
+  historyRow: {
+    display: 'flex',
+    flexDirection: 'column',
+    fontSize: '14px',
+    color: '#333',
+  },
+};
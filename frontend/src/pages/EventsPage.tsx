@@ -10,6 +10,14 @@ export default function EventsPage() {
   const [isLoadingData, setIsLoadingData] = useState(true);
   const [error, setError] = useState('');
 
+  // Botの通知のリンク（?eventId=ID）から開かれた場合はイベント詳細へ移動する
+  useEffect(() => {
+    const eventId = new URLSearchParams(window.location.search).get('eventId');
+    if (eventId) {
+      navigate(`/events/${encodeURIComponent(eventId)}`, { replace: true });
+    }
+  }, []);
+
   useEffect(() => {
     if (isLoggedIn && accessToken) {
       loadEvents();
@@ -76,7 +84,11 @@ export default function EventsPage() {
       ) : (
         <div style={styles.eventList}>
           {events.map((event) => (
-            <div key={event.id} style={styles.eventCard}>
+            <div
+              key={event.id}
+              style={styles.eventCard}
+              onClick={() => navigate(`/events/${event.id}`)}
+            >
               <div style={styles.eventHeader}>
                 <span style={styles.eventName}>{event.name}</span>
                 <span
@@ -222,6 +234,7 @@ const styles: { [key: string]: React.CSSProperties } = {
     border: '1px solid #ddd',
     borderRadius: '8px',
     backgroundColor: '#fff',
+    cursor: 'pointer',
   },
   eventHeader: {
     display: 'flex',
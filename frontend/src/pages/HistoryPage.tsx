@@ -0,0 +1,310 @@
+import { useState, useEffect } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { useLiff } from '../liff/useLiff';
+import {
+  getMyPaymentHistory,
+  getMyMonthlyTotals,
+  type MyPayment,
+  type MonthlyPaymentTotal,
+} from '../liff/api';
+
+const stateLabels: { [key in MyPayment['state']]: string } = {
+  unpaid: '未払い',
+  reported: '承認待ち',
+  approved: '精算済み',
+};
+
+// YYYY-MMをYYYY年M月にする
+function formatMonth(month: string) {
+  const [year, m] = month.split('-');
+  return `${year}年${Number(m)}月`;
+}
+
+export default function HistoryPage() {
+  const navigate = useNavigate();
+  const { isLoggedIn, isLoading, accessToken } = useLiff();
+  const [months, setMonths] = useState<MonthlyPaymentTotal[]>([]);
+  const [payments, setPayments] = useState<MyPayment[]>([]);
+  const [nextBeforeId, setNextBeforeId] = useState<number | null>(null);
+  const [isLoadingData, setIsLoadingData] = useState(true);
+  const [isLoadingMore, setIsLoadingMore] = useState(false);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    if (isLoggedIn && accessToken) {
+      loadHistory();
+    }
+  }, [isLoggedIn, accessToken]);
+
+  const loadHistory = async () => {
+    if (!accessToken) return;
+
+    setIsLoadingData(true);
+    try {
+      const [totals, history] = await Promise.all([
+        getMyMonthlyTotals(accessToken),
+        getMyPaymentHistory(accessToken),
+      ]);
+      setMonths(totals.months || []);
+      setPayments(history.payments || []);
+      setNextBeforeId(history.nextBeforeId);
+    } catch (err) {
+      console.error('支払い履歴取得エラー:', err);
+      setError('支払い履歴の取得に失敗しました');
+    } finally {
+      setIsLoadingData(false);
+    }
+  };
+
+  const loadMore = async () => {
+    if (!accessToken || !nextBeforeId) return;
+
+    setIsLoadingMore(true);
+    try {
+      const history = await getMyPaymentHistory(accessToken, nextBeforeId);
+      setPayments((prev) => [...prev, ...(history.payments || [])]);
+      setNextBeforeId(history.nextBeforeId);
+    } catch (err) {
+      console.error('支払い履歴取得エラー:', err);
+      setError('支払い履歴の取得に失敗しました');
+    } finally {
+      setIsLoadingMore(false);
+    }
+  };
+
+  if (isLoading || isLoadingData) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.loading}>読み込み中...</div>
+      </div>
+    );
+  }
+
+  if (!isLoggedIn) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.error}>ログインが必要です</div>
+      </div>
+    );
+  }
+
+  return (
+    <div style={styles.container}>
+      <h1 style={styles.title}>📒 支払い履歴</h1>
+
+      {error && <div style={styles.errorMessage}>{error}</div>}
+
+      {months.length > 0 && (
+        <div style={styles.section}>
+          <h2 style={styles.sectionTitle}>月ごとの集計</h2>
+          {months.map((m) => (
+            <div
+              key={m.month}
+              style={styles.monthRow}
+              onClick={() => navigate(`/statements?month=${m.month}`)}
+            >
+              <span style={styles.monthLabel}>{formatMonth(m.month)}</span>
+              <span style={styles.monthValues}>
+                請求 {m.billed.toLocaleString()}円 / 支払 {m.paid.toLocaleString()}円 / 受取 {m.received.toLocaleString()}円
+              </span>
+            </div>
+          ))}
+        </div>
+      )}
+
+      {payments.length === 0 ? (
+        <div style={styles.emptyState}>
+          <p style={styles.emptyText}>支払いの履歴はありません</p>
+        </div>
+      ) : (
+        <div style={styles.paymentList}>
+          {payments.map((payment) => (
+            <div
+              key={payment.participantId}
+              style={styles.paymentCard}
+              onClick={() => navigate(`/events/${payment.eventId}`)}
+            >
+              <div style={styles.paymentHeader}>
+                <span style={styles.eventName}>{payment.eventName}</span>
+                <span
+                  style={{
+                    ...styles.statusBadge,
+                    ...(payment.state === 'approved'
+                      ? styles.statusApproved
+                      : payment.state === 'reported'
+                      ? styles.statusReported
+                      : styles.statusUnpaid),
+                  }}
+                >
+                  {stateLabels[payment.state]}
+                </span>
+              </div>
+              <div style={styles.detailRow}>
+                <span style={styles.detailLabel}>
+                  {payment.circleName ? `${payment.circleName} / ` : ''}
+                  {payment.organizerName}さんへ
+                </span>
+                <span style={styles.detailValue}>{payment.amount.toLocaleString()}円</span>
+              </div>
+              <div style={styles.detailRow}>
+                <span style={styles.detailLabel}>
+                  {payment.approvedAt
+                    ? `承認: ${new Date(payment.approvedAt).toLocaleString('ja-JP')}`
+                    : payment.reportedAt
+                    ? `報告: ${new Date(payment.reportedAt).toLocaleString('ja-JP')}`
+                    : `作成: ${new Date(payment.createdAt).toLocaleString('ja-JP')}`}
+                </span>
+              </div>
+            </div>
+          ))}
+          {nextBeforeId && (
+            <button onClick={loadMore} disabled={isLoadingMore} style={styles.moreButton}>
+              {isLoadingMore ? '読み込み中...' : 'さらに表示'}
+            </button>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+const styles: { [key: string]: React.CSSProperties } = {
+  container: {
+    maxWidth: '600px',
+    margin: '0 auto',
+    padding: '20px',
+    fontFamily: 'sans-serif',
+  },
+  loading: {
+    textAlign: 'center',
+    padding: '40px',
+    fontSize: '16px',
+    color: '#666',
+  },
+  error: {
+    textAlign: 'center',
+    padding: '40px',
+    fontSize: '16px',
+    color: '#e74c3c',
+  },
+  title: {
+    fontSize: '24px',
+    fontWeight: 'bold',
+    marginBottom: '20px',
+    textAlign: 'center',
+  },
+  errorMessage: {
+    backgroundColor: '#ffebee',
+    color: '#c62828',
+    padding: '12px',
+    borderRadius: '8px',
+    fontSize: '14px',
+    marginBottom: '16px',
+  },
+  section: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '8px',
+    padding: '16px',
+    marginBottom: '16px',
+    border: '1px solid #ddd',
+    borderRadius: '8px',
+    backgroundColor: '#fff',
+  },
+  sectionTitle: {
+    fontSize: '16px',
+    fontWeight: 'bold',
+    margin: '0 0 4px',
+    color: '#333',
+  },
+  monthRow: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '2px',
+    paddingBottom: '8px',
+    borderBottom: '1px solid #eee',
+    cursor: 'pointer',
+  },
+  monthLabel: {
+    fontSize: '14px',
+    fontWeight: 'bold',
+    color: '#00b0ff',
+  },
+  monthValues: {
+    fontSize: '13px',
+    color: '#666',
+  },
+  emptyState: {
+    textAlign: 'center',
+    padding: '60px 20px',
+  },
+  emptyText: {
+    fontSize: '16px',
+    color: '#999',
+  },
+  paymentList: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '16px',
+  },
+  paymentCard: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '8px',
+    padding: '16px',
+    border: '1px solid #ddd',
+    borderRadius: '8px',
+    backgroundColor: '#fff',
+    cursor: 'pointer',
+  },
+  paymentHeader: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    alignItems: 'center',
+  },
+  eventName: {
+    fontSize: '18px',
+    fontWeight: 'bold',
+    color: '#333',
+  },
+  statusBadge: {
+    padding: '4px 12px',
+    fontSize: '12px',
+    fontWeight: 'bold',
+    borderRadius: '12px',
+  },
+  statusApproved: {
+    backgroundColor: '#e8f5e9',
+    color: '#388e3c',
+  },
+  statusReported: {
+    backgroundColor: '#e3f2fd',
+    color: '#1976d2',
+  },
+  statusUnpaid: {
+    backgroundColor: '#fff3e0',
+    color: '#f57c00',
+  },
+  detailRow: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    fontSize: '14px',
+  },
+  detailLabel: {
+    color: '#666',
+  },
+  detailValue: {
+    fontWeight: '500',
+    color: '#333',
+  },
+  moreButton: {
+    padding: '14px',
+    fontSize: '16px',
+    fontWeight: 'bold',
+    color: '#00b0ff',
+    backgroundColor: '#fff',
+    border: '1px solid #00b0ff',
+    borderRadius: '8px',
+    cursor: 'pointer',
+  },
+};
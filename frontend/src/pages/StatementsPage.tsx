@@ -0,0 +1,258 @@
+import { useState, useEffect } from 'react';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { useLiff } from '../liff/useLiff';
+import { getMyStatement, type MonthlyStatement, type StatementCounterparty } from '../liff/api';
+
+// YYYY-MMをYYYY年M月にする
+function formatMonth(month: string) {
+  const [year, m] = month.split('-');
+  return `${year}年${Number(m)}月`;
+}
+
+// YYYY-MMの月をdeltaか月ずらす
+function shiftMonth(month: string, delta: number) {
+  const [year, m] = month.split('-').map(Number);
+  const index = year * 12 + (m - 1) + delta;
+  return `${Math.floor(index / 12)}-${String((index % 12) + 1).padStart(2, '0')}`;
+}
+
+export default function StatementsPage() {
+  const navigate = useNavigate();
+  const [searchParams, setSearchParams] = useSearchParams();
+  const month = searchParams.get('month') || undefined;
+  const { isLoggedIn, isLoading, accessToken } = useLiff();
+  const [statement, setStatement] = useState<MonthlyStatement | null>(null);
+  const [isLoadingData, setIsLoadingData] = useState(true);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    if (isLoggedIn && accessToken) {
+      loadStatement();
+    }
+  }, [isLoggedIn, accessToken, month]);
+
+  const loadStatement = async () => {
+    if (!accessToken) return;
+
+    setIsLoadingData(true);
+    setError('');
+    try {
+      const response = await getMyStatement(accessToken, month);
+      setStatement(response.statement);
+    } catch (err) {
+      console.error('明細取得エラー:', err);
+      setStatement(null);
+      setError('明細の取得に失敗しました');
+    } finally {
+      setIsLoadingData(false);
+    }
+  };
+
+  const renderSection = (title: string, suffix: string, total: number, parties: StatementCounterparty[]) => (
+    <div style={styles.section}>
+      <div style={styles.sectionHeader}>
+        <h2 style={styles.sectionTitle}>{title}</h2>
+        <span style={styles.sectionTotal}>{total.toLocaleString()}円</span>
+      </div>
+      {parties.length === 0 ? (
+        <p style={styles.emptyText}>ありません</p>
+      ) : (
+        parties.map((party) => (
+          <div key={party.userId} style={styles.party}>
+            <div style={styles.partyHeader}>
+              <span style={styles.partyName}>{party.name}{suffix}</span>
+              <span style={styles.partyTotal}>{party.total.toLocaleString()}円</span>
+            </div>
+            {party.entries.map((entry, i) => (
+              <div
+                key={`${entry.eventId}-${i}`}
+                style={styles.entryRow}
+                onClick={() => navigate(`/events/${entry.eventId}`)}
+              >
+                <span style={styles.entryName}>
+                  {entry.eventName}
+                  {entry.circleName && <span style={styles.entryCircle}>（{entry.circleName}）</span>}
+                </span>
+                <span style={styles.entryAmount}>{entry.amount.toLocaleString()}円</span>
+              </div>
+            ))}
+          </div>
+        ))
+      )}
+    </div>
+  );
+
+  if (isLoading || isLoadingData) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.loading}>読み込み中...</div>
+      </div>
+    );
+  }
+
+  if (!isLoggedIn) {
+    return (
+      <div style={styles.container}>
+        <div style={styles.error}>ログインが必要です</div>
+      </div>
+    );
+  }
+
+  return (
+    <div style={styles.container}>
+      <button onClick={() => navigate('/history')} style={styles.backLink}>
+        ← 支払い履歴
+      </button>
+
+      <h1 style={styles.title}>🧾 {statement ? formatMonth(statement.month) : ''}の明細</h1>
+
+      {error && <div style={styles.errorMessage}>{error}</div>}
+
+      {statement && (
+        <>
+          <div style={styles.monthNav}>
+            <button
+              onClick={() => setSearchParams({ month: shiftMonth(statement.month, -1) })}
+              style={styles.monthButton}
+            >
+              ← 前の月
+            </button>
+            <button
+              onClick={() => setSearchParams({ month: shiftMonth(statement.month, 1) })}
+              style={styles.monthButton}
+            >
+              次の月 →
+            </button>
+          </div>
+
+          {renderSection('支払った分', 'さんへ', statement.paidTotal, statement.paid)}
+          {renderSection('受け取った分', 'さんから', statement.receivedTotal, statement.received)}
+        </>
+      )}
+    </div>
+  );
+}
+
+const styles: { [key: string]: React.CSSProperties } = {
+  container: {
+    maxWidth: '600px',
+    margin: '0 auto',
+    padding: '20px',
+    fontFamily: 'sans-serif',
+  },
+  loading: {
+    textAlign: 'center',
+    padding: '40px',
+    fontSize: '16px',
+    color: '#666',
+  },
+  error: {
+    textAlign: 'center',
+    padding: '40px',
+    fontSize: '16px',
+    color: '#e74c3c',
+  },
+  backLink: {
+    padding: '0',
+    marginBottom: '12px',
+    fontSize: '14px',
+    color: '#00b0ff',
+    backgroundColor: 'transparent',
+    border: 'none',
+    cursor: 'pointer',
+  },
+  title: {
+    fontSize: '24px',
+    fontWeight: 'bold',
+    marginBottom: '20px',
+    textAlign: 'center',
+  },
+  errorMessage: {
+    backgroundColor: '#ffebee',
+    color: '#c62828',
+    padding: '12px',
+    borderRadius: '8px',
+    fontSize: '14px',
+    marginBottom: '16px',
+  },
+  monthNav: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    marginBottom: '16px',
+  },
+  monthButton: {
+    padding: '8px 16px',
+    fontSize: '14px',
+    color: '#00b0ff',
+    backgroundColor: '#fff',
+    border: '1px solid #00b0ff',
+    borderRadius: '8px',
+    cursor: 'pointer',
+  },
+  section: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '12px',
+    padding: '16px',
+    marginBottom: '16px',
+    border: '1px solid #ddd',
+    borderRadius: '8px',
+    backgroundColor: '#fff',
+  },
+  sectionHeader: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    alignItems: 'center',
+  },
+  sectionTitle: {
+    fontSize: '16px',
+    fontWeight: 'bold',
+    margin: 0,
+    color: '#333',
+  },
+  sectionTotal: {
+    fontSize: '16px',
+    fontWeight: 'bold',
+    color: '#333',
+  },
+  emptyText: {
+    fontSize: '14px',
+    color: '#999',
+    margin: 0,
+  },
+  party: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: '4px',
+    paddingBottom: '8px',
+    borderBottom: '1px solid #eee',
+  },
+  partyHeader: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    fontSize: '14px',
+    fontWeight: 'bold',
+  },
+  partyName: {
+    color: '#333',
+  },
+  partyTotal: {
+    color: '#333',
+  },
+  entryRow: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    paddingLeft: '12px',
+    fontSize: '13px',
+    cursor: 'pointer',
+  },
+  entryName: {
+    color: '#666',
+  },
+  entryCircle: {
+    color: '#999',
+  },
+  entryAmount: {
+    color: '#666',
+  },
+};